@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
+	"kit8-backend/internal/core/auth"
+	"kit8-backend/internal/core/billing"
+	"kit8-backend/internal/core/clock"
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/registry"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
+	"kit8-backend/internal/database"
+
 	// Импортируем наши модули
 	cashier "kit8-backend/internal/modules/cashier"
 	crm "kit8-backend/internal/modules/crm"
@@ -15,17 +32,54 @@ import (
 )
 
 func main() {
-	app := fiber.New()
+	storage := flag.String("storage", "postgres", "хранилище данных: postgres или memory")
+	flag.Parse()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: errorHandler,
+		// Значения из запроса (параметры, заголовки) сохраняются в хранилищах,
+		// поэтому они не должны ссылаться на переиспользуемые буферы fasthttp
+		Immutable: true,
+	})
 
 	// Middleware
 	app.Use(logger.New())
 	app.Use(cors.New())
 
+	// Подключаем хранилище данных
+	repos, closeStorage := openStorage(*storage)
+	defer closeStorage()
+
+	// Авторизация
+	authService := auth.NewService(repos.users, auth.Config{Secret: jwtSecret()})
+	bootstrapAdmin(authService, repos.tenants)
+
 	// Инициализируем контроллеры
-	crmController := crm.NewController()
-	inventoryController := inventory.NewController()
-	ordersController := orders.NewController()
-	cashierController := cashier.NewController()
+	authController := auth.NewController(authService)
+	currencyService := currency.NewService(repos.currencies)
+	currencyController := currency.NewController(currencyService)
+	taxService := tax.NewService(repos.taxes)
+	taxController := tax.NewController(taxService)
+	inventoryController := inventory.NewController(repos.products, currencyService, taxService)
+	ordersController := orders.NewController(repos.orders, repos.coupons, repos.products, repos.products, currencyService, taxService)
+	crmController := crm.NewController(repos.contacts, repos.deals, repos.pipelines, currencyService,
+		orders.NewSales(ordersController))
+	cashierController := cashier.NewController(repos.payments, repos.shifts, repos.receipts, paymentProvider(), fiscalization(),
+		orders.NewLedger(repos.orders), currencyService, taxService)
+
+	// Регистрируем модули платформы
+	modules := registry.New()
+	modules.Register(crm.NewModule(crmController))
+	modules.Register(inventory.NewModule(inventoryController))
+	modules.Register(orders.NewModule(ordersController))
+	modules.Register(cashier.NewModule(cashierController))
+	modulesController := registry.NewController(modules, repos.subscriptions)
+
+	// Биллинг: счета за завершенные циклы выставляются в фоне
+	billingService := billing.NewService(clock.Real(), modules, repos.subscriptions, repos.invoices,
+		billing.Config{TrialDays: envInt("KIT8_TRIAL_DAYS", 14)})
+	billingController := billing.NewController(billingService)
+	go billingService.Run(context.Background(), time.Hour)
 
 	// Основные маршруты
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -35,51 +89,286 @@ func main() {
 		})
 	})
 
+	// Маршруты авторизации, доступные без токена
+	authRoutes := app.Group("/api/auth")
+	authRoutes.Post("/login", authController.Login)
+	authRoutes.Post("/refresh", authController.Refresh)
+
+	// Уведомления платежных провайдеров подписаны провайдером и приходят без токена
+	app.Post("/api/cashier/webhooks/:provider", cashierController.HandleWebhook)
+
 	// Маршруты API
-	api := app.Group("/api")
-
-	// CRM маршруты
-	crmRoutes := api.Group("/crm")
-	crmRoutes.Get("/contacts", crmController.GetContacts)
-	crmRoutes.Post("/contacts", crmController.CreateContact)
-	crmRoutes.Put("/contacts/:id", crmController.UpdateContact)
-	crmRoutes.Delete("/contacts/:id", crmController.DeleteContact)
-	crmRoutes.Get("/deals", crmController.GetDeals)
-	crmRoutes.Post("/deals", crmController.CreateDeal)
-	crmRoutes.Put("/deals/:id", crmController.UpdateDeal)
-	crmRoutes.Delete("/deals/:id", crmController.DeleteDeal)
-	crmRoutes.Get("/deals/stats", crmController.GetDealStats)
-	crmRoutes.Get("/stats", crmController.GetCRMStats)
-
-	// Inventory маршруты
-	inventoryRoutes := api.Group("/inventory")
-	inventoryRoutes.Get("/products", inventoryController.GetProducts)
-	inventoryRoutes.Post("/products", inventoryController.CreateProduct)
-	inventoryRoutes.Put("/products/:id", inventoryController.UpdateProduct)
-	inventoryRoutes.Delete("/products/:id", inventoryController.DeleteProduct)
-	inventoryRoutes.Get("/products/:id", inventoryController.GetProduct)
-	inventoryRoutes.Get("/stats", inventoryController.GetInventoryStats)
-	// Дополнительные маршруты для инвентаря (если требуются)
-
-	// Orders маршруты
-	ordersRoutes := api.Group("/orders")
-	// Дополнительные маршруты для заказов (если требуются)
-	ordersRoutes.Get("/stats", ordersController.GetOrderStats)
-	ordersRoutes.Get("/orders", ordersController.GetOrders)
-	ordersRoutes.Post("/orders", ordersController.CreateOrder)
-	ordersRoutes.Put("/orders/:id", ordersController.UpdateOrder)
-	ordersRoutes.Delete("/orders/:id", ordersController.DeleteOrder)
-	ordersRoutes.Get("/orders/:id", ordersController.GetOrder)
-
-	// Cashier маршруты
-	cashierRoutes := api.Group("/cashier")
-	// Дополнительные маршруты для кассы (если требуются)
-	cashierRoutes.Get("/stats", cashierController.GetCashierStats)
-	cashierRoutes.Get("/payments", cashierController.GetPayments)
-	cashierRoutes.Post("/payments", cashierController.CreatePayment)
-	cashierRoutes.Put("/payments/:id", cashierController.UpdatePayment)
-	cashierRoutes.Post("/process", cashierController.ProcessPayment)
-	cashierRoutes.Post("/refund/:id", cashierController.RefundPayment)
+	api := app.Group("/api", tenant.Middleware(tenantResolvers(authService, repos.tenants)...))
+
+	// Маршруты текущего пользователя
+	api.Post("/auth/logout", authController.Logout)
+	api.Get("/auth/me", authController.Me)
+
+	// Пользователи компании и их роли
+	api.Get("/auth/users", rbac.Require(rbac.UsersManage), authController.GetUsers)
+	api.Post("/auth/users", rbac.Require(rbac.UsersManage), authController.CreateUser)
+	api.Put("/auth/users/:id", rbac.Require(rbac.UsersManage), authController.UpdateUser)
+
+	// Каталог модулей и подписки компании
+	api.Get("/modules", rbac.Require(rbac.ModulesRead), modulesController.GetModules)
+	api.Post("/modules/:name/enable", rbac.Require(rbac.ModulesManage), modulesController.EnableModule)
+	api.Post("/modules/:name/disable", rbac.Require(rbac.ModulesManage), modulesController.DisableModule)
+
+	// Счета за подписки
+	api.Get("/billing/invoices", rbac.Require(rbac.BillingRead), billingController.GetInvoices)
+	api.Get("/billing/invoices/upcoming", rbac.Require(rbac.BillingRead), billingController.GetUpcomingInvoice)
+	api.Get("/billing/invoices/:id", rbac.Require(rbac.BillingRead), billingController.GetInvoice)
+
+	// Базовая валюта компании и курсы валют для пересчета статистики модулей
+	api.Get("/currency", rbac.Require(rbac.CurrencyRead), currencyController.GetSettings)
+	api.Put("/currency", rbac.Require(rbac.CurrencyManage), currencyController.UpdateSettings)
+	api.Get("/currency/rates", rbac.Require(rbac.CurrencyRead), currencyController.GetRates)
+	api.Post("/currency/rates", rbac.Require(rbac.RatesWrite), currencyController.CreateRate)
+	api.Post("/currency/rates/import", rbac.Require(rbac.RatesWrite), currencyController.ImportRates)
+	api.Delete("/currency/rates/:id", rbac.Require(rbac.RatesWrite), currencyController.DeleteRate)
+
+	// Режим цен (с налогом или без) и налоговая категория компании по умолчанию
+	api.Get("/tax", rbac.Require(rbac.TaxRead), taxController.GetSettings)
+	api.Put("/tax", rbac.Require(rbac.TaxManage), taxController.UpdateSettings)
+
+	// Маршруты модулей: /api/crm, /api/inventory, /api/orders, /api/cashier.
+	// Доступны только компаниям, подписанным на соответствующий модуль.
+	modules.Mount(api, repos.subscriptions)
 
 	log.Fatal(app.Listen(":3000"))
 }
+
+// repositories - хранилища данных платформы и всех модулей
+type repositories struct {
+	tenants       tenant.Store
+	users         auth.Store
+	subscriptions registry.SubscriptionStore
+	invoices      billing.InvoiceStore
+	currencies    currency.Store
+	taxes         tax.Store
+
+	contacts  crm.ContactRepository
+	deals     crm.DealRepository
+	pipelines crm.PipelineRepository
+	products  inventory.ProductRepository
+	orders    orders.OrderRepository
+	coupons   orders.CouponRepository
+	payments  cashier.PaymentRepository
+	shifts    cashier.ShiftRepository
+	receipts  cashier.ReceiptRepository
+}
+
+// openStorage создает репозитории выбранного типа:
+// postgres - база данных из DATABASE_URL, memory - данные в памяти процесса
+func openStorage(storage string) (*repositories, func()) {
+	switch storage {
+	case "memory":
+		log.Println("warning: using in-memory storage, data will be lost on restart")
+		crmRepository := crm.NewMemoryRepository()
+		ordersRepository := orders.NewMemoryRepository(crmRepository)
+		cashierRepository := cashier.NewMemoryRepository()
+		return &repositories{
+			tenants:       tenant.NewMemoryStore(),
+			users:         auth.NewMemoryStore(),
+			subscriptions: registry.NewMemorySubscriptionStore(),
+			invoices:      billing.NewMemoryInvoiceStore(),
+			currencies:    currency.NewMemoryStore(),
+			taxes:         tax.NewMemoryStore(),
+
+			contacts:  crmRepository,
+			deals:     crmRepository,
+			pipelines: crmRepository,
+			products:  inventory.NewMemoryRepository(),
+			orders:    ordersRepository,
+			coupons:   ordersRepository,
+			payments:  cashierRepository,
+			shifts:    cashierRepository,
+			receipts:  cashierRepository,
+		}, func() {}
+
+	case "postgres":
+		db, err := database.Open(os.Getenv("DATABASE_URL"))
+		if err != nil {
+			log.Fatalf("database: %v", err)
+		}
+		// Не запускаемся на устаревшей схеме: запросы модулей упадут на первом же обращении
+		if err := database.CheckSchema(context.Background(), db); err != nil {
+			log.Fatal(err)
+		}
+		crmRepository := crm.NewPostgresRepository(db)
+		ordersRepository := orders.NewPostgresRepository(db)
+		cashierRepository := cashier.NewPostgresRepository(db)
+		return &repositories{
+			tenants:       tenant.NewPostgresStore(db),
+			users:         auth.NewPostgresStore(db),
+			subscriptions: registry.NewPostgresSubscriptionStore(db),
+			invoices:      billing.NewPostgresInvoiceStore(db),
+			currencies:    currency.NewPostgresStore(db),
+			taxes:         tax.NewPostgresStore(db),
+
+			contacts:  crmRepository,
+			deals:     crmRepository,
+			pipelines: crmRepository,
+			products:  inventory.NewPostgresRepository(db),
+			orders:    ordersRepository,
+			coupons:   ordersRepository,
+			payments:  cashierRepository,
+			shifts:    cashierRepository,
+			receipts:  cashierRepository,
+		}, func() { db.Close() }
+
+	default:
+		log.Fatalf("unknown storage %q, expected postgres or memory", storage)
+		return nil, nil
+	}
+}
+
+// tenantResolvers собирает способы определения компании:
+// токены пользователей из authService и настройки из переменных окружения
+// KIT8_API_KEYS - API-ключи в формате "key=customer_id,...",
+// KIT8_BASE_DOMAIN - домен, поддомены которого ищутся среди компаний tenants;
+// KIT8_SUBDOMAINS - поддомены в формате "acme=customer_id,...", переопределяющие поддомены компаний
+func tenantResolvers(authService *auth.Service, tenants tenant.Store) []tenant.Resolver {
+	resolvers := []tenant.Resolver{tenant.BearerToken(authService)}
+
+	if keys := os.Getenv("KIT8_API_KEYS"); keys != "" {
+		m, err := tenant.ParseMapping(keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resolvers = append(resolvers, apiKeyResolver(tenant.StaticLookup(m)))
+	}
+
+	if domain := os.Getenv("KIT8_BASE_DOMAIN"); domain != "" {
+		overrides, err := tenant.ParseMapping(os.Getenv("KIT8_SUBDOMAINS"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		resolvers = append(resolvers, tenant.Subdomain(domain, func(ctx context.Context, subdomain string) (int, error) {
+			if id, ok := overrides[subdomain]; ok {
+				return id, nil
+			}
+			return tenants.SubdomainID(ctx, subdomain)
+		}))
+	}
+
+	return resolvers
+}
+
+// apiKeyResolver определяет компанию по API-ключу. Ключ выдается компании целиком,
+// поэтому запросы с ним без токена пользователя выполняются с правами владельца.
+func apiKeyResolver(lookup func(key string) (int, bool)) tenant.Resolver {
+	resolver := tenant.APIKey(lookup)
+	return tenant.ResolverFunc(func(c *fiber.Ctx) (int, error) {
+		customerID, err := resolver.Resolve(c)
+		if err == nil && customerID != 0 && rbac.Role(c) == "" {
+			rbac.SetRole(c, rbac.RoleOwner)
+		}
+		return customerID, err
+	})
+}
+
+// paymentProvider создает платежного провайдера. Пока доступен только встроенный
+// mock-провайдер; KIT8_PAYMENT_MOCK_OUTCOME задает исход платежей: approve, decline или timeout,
+// KIT8_PAYMENT_WEBHOOK_SECRET - секрет подписи его уведомлений.
+func paymentProvider() cashier.PaymentProvider {
+	outcome := os.Getenv("KIT8_PAYMENT_MOCK_OUTCOME")
+	if outcome != "" && !cashier.ValidMockOutcome(outcome) {
+		log.Fatal("KIT8_PAYMENT_MOCK_OUTCOME must be approve, decline or timeout")
+	}
+	return cashier.NewMockProvider(cashier.MockConfig{
+		Outcome:       outcome,
+		WebhookSecret: []byte(os.Getenv("KIT8_PAYMENT_WEBHOOK_SECRET")),
+	})
+}
+
+// envInt возвращает числовое значение переменной окружения или def, если она не задана
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be a number", key)
+	}
+	return n
+}
+
+// fiscalization задает регистрацию чеков. Пока доступен только эмулятор фискального
+// накопителя; KIT8_FISCAL_TAXATION задает систему налогообложения (по умолчанию usn_income).
+// Ставки НДС позиций следуют налоговым категориям товаров.
+func fiscalization() cashier.Fiscalization {
+	fiscal := cashier.Fiscalization{
+		Driver:   cashier.NewFiscalEmulator(),
+		Taxation: os.Getenv("KIT8_FISCAL_TAXATION"),
+	}
+	if fiscal.Taxation == "" {
+		fiscal.Taxation = cashier.TaxationUSNIncome
+	}
+	if !cashier.ValidTaxation(fiscal.Taxation) {
+		log.Fatal("KIT8_FISCAL_TAXATION must be osn, usn_income, usn_income_outcome, esn or patent")
+	}
+	return fiscal
+}
+
+// jwtSecret возвращает секрет подписи токенов из KIT8_JWT_SECRET.
+// Если он не задан, генерируется случайный секрет, и токены перестают
+// действовать после перезапуска.
+func jwtSecret() []byte {
+	if secret := os.Getenv("KIT8_JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	log.Println("warning: KIT8_JWT_SECRET is not set, using a random secret")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal(err)
+	}
+	return secret
+}
+
+// bootstrapAdmin создает первого пользователя из KIT8_ADMIN_EMAIL,
+// KIT8_ADMIN_PASSWORD и KIT8_ADMIN_CUSTOMER_ID, если они заданы.
+// Компания KIT8_ADMIN_CUSTOMER_ID создается, если ее еще нет; название
+// новой компании задает KIT8_ADMIN_COMPANY.
+func bootstrapAdmin(authService *auth.Service, tenants tenant.Store) {
+	email := os.Getenv("KIT8_ADMIN_EMAIL")
+	if email == "" {
+		return
+	}
+
+	customerID, err := strconv.Atoi(os.Getenv("KIT8_ADMIN_CUSTOMER_ID"))
+	if err != nil || customerID <= 0 {
+		log.Fatal("KIT8_ADMIN_CUSTOMER_ID must be a positive number")
+	}
+	name := os.Getenv("KIT8_ADMIN_COMPANY")
+	if name == "" {
+		name = "Kit8"
+	}
+	if err := tenants.Ensure(context.Background(), customerID, name); err != nil {
+		log.Fatal(err)
+	}
+
+	user := auth.User{CustomerID: customerID, Email: email, Name: "Administrator", Role: rbac.RoleOwner}
+	err = authService.CreateUser(context.Background(), &user, os.Getenv("KIT8_ADMIN_PASSWORD"))
+	if err != nil && !errors.Is(err, auth.ErrEmailTaken) {
+		log.Fatal(err)
+	}
+}
+
+// errorHandler возвращает ошибки в том же JSON-формате, что и обработчики модулей
+func errorHandler(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	message := "Internal server error"
+
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code = e.Code
+		message = e.Message
+	} else {
+		log.Println(err)
+	}
+
+	return c.Status(code).JSON(fiber.Map{"error": message})
+}
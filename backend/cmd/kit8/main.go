@@ -0,0 +1,126 @@
+// Команда kit8 - служебные операции платформы.
+//
+//	kit8 migrate up        - применить все новые миграции
+//	kit8 migrate down [N]  - откатить N последних миграций (по умолчанию 1)
+//	kit8 migrate status    - показать состояние миграций
+//	kit8 tenant create NAME [SUBDOMAIN] - создать компанию и вывести ее ID
+//
+// Подключение к базе берется из переменной окружения DATABASE_URL.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"kit8-backend/internal/core/tenant"
+	"kit8-backend/internal/database"
+)
+
+const usage = `usage:
+  kit8 migrate up
+  kit8 migrate down [steps]
+  kit8 migrate status
+  kit8 tenant create name [subdomain]`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(context.Background(), os.Args[2], os.Args[3:])
+	case "tenant":
+		err = tenantCommand(context.Background(), os.Args[2], os.Args[3:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kit8:", err)
+		os.Exit(1)
+	}
+}
+
+// tenantCommand создает компанию: записи модулей и пользователи ссылаются на нее,
+// поэтому компанию нужно создать до первого входа
+func tenantCommand(ctx context.Context, command string, args []string) error {
+	if command != "create" || len(args) < 1 || len(args) > 2 || args[0] == "" {
+		return fmt.Errorf("invalid tenant command\n%s", usage)
+	}
+	subdomain := ""
+	if len(args) > 1 {
+		subdomain = args[1]
+	}
+
+	db, err := database.Open(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := database.CheckSchema(ctx, db); err != nil {
+		return err
+	}
+	id, err := tenant.NewPostgresStore(db).Create(ctx, args[0], subdomain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created tenant %d\n", id)
+	return nil
+}
+
+func migrate(ctx context.Context, command string, args []string) error {
+	db, err := database.Open(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(ctx, db)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[0])
+			}
+		}
+		reverted, err := database.MigrateDown(ctx, db, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := database.MigrateStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied() {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, usage)
+	}
+}
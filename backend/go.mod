@@ -2,11 +2,16 @@ module kit8-backend
 
 go 1.21
 
-require github.com/gofiber/fiber/v2 v2.52.2
+require (
+	github.com/gofiber/fiber/v2 v2.52.2
+	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/google/uuid v1.5.0
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.17.0
+)
 
 require (
 	github.com/andybalholm/brotli v1.0.5 // indirect
-	github.com/google/uuid v1.5.0 // indirect
 	github.com/klauspost/compress v1.17.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
@@ -0,0 +1,347 @@
+// Package auth реализует вход пользователей, выпуск и отзыв JWT-токенов.
+// Каждый пользователь принадлежит одной компании (customer_id), и токены
+// несут ее ID, поэтому сервис одновременно служит источником tenant.TokenVerifier.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"kit8-backend/internal/core/rbac"
+)
+
+// Типы токенов
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// localsKey - ключ, под которым данные токена хранятся в контексте запроса
+const localsKey = "auth_claims"
+
+var (
+	ErrInvalidCredentials = errors.New("auth: invalid email or password")
+	ErrInvalidToken       = errors.New("auth: invalid token")
+	ErrUserNotFound       = errors.New("auth: user not found")
+	ErrEmailTaken         = errors.New("auth: email already registered")
+	ErrInvalidRole        = errors.New("auth: unknown role")
+	ErrLastOwner          = errors.New("auth: company must keep at least one owner")
+)
+
+// User представляет пользователя платформы
+type User struct {
+	ID           int    `json:"id"`
+	CustomerID   int    `json:"customer_id"` // ID компании
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Role         string `json:"role"` // owner, manager, sales, cashier, warehouse
+	PasswordHash string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// Claims - содержимое access и refresh токенов
+type Claims struct {
+	CustomerID int    `json:"customer_id"`
+	Role       string `json:"role"`
+	Type       string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// UserID возвращает ID пользователя из поля sub
+func (c *Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// RefreshToken - запись о выданном refresh токене
+type RefreshToken struct {
+	ID        string
+	UserID    int
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+// TokenPair - результат входа или обновления токенов
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Время жизни access токена в секундах
+}
+
+// Config - настройки сервиса авторизации
+type Config struct {
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Service выдает, обновляет, проверяет и отзывает токены
+type Service struct {
+	store  Store
+	config Config
+	now    func() time.Time
+}
+
+// NewService создает сервис авторизации
+func NewService(store Store, config Config) *Service {
+	if config.AccessTTL == 0 {
+		config.AccessTTL = 15 * time.Minute
+	}
+	if config.RefreshTTL == 0 {
+		config.RefreshTTL = 30 * 24 * time.Hour
+	}
+	return &Service{store: store, config: config, now: time.Now}
+}
+
+// HashPassword возвращает bcrypt-хеш пароля
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CreateUser регистрирует пользователя в компании
+func (s *Service) CreateUser(ctx context.Context, user *User, password string) error {
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	if user.Email == "" || password == "" || user.CustomerID == 0 {
+		return ErrInvalidCredentials
+	}
+	if !rbac.ValidRole(user.Role) {
+		return ErrInvalidRole
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.CreatedAt = s.now().UTC().Format(time.RFC3339)
+
+	return s.store.CreateUser(ctx, user)
+}
+
+// User возвращает пользователя по ID
+func (s *Service) User(ctx context.Context, id int) (*User, error) {
+	return s.store.UserByID(ctx, id)
+}
+
+// Users возвращает пользователей компании
+func (s *Service) Users(ctx context.Context, customerID int) ([]User, error) {
+	return s.store.ListUsers(ctx, customerID)
+}
+
+// UpdateUser меняет имя и роль пользователя компании.
+// Последнего владельца компании нельзя перевести в другую роль.
+// Новая роль начинает действовать со следующего access токена.
+func (s *Service) UpdateUser(ctx context.Context, customerID, id int, name, role string) (*User, error) {
+	if !rbac.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.store.UserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.CustomerID != customerID {
+		return nil, ErrUserNotFound
+	}
+
+	if user.Role == rbac.RoleOwner && role != rbac.RoleOwner {
+		users, err := s.store.ListUsers(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		owners := 0
+		for _, u := range users {
+			if u.Role == rbac.RoleOwner {
+				owners++
+			}
+		}
+		if owners <= 1 {
+			return nil, ErrLastOwner
+		}
+	}
+
+	user.Name = name
+	user.Role = role
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login проверяет пароль и выдает пару токенов
+func (s *Service) Login(ctx context.Context, email, password string) (*TokenPair, *User, error) {
+	user, err := s.store.UserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	pair, err := s.issue(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, user, nil
+}
+
+// Refresh обменивает действующий refresh токен на новую пару.
+// Старый refresh токен отзывается, поэтому использовать его повторно нельзя.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := s.parse(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.store.RefreshToken(ctx, claims.ID)
+	if err != nil || stored.Revoked || stored.UserID != claims.UserID() {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.store.UserByID(ctx, stored.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Токен отзывается условно: из параллельных обменов одного токена проходит только один
+	if err := s.store.RevokeRefreshToken(ctx, stored.ID); err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, user)
+}
+
+// Logout отзывает access токен текущего запроса и, если передан, refresh токен пользователя
+func (s *Service) Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if err := s.store.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	refresh, err := s.parse(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return err
+	}
+	if refresh.Subject != claims.Subject {
+		return ErrInvalidToken
+	}
+	// Повторный выход с уже отозванным refresh токеном не ошибка
+	if err := s.store.RevokeRefreshToken(ctx, refresh.ID); err != nil && !errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+	return nil
+}
+
+// VerifyToken проверяет access токен, сохраняет его данные в контексте запроса
+// и возвращает ID компании. Реализует tenant.TokenVerifier.
+func (s *Service) VerifyToken(c *fiber.Ctx, token string) (int, error) {
+	claims, err := s.parse(token, TokenTypeAccess)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.store.IsAccessTokenRevoked(c.UserContext(), claims.ID)
+	if err != nil {
+		return 0, err
+	}
+	if revoked {
+		return 0, ErrInvalidToken
+	}
+
+	c.Locals(localsKey, claims)
+	rbac.SetRole(c, claims.Role)
+	return claims.CustomerID, nil
+}
+
+// CurrentClaims возвращает данные access токена текущего запроса.
+// Если запрос авторизован не токеном пользователя (например, API-ключом), ok = false.
+func CurrentClaims(c *fiber.Ctx) (*Claims, bool) {
+	claims, ok := c.Locals(localsKey).(*Claims)
+	return claims, ok
+}
+
+// issue выпускает пару токенов для пользователя и сохраняет refresh токен
+func (s *Service) issue(ctx context.Context, user *User) (*TokenPair, error) {
+	now := s.now()
+
+	access, _, err := s.sign(user, TokenTypeAccess, now, s.config.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, refreshClaims, err := s.sign(user, TokenTypeRefresh, now, s.config.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.SaveRefreshToken(ctx, &RefreshToken{
+		ID:        refreshClaims.ID,
+		UserID:    user.ID,
+		ExpiresAt: refreshClaims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.AccessTTL.Seconds()),
+	}, nil
+}
+
+func (s *Service) sign(user *User, tokenType string, now time.Time, ttl time.Duration) (string, *Claims, error) {
+	claims := &Claims{
+		CustomerID: user.CustomerID,
+		Role:       user.Role,
+		Type:       tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.config.Secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+func (s *Service) parse(token, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return s.config.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil || claims.Type != tokenType || claims.ID == "" || claims.CustomerID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// LoginRequest - тело запроса на вход
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest - тело запросов на обновление токенов и выход
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CreateUserRequest - тело запроса на добавление пользователя в компанию
+type CreateUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+// UpdateUserRequest - тело запроса на изменение пользователя
+type UpdateUserRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// Контроллер авторизации
+type Controller struct {
+	service *Service
+}
+
+// NewController создает новый контроллер авторизации
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Login выполняет вход по email и паролю
+func (ctrl *Controller) Login(c *fiber.Ctx) error {
+	var req LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	pair, user, err := ctrl.service.Login(c.UserContext(), req.Email, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid email or password"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"token_type":    pair.TokenType,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
+	})
+}
+
+// Refresh выдает новую пару токенов по refresh токену
+func (ctrl *Controller) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	pair, err := ctrl.service.Refresh(c.UserContext(), req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(pair)
+}
+
+// Logout отзывает текущий access токен и переданный refresh токен
+func (ctrl *Controller) Logout(c *fiber.Ctx) error {
+	claims, ok := CurrentClaims(c)
+	if !ok {
+		return tenant.ErrUnauthorized
+	}
+
+	// Тело запроса необязательно: без него отзывается только access токен
+	var req RefreshRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	err := ctrl.service.Logout(c.UserContext(), claims, req.RefreshToken)
+	if errors.Is(err, ErrInvalidToken) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Me возвращает текущего пользователя
+func (ctrl *Controller) Me(c *fiber.Ctx) error {
+	claims, ok := CurrentClaims(c)
+	if !ok {
+		return tenant.ErrUnauthorized
+	}
+
+	user, err := ctrl.service.User(c.UserContext(), claims.UserID())
+	if errors.Is(err, ErrUserNotFound) {
+		return tenant.ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
+
+// CreateUser добавляет пользователя в компанию текущего запроса
+func (ctrl *Controller) CreateUser(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Email == "" || len(req.Password) < 8 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Email and password of at least 8 characters are required"})
+	}
+
+	user := User{CustomerID: customerID, Email: req.Email, Name: req.Name, Role: req.Role}
+	err = ctrl.service.CreateUser(c.UserContext(), &user, req.Password)
+	if errors.Is(err, ErrEmailTaken) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Email already registered"})
+	}
+	if errors.Is(err, ErrInvalidRole) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown role"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(user)
+}
+
+// GetUsers возвращает пользователей текущей компании
+func (ctrl *Controller) GetUsers(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	users, err := ctrl.service.Users(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(users)
+}
+
+// UpdateUser меняет имя и роль пользователя текущей компании
+func (ctrl *Controller) UpdateUser(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
+	var req UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := ctrl.service.UpdateUser(c.UserContext(), customerID, id, req.Name, req.Role)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case errors.Is(err, ErrInvalidRole):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown role"})
+	case errors.Is(err, ErrLastOwner):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Company must keep at least one owner"})
+	case err != nil:
+		return err
+	}
+
+	return c.JSON(user)
+}
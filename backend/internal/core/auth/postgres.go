@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresStore реализует Store поверх таблиц users, refresh_tokens и revoked_tokens
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore создает хранилище пользователей и токенов
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const userColumns = `id, customer_id, email, name, role, password_hash, created_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	var u User
+	var createdAt time.Time
+	err := row.Scan(&u.ID, &u.CustomerID, &u.Email, &u.Name, &u.Role, &u.PasswordHash, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.CreatedAt = database.FormatTime(createdAt)
+	return &u, nil
+}
+
+func (s *PostgresStore) CreateUser(ctx context.Context, user *User) error {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO users (customer_id, email, name, role, password_hash) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id`,
+		user.CustomerID, user.Email, user.Name, user.Role, user.PasswordHash,
+	).Scan(&user.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+func (s *PostgresStore) UserByEmail(ctx context.Context, email string) (*User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+func (s *PostgresStore) UserByID(ctx context.Context, id int) (*User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+func (s *PostgresStore) ListUsers(ctx context.Context, customerID int) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (s *PostgresStore) UpdateUser(ctx context.Context, user *User) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE users SET name = $2, role = $3, updated_at = now() WHERE id = $1`,
+		user.ID, user.Name, user.Role)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) SaveRefreshToken(ctx context.Context, token *RefreshToken) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, user_id, expires_at) VALUES ($1, $2, $3)`,
+		token.ID, token.UserID, token.ExpiresAt)
+	return err
+}
+
+func (s *PostgresStore) RefreshToken(ctx context.Context, id string) (*RefreshToken, error) {
+	var t RefreshToken
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, expires_at, revoked FROM refresh_tokens WHERE id = $1`, id,
+	).Scan(&t.ID, &t.UserID, &t.ExpiresAt, &t.Revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) RevokeRefreshToken(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE id = $1 AND revoked = FALSE`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *PostgresStore) RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error {
+	// Попутно удаляем записи, срок действия которых уже истек
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (id, expires_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`,
+		id, expiresAt)
+	return err
+}
+
+func (s *PostgresStore) IsAccessTokenRevoked(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE id = $1)`, id).Scan(&revoked)
+	return revoked, err
+}
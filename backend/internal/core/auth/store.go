@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store хранит пользователей, выданные refresh токены и отозванные access токены
+type Store interface {
+	CreateUser(ctx context.Context, user *User) error
+	UserByEmail(ctx context.Context, email string) (*User, error)
+	UserByID(ctx context.Context, id int) (*User, error)
+	ListUsers(ctx context.Context, customerID int) ([]User, error)
+	UpdateUser(ctx context.Context, user *User) error
+
+	SaveRefreshToken(ctx context.Context, token *RefreshToken) error
+	RefreshToken(ctx context.Context, id string) (*RefreshToken, error)
+	// RevokeRefreshToken атомарно отзывает действующий refresh токен; ErrInvalidToken,
+	// если токена нет или он уже отозван. Так токен обменивается не больше одного раза.
+	RevokeRefreshToken(ctx context.Context, id string) error
+
+	RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// MemoryStore - потокобезопасная реализация Store в памяти процесса
+type MemoryStore struct {
+	mu      sync.RWMutex
+	nextID  int
+	users   map[int]User
+	refresh map[string]RefreshToken
+	revoked map[string]time.Time // ID access токена -> время истечения
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:   make(map[int]User),
+		refresh: make(map[string]RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	s.nextID++
+	user.ID = s.nextID
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryStore) UserByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *MemoryStore) UserByID(ctx context.Context, id int) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (s *MemoryStore) ListUsers(ctx context.Context, customerID int) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []User{}
+	for _, u := range s.users {
+		if u.CustomerID == customerID {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (s *MemoryStore) UpdateUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryStore) SaveRefreshToken(ctx context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh[token.ID] = *token
+	return nil
+}
+
+func (s *MemoryStore) RefreshToken(ctx context.Context, id string) (*RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.refresh[id]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &t, nil
+}
+
+func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[id]
+	if !ok || t.Revoked {
+		return ErrInvalidToken
+	}
+	t.Revoked = true
+	s.refresh[id] = t
+	return nil
+}
+
+func (s *MemoryStore) RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Попутно удаляем записи, срок действия которых уже истек
+	now := time.Now()
+	for jti, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, jti)
+		}
+	}
+
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) IsAccessTokenRevoked(ctx context.Context, id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revoked[id]
+	return ok, nil
+}
@@ -0,0 +1,192 @@
+// Package billing рассчитывает ежемесячные счета компаний за подключенные модули:
+// с пропорциональным расчетом при подключении или отключении модуля посреди цикла
+// и бесплатным пробным периодом для каждого модуля.
+package billing
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"kit8-backend/internal/core/registry"
+)
+
+// Статусы счета
+const (
+	InvoiceDraft = "draft" // Предварительный расчет текущего цикла, не сохраняется
+	InvoiceOpen  = "open"  // Выставлен и ожидает оплаты
+	InvoicePaid  = "paid"
+)
+
+// Currency - валюта тарифов платформы
+const Currency = "USD"
+
+// Invoice - счет компании за один биллинговый цикл
+type Invoice struct {
+	ID          int           `json:"id"`
+	CustomerID  int           `json:"customer_id"` // ID компании
+	PeriodStart string        `json:"period_start"`
+	PeriodEnd   string        `json:"period_end"`
+	Lines       []InvoiceLine `json:"lines"`
+	Total       float64       `json:"total"`
+	Currency    string        `json:"currency"`
+	Status      string        `json:"status"` // draft, open, paid
+	IssuedAt    string        `json:"issued_at"`
+}
+
+// InvoiceLine - начисление за один период подписки на модуль внутри цикла
+type InvoiceLine struct {
+	ID           int     `json:"id"`
+	Module       string  `json:"module"`
+	Description  string  `json:"description"`
+	PeriodStart  string  `json:"period_start"` // Начало использования модуля в цикле
+	PeriodEnd    string  `json:"period_end"`   // Конец использования модуля в цикле
+	TrialUntil   string  `json:"trial_until,omitempty"`
+	MonthlyPrice float64 `json:"monthly_price"`
+	Amount       float64 `json:"amount"`
+}
+
+// CycleStart возвращает начало биллингового цикла (календарного месяца UTC), содержащего t
+func CycleStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CycleEnd возвращает конец цикла, начинающегося в start
+func CycleEnd(start time.Time) time.Time {
+	return start.AddDate(0, 1, 0)
+}
+
+// period - интервал подписки [from, to); нулевое to означает действующую подписку
+type period struct {
+	sub  registry.Subscription
+	from time.Time
+	to   time.Time
+}
+
+// ComputeInvoice рассчитывает счет компании за цикл [start, end).
+// periods - вся история подписок компании, prices - каталог модулей,
+// trial - длительность пробного периода, отсчитываемого от первого подключения модуля.
+// Если в цикле компания не пользовалась ни одним модулем, возвращается nil.
+func ComputeInvoice(customerID int, subs []registry.Subscription, modules []registry.Info,
+	start, end time.Time, trial time.Duration) *Invoice {
+
+	catalog := make(map[string]registry.Info, len(modules))
+	for _, info := range modules {
+		catalog[info.Name] = info
+	}
+
+	// Пробный период предоставляется один раз: от самого раннего подключения модуля
+	firstEnabled := make(map[string]time.Time)
+	var periods []period
+	for _, sub := range subs {
+		if sub.CustomerID != customerID {
+			continue
+		}
+		p, ok := parsePeriod(sub)
+		if !ok {
+			continue
+		}
+		if first, seen := firstEnabled[sub.Module]; !seen || p.from.Before(first) {
+			firstEnabled[sub.Module] = p.from
+		}
+		periods = append(periods, p)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].from.Before(periods[j].from) })
+
+	cycle := end.Sub(start)
+	invoice := &Invoice{
+		CustomerID:  customerID,
+		PeriodStart: start.Format(time.RFC3339),
+		PeriodEnd:   end.Format(time.RFC3339),
+		Lines:       []InvoiceLine{},
+		Currency:    Currency,
+		Status:      InvoiceOpen,
+	}
+
+	var totalCents int64
+	for _, p := range periods {
+		from, to := maxTime(p.from, start), end
+		if !p.to.IsZero() {
+			to = minTime(p.to, end)
+		}
+		if !from.Before(to) {
+			continue
+		}
+
+		info, ok := catalog[p.sub.Module]
+		if !ok {
+			continue
+		}
+		priceCents := int64(math.Round(info.MonthlyPrice * 100))
+
+		line := InvoiceLine{
+			Module:       info.Name,
+			Description:  info.Title,
+			PeriodStart:  from.Format(time.RFC3339),
+			PeriodEnd:    to.Format(time.RFC3339),
+			MonthlyPrice: info.MonthlyPrice,
+		}
+
+		// Бесплатная часть периода, попавшая в пробный период
+		billableFrom := from
+		if trialEnd := firstEnabled[p.sub.Module].Add(trial); trial > 0 && trialEnd.After(from) {
+			line.TrialUntil = trialEnd.Format(time.RFC3339)
+			billableFrom = minTime(trialEnd, to)
+		}
+
+		cents := prorate(priceCents, to.Sub(billableFrom), cycle)
+		line.Amount = float64(cents) / 100
+		totalCents += cents
+		invoice.Lines = append(invoice.Lines, line)
+	}
+
+	if len(invoice.Lines) == 0 {
+		return nil
+	}
+	invoice.Total = float64(totalCents) / 100
+	return invoice
+}
+
+// prorate возвращает долю месячной цены за использованное время с округлением до цента
+func prorate(priceCents int64, used, cycle time.Duration) int64 {
+	if used <= 0 || cycle <= 0 {
+		return 0
+	}
+	if used >= cycle {
+		return priceCents
+	}
+	// Целочисленное округление половины вверх: (2*p*u + c) / (2*c), в секундах
+	u, c := int64(used/time.Second), int64(cycle/time.Second)
+	return (2*priceCents*u + c) / (2 * c)
+}
+
+func parsePeriod(sub registry.Subscription) (period, bool) {
+	from, err := time.Parse(time.RFC3339, sub.EnabledAt)
+	if err != nil {
+		return period{}, false
+	}
+	p := period{sub: sub, from: from.UTC()}
+	if sub.DisabledAt != "" {
+		to, err := time.Parse(time.RFC3339, sub.DisabledAt)
+		if err != nil {
+			return period{}, false
+		}
+		p.to = to.UTC()
+	}
+	return p, true
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,222 @@
+package billing
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/clock"
+	"kit8-backend/internal/core/registry"
+)
+
+// testModule - модуль каталога без маршрутов
+type testModule registry.Info
+
+func (m testModule) Info() registry.Info                { return registry.Info(m) }
+func (m testModule) RegisterRoutes(router fiber.Router) {}
+
+// testPeriods - неизменяемая история подписок
+type testPeriods []registry.Subscription
+
+func (p testPeriods) ListPeriods(ctx context.Context, until time.Time) ([]registry.Subscription, error) {
+	subs := []registry.Subscription{}
+	for _, sub := range p {
+		enabledAt, err := time.Parse(time.RFC3339, sub.EnabledAt)
+		if err == nil && enabledAt.Before(until) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// Модуль за 30 USD в месяц: в 30-дневном апреле 2026 день стоит ровно 1 USD
+var crmModule = registry.Info{Name: "crm", Title: "CRM", MonthlyPrice: 30}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func sub(customerID int, from, to time.Time) registry.Subscription {
+	s := registry.Subscription{CustomerID: customerID, Module: crmModule.Name, EnabledAt: from.Format(time.RFC3339)}
+	if !to.IsZero() {
+		s.DisabledAt = to.Format(time.RFC3339)
+	}
+	return s
+}
+
+func cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func TestComputeInvoice(t *testing.T) {
+	start, end := date(time.April, 1), date(time.May, 1)
+
+	tests := []struct {
+		name  string
+		subs  []registry.Subscription
+		trial time.Duration
+		want  int64 // Сумма счета в центах; -1 - счета нет
+	}{
+		{"full cycle", []registry.Subscription{sub(1, date(time.March, 1), time.Time{})}, 0, 3000},
+		{"enabled mid-cycle", []registry.Subscription{sub(1, date(time.April, 11), time.Time{})}, 0, 2000},
+		{"disabled mid-cycle", []registry.Subscription{sub(1, date(time.March, 1), date(time.April, 16))}, 0, 1500},
+		{"enabled and disabled in cycle", []registry.Subscription{sub(1, date(time.April, 5), date(time.April, 12))}, 0, 700},
+		{"disabled before cycle", []registry.Subscription{sub(1, date(time.March, 1), date(time.March, 20))}, 0, -1},
+		{"other company", []registry.Subscription{sub(2, date(time.March, 1), time.Time{})}, 0, -1},
+		{"trial inside cycle", []registry.Subscription{sub(1, date(time.April, 1), time.Time{})}, 14 * 24 * time.Hour, 1600},
+		{"trial from previous cycle", []registry.Subscription{sub(1, date(time.March, 25), time.Time{})}, 14 * 24 * time.Hour, 2300},
+		{"trial covers usage", []registry.Subscription{sub(1, date(time.April, 10), date(time.April, 20))}, 14 * 24 * time.Hour, 0},
+		{"trial ended earlier", []registry.Subscription{sub(1, date(time.January, 1), time.Time{})}, 14 * 24 * time.Hour, 3000},
+		{"trial is not granted again", []registry.Subscription{
+			sub(1, date(time.April, 1), date(time.April, 3)),
+			sub(1, date(time.April, 21), time.Time{}),
+		}, 14 * 24 * time.Hour, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := ComputeInvoice(1, tt.subs, []registry.Info{crmModule}, start, end, tt.trial)
+			if tt.want < 0 {
+				if invoice != nil {
+					t.Fatalf("unexpected invoice %+v", invoice)
+				}
+				return
+			}
+			if invoice == nil {
+				t.Fatal("no invoice")
+			}
+			var lines int64
+			for _, line := range invoice.Lines {
+				lines += cents(line.Amount)
+			}
+			if got := cents(invoice.Total); got != tt.want || lines != got {
+				t.Fatalf("total %d cents, lines %d cents, want %d", got, lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrialCutoffLine(t *testing.T) {
+	subs := []registry.Subscription{sub(1, date(time.March, 25), time.Time{})}
+	invoice := ComputeInvoice(1, subs, []registry.Info{crmModule}, date(time.April, 1), date(time.May, 1), 14*24*time.Hour)
+	if invoice == nil || len(invoice.Lines) != 1 {
+		t.Fatalf("unexpected invoice %+v", invoice)
+	}
+	line := invoice.Lines[0]
+	if line.TrialUntil != date(time.April, 8).Format(time.RFC3339) {
+		t.Fatalf("trial until %s, want April 8", line.TrialUntil)
+	}
+	if line.PeriodStart != date(time.April, 1).Format(time.RFC3339) || line.PeriodEnd != date(time.May, 1).Format(time.RFC3339) {
+		t.Fatalf("line period %s - %s", line.PeriodStart, line.PeriodEnd)
+	}
+}
+
+func TestProrateRounding(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		price       int64
+		used, cycle time.Duration
+		want        int64
+	}{
+		{1000, 10 * day, 30 * day, 333},
+		{1000, 20 * day, 30 * day, 667},
+		{1000, 45 * day, 30 * day, 1000},
+		{1000, 0, 30 * day, 0},
+		{1, 15 * day, 30 * day, 1}, // Половина цента округляется вверх
+	}
+	for _, tt := range tests {
+		if got := prorate(tt.price, tt.used, tt.cycle); got != tt.want {
+			t.Errorf("prorate(%d, %s, %s) = %d, want %d", tt.price, tt.used, tt.cycle, got, tt.want)
+		}
+	}
+}
+
+// testService создает сервис биллинга с часами, остановленными на now
+func testService(now time.Time, subs testPeriods, invoices InvoiceStore) *Service {
+	modules := registry.New()
+	modules.Register(testModule(crmModule))
+	return NewService(clock.Fixed(now), modules, subs, invoices, Config{})
+}
+
+func TestRunDueCatchesUpMissedCycles(t *testing.T) {
+	ctx := context.Background()
+	subs := testPeriods{
+		sub(1, date(time.February, 15), time.Time{}),
+		sub(2, date(time.April, 1), date(time.April, 16)),
+	}
+	invoices := NewMemoryInvoiceStore()
+
+	// Сервис впервые запущен в середине июня: закрываются циклы с февраля по май
+	issued, err := testService(date(time.June, 10), subs, invoices).RunDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issued) != 5 {
+		t.Fatalf("issued %d invoices, want 4 for company 1 and 1 for company 2", len(issued))
+	}
+	first, err := invoices.ListInvoices(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periods := make(map[string]bool)
+	for _, invoice := range first {
+		periods[invoice.PeriodStart] = true
+	}
+	for _, month := range []time.Month{time.February, time.March, time.April, time.May} {
+		if !periods[date(month, 1).Format(time.RFC3339)] {
+			t.Errorf("no invoice for %s", month)
+		}
+	}
+	if periods[date(time.June, 1).Format(time.RFC3339)] {
+		t.Error("invoice issued for the current cycle")
+	}
+
+	// Повторный запуск в том же цикле не дублирует счета
+	if issued, err := testService(date(time.June, 20), subs, invoices).RunDue(ctx); err != nil || len(issued) != 0 {
+		t.Fatalf("rerun issued %d invoices, err %v", len(issued), err)
+	}
+
+	// После двух пропущенных циклов закрываются июнь и июль
+	issued, err = testService(date(time.August, 3), subs, invoices).RunDue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issued) != 2 {
+		t.Fatalf("issued %d invoices, want June and July for company 1", len(issued))
+	}
+	for _, invoice := range issued {
+		if invoice.CustomerID != 1 || invoice.IssuedAt != date(time.August, 3).Format(time.RFC3339) {
+			t.Fatalf("unexpected invoice %+v", invoice)
+		}
+	}
+}
+
+func TestRunDueWithoutSubscriptions(t *testing.T) {
+	issued, err := testService(date(time.June, 10), nil, NewMemoryInvoiceStore()).RunDue(context.Background())
+	if err != nil || len(issued) != 0 {
+		t.Fatalf("issued %d invoices, err %v", len(issued), err)
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	subs := testPeriods{sub(1, date(time.April, 11), time.Time{})}
+	svc := testService(date(time.April, 20), subs, NewMemoryInvoiceStore())
+
+	invoice, err := svc.Upcoming(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != InvoiceDraft || cents(invoice.Total) != 2000 {
+		t.Fatalf("unexpected draft %+v", invoice)
+	}
+
+	empty, err := svc.Upcoming(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Status != InvoiceDraft || len(empty.Lines) != 0 || empty.Total != 0 {
+		t.Fatalf("unexpected draft %+v", empty)
+	}
+}
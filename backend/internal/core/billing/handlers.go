@@ -0,0 +1,74 @@
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// Контроллер биллинга
+type Controller struct {
+	service *Service
+}
+
+// NewController создает новый контроллер биллинга
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// GetInvoices возвращает выставленные счета текущей компании
+func (ctrl *Controller) GetInvoices(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	invoices, err := ctrl.service.Invoices(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(invoices)
+}
+
+// GetUpcomingInvoice возвращает предварительный счет за текущий цикл
+func (ctrl *Controller) GetUpcomingInvoice(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	invoice, err := ctrl.service.Upcoming(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(invoice)
+}
+
+// GetInvoice возвращает счет текущей компании по ID
+func (ctrl *Controller) GetInvoice(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid invoice ID"})
+	}
+
+	invoice, err := ctrl.service.Invoice(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Invoice not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(invoice)
+}
@@ -0,0 +1,157 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresInvoiceStore реализует InvoiceStore поверх таблиц invoices и invoice_lines
+type PostgresInvoiceStore struct {
+	db *sql.DB
+}
+
+// NewPostgresInvoiceStore создает хранилище счетов
+func NewPostgresInvoiceStore(db *sql.DB) *PostgresInvoiceStore {
+	return &PostgresInvoiceStore{db: db}
+}
+
+func (s *PostgresInvoiceStore) CreateInvoice(ctx context.Context, invoice *Invoice) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO invoices (customer_id, period_start, period_end, total, currency, status, issued_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		invoice.CustomerID, invoice.PeriodStart, invoice.PeriodEnd, invoice.Total, invoice.Currency,
+		invoice.Status, invoice.IssuedAt,
+	).Scan(&invoice.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrInvoiceExists
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO invoice_lines (invoice_id, module, description, period_start, period_end,
+			                            trial_until, monthly_price, amount)
+			 VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::timestamptz, $7, $8) RETURNING id`,
+			invoice.ID, line.Module, line.Description, line.PeriodStart, line.PeriodEnd,
+			line.TrialUntil, line.MonthlyPrice, line.Amount,
+		).Scan(&line.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const invoiceColumns = `id, customer_id, period_start, period_end, total, currency, status, issued_at`
+
+func scanInvoice(row interface{ Scan(...interface{}) error }) (*Invoice, error) {
+	var invoice Invoice
+	var periodStart, periodEnd, issuedAt time.Time
+	err := row.Scan(&invoice.ID, &invoice.CustomerID, &periodStart, &periodEnd, &invoice.Total,
+		&invoice.Currency, &invoice.Status, &issuedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	invoice.PeriodStart = database.FormatTime(periodStart)
+	invoice.PeriodEnd = database.FormatTime(periodEnd)
+	invoice.IssuedAt = database.FormatTime(issuedAt)
+	invoice.Lines = []InvoiceLine{}
+	return &invoice, nil
+}
+
+func (s *PostgresInvoiceStore) ListInvoices(ctx context.Context, customerID int) ([]Invoice, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE customer_id = $1 ORDER BY period_start, id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *invoice)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range invoices {
+		if invoices[i].Lines, err = s.lines(ctx, invoices[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return invoices, nil
+}
+
+func (s *PostgresInvoiceStore) LastCycle(ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+	if err := s.db.QueryRowContext(ctx, `SELECT max(period_start) FROM invoices`).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+	return last.Time.UTC(), nil
+}
+
+func (s *PostgresInvoiceStore) GetInvoice(ctx context.Context, customerID, id int) (*Invoice, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE customer_id = $1 AND id = $2`, customerID, id)
+	invoice, err := scanInvoice(row)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Lines, err = s.lines(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+func (s *PostgresInvoiceStore) lines(ctx context.Context, invoiceID int) ([]InvoiceLine, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, module, description, period_start, period_end, trial_until, monthly_price, amount
+		 FROM invoice_lines WHERE invoice_id = $1 ORDER BY id`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []InvoiceLine{}
+	for rows.Next() {
+		var line InvoiceLine
+		var periodStart, periodEnd time.Time
+		var trialUntil sql.NullTime
+		err := rows.Scan(&line.ID, &line.Module, &line.Description, &periodStart, &periodEnd,
+			&trialUntil, &line.MonthlyPrice, &line.Amount)
+		if err != nil {
+			return nil, err
+		}
+		line.PeriodStart = database.FormatTime(periodStart)
+		line.PeriodEnd = database.FormatTime(periodEnd)
+		line.TrialUntil = database.FormatNullTime(trialUntil)
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
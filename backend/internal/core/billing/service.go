@@ -0,0 +1,181 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"kit8-backend/internal/core/clock"
+	"kit8-backend/internal/core/registry"
+)
+
+// PeriodSource предоставляет историю подписок для расчета счетов
+type PeriodSource interface {
+	ListPeriods(ctx context.Context, until time.Time) ([]registry.Subscription, error)
+}
+
+// Config - настройки биллинга
+type Config struct {
+	TrialDays int // Длительность бесплатного пробного периода каждого модуля
+}
+
+// Service выставляет счета по завершенным циклам и показывает предварительный счет текущего
+type Service struct {
+	clock    clock.Clock
+	modules  *registry.Registry
+	periods  PeriodSource
+	invoices InvoiceStore
+	trial    time.Duration
+}
+
+// NewService создает сервис биллинга
+func NewService(clk clock.Clock, modules *registry.Registry, periods PeriodSource, invoices InvoiceStore, config Config) *Service {
+	return &Service{
+		clock:    clk,
+		modules:  modules,
+		periods:  periods,
+		invoices: invoices,
+		trial:    time.Duration(config.TrialDays) * 24 * time.Hour,
+	}
+}
+
+// CloseCycle выставляет счета всем компаниям за цикл, начинающийся в start.
+// Повторный вызов для того же цикла не создает дубликатов.
+func (s *Service) CloseCycle(ctx context.Context, start time.Time) ([]Invoice, error) {
+	start = CycleStart(start)
+	end := CycleEnd(start)
+
+	subs, err := s.periods.ListPeriods(ctx, end)
+	if err != nil {
+		return nil, err
+	}
+
+	customers := make(map[int]bool)
+	for _, sub := range subs {
+		customers[sub.CustomerID] = true
+	}
+
+	issued := []Invoice{}
+	for customerID := range customers {
+		invoice := ComputeInvoice(customerID, subs, s.modules.Modules(), start, end, s.trial)
+		if invoice == nil {
+			continue
+		}
+		invoice.IssuedAt = s.clock.Now().UTC().Format(time.RFC3339)
+
+		err := s.invoices.CreateInvoice(ctx, invoice)
+		if errors.Is(err, ErrInvoiceExists) {
+			continue
+		}
+		if err != nil {
+			return issued, err
+		}
+		issued = append(issued, *invoice)
+	}
+	return issued, nil
+}
+
+// RunDue выставляет счета за все завершенные циклы, начиная с последнего цикла со счетами,
+// а если счетов еще нет - с цикла первого подключения модуля. Так закрываются и циклы,
+// пропущенные, пока сервис не работал; уже выставленные счета не дублируются.
+func (s *Service) RunDue(ctx context.Context) ([]Invoice, error) {
+	previous := CycleStart(s.clock.Now()).AddDate(0, -1, 0)
+
+	start, err := s.invoices.LastCycle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if start.IsZero() {
+		if start, err = s.firstEnabled(ctx, CycleEnd(previous)); err != nil {
+			return nil, err
+		}
+	}
+
+	issued := []Invoice{}
+	if start.IsZero() {
+		return issued, nil
+	}
+	for cycle := CycleStart(start); !cycle.After(previous); cycle = CycleEnd(cycle) {
+		invoices, err := s.CloseCycle(ctx, cycle)
+		issued = append(issued, invoices...)
+		if err != nil {
+			return issued, err
+		}
+	}
+	return issued, nil
+}
+
+// firstEnabled возвращает время самого раннего подключения модуля до until;
+// нулевое, если подписок нет
+func (s *Service) firstEnabled(ctx context.Context, until time.Time) (time.Time, error) {
+	subs, err := s.periods.ListPeriods(ctx, until)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var first time.Time
+	for _, sub := range subs {
+		p, ok := parsePeriod(sub)
+		if ok && (first.IsZero() || p.from.Before(first)) {
+			first = p.from
+		}
+	}
+	return first, nil
+}
+
+// Upcoming рассчитывает предварительный счет компании за текущий цикл,
+// считая, что действующие подписки продлятся до его конца. Счет не сохраняется.
+func (s *Service) Upcoming(ctx context.Context, customerID int) (*Invoice, error) {
+	start := CycleStart(s.clock.Now())
+	end := CycleEnd(start)
+
+	subs, err := s.periods.ListPeriods(ctx, end)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := ComputeInvoice(customerID, subs, s.modules.Modules(), start, end, s.trial)
+	if invoice == nil {
+		invoice = &Invoice{
+			CustomerID:  customerID,
+			PeriodStart: start.Format(time.RFC3339),
+			PeriodEnd:   end.Format(time.RFC3339),
+			Lines:       []InvoiceLine{},
+			Currency:    Currency,
+		}
+	}
+	invoice.Status = InvoiceDraft
+	return invoice, nil
+}
+
+// Invoices возвращает выставленные счета компании
+func (s *Service) Invoices(ctx context.Context, customerID int) ([]Invoice, error) {
+	return s.invoices.ListInvoices(ctx, customerID)
+}
+
+// Invoice возвращает счет компании по ID
+func (s *Service) Invoice(ctx context.Context, customerID, id int) (*Invoice, error) {
+	return s.invoices.GetInvoice(ctx, customerID, id)
+}
+
+// Run периодически выставляет счета за завершенные циклы, пока ctx не отменен
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		issued, err := s.RunDue(ctx)
+		if err != nil {
+			log.Printf("billing: %v", err)
+		} else if len(issued) > 0 {
+			log.Printf("billing: issued %d invoices", len(issued))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
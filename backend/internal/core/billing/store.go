@@ -0,0 +1,108 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound возвращается, если счет не найден или принадлежит другой компании
+	ErrNotFound = errors.New("billing: invoice not found")
+
+	// ErrInvoiceExists возвращается, если счет компании за цикл уже выставлен
+	ErrInvoiceExists = errors.New("billing: invoice for this period already exists")
+)
+
+// InvoiceStore хранит выставленные счета
+type InvoiceStore interface {
+	// CreateInvoice сохраняет счет со строками; на компанию и цикл допускается один счет
+	CreateInvoice(ctx context.Context, invoice *Invoice) error
+	ListInvoices(ctx context.Context, customerID int) ([]Invoice, error)
+	GetInvoice(ctx context.Context, customerID, id int) (*Invoice, error)
+	// LastCycle возвращает начало последнего цикла, за который выставлен хотя бы один счет;
+	// нулевое время, если счетов еще нет
+	LastCycle(ctx context.Context) (time.Time, error)
+}
+
+// MemoryInvoiceStore - потокобезопасная реализация InvoiceStore в памяти процесса
+type MemoryInvoiceStore struct {
+	mu         sync.RWMutex
+	invoices   map[int]Invoice
+	nextID     int
+	nextLineID int
+}
+
+// NewMemoryInvoiceStore создает пустое хранилище счетов в памяти
+func NewMemoryInvoiceStore() *MemoryInvoiceStore {
+	return &MemoryInvoiceStore{invoices: make(map[int]Invoice)}
+}
+
+func (s *MemoryInvoiceStore) CreateInvoice(ctx context.Context, invoice *Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.invoices {
+		if existing.CustomerID == invoice.CustomerID && existing.PeriodStart == invoice.PeriodStart {
+			return ErrInvoiceExists
+		}
+	}
+
+	s.nextID++
+	invoice.ID = s.nextID
+	for i := range invoice.Lines {
+		s.nextLineID++
+		invoice.Lines[i].ID = s.nextLineID
+	}
+
+	stored := *invoice
+	stored.Lines = append([]InvoiceLine{}, invoice.Lines...)
+	s.invoices[invoice.ID] = stored
+	return nil
+}
+
+func (s *MemoryInvoiceStore) ListInvoices(ctx context.Context, customerID int) ([]Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invoices := []Invoice{}
+	for _, invoice := range s.invoices {
+		if invoice.CustomerID == customerID {
+			invoice.Lines = append([]InvoiceLine{}, invoice.Lines...)
+			invoices = append(invoices, invoice)
+		}
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].ID < invoices[j].ID })
+	return invoices, nil
+}
+
+func (s *MemoryInvoiceStore) GetInvoice(ctx context.Context, customerID, id int) (*Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invoice, ok := s.invoices[id]
+	if !ok || invoice.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	invoice.Lines = append([]InvoiceLine{}, invoice.Lines...)
+	return &invoice, nil
+}
+
+func (s *MemoryInvoiceStore) LastCycle(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var last time.Time
+	for _, invoice := range s.invoices {
+		start, err := time.Parse(time.RFC3339, invoice.PeriodStart)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	return last, nil
+}
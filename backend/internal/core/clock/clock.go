@@ -0,0 +1,28 @@
+// Package clock абстрагирует текущее время, чтобы расчеты, зависящие от даты
+// (биллинговые циклы, пробные периоды), можно было воспроизводить детерминированно.
+package clock
+
+import "time"
+
+// Clock возвращает текущее время
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Real возвращает системные часы
+func Real() Clock {
+	return realClock{}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// Fixed возвращает часы, всегда показывающие t
+func Fixed(t time.Time) Clock {
+	return fixedClock(t)
+}
@@ -0,0 +1,209 @@
+// Package currency хранит базовые валюты компаний и курсы валют, по которым
+// суммы модулей в разных валютах пересчитываются в базовую валюту компании.
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Поддерживаемые валюты
+const (
+	RUB = "RUB"
+	KZT = "KZT"
+	BYN = "BYN"
+	USD = "USD"
+	EUR = "EUR"
+)
+
+// Default - базовая валюта компании, пока она не выбрана
+const Default = RUB
+
+// supported - валюты в порядке отображения
+var supported = []string{RUB, KZT, BYN, USD, EUR}
+
+// Источники курсов
+const (
+	SourceManual = "manual" // Введен вручную
+	SourceImport = "import" // Загружен из файла
+)
+
+// DateLayout - формат даты курса
+const DateLayout = "2006-01-02"
+
+// rateScale - курсы хранятся с точностью до миллионных
+const rateScale = 1000000
+
+var (
+	// ErrNotFound возвращается, если курс или компания не найдены
+	ErrNotFound = errors.New("currency: not found")
+	// ErrInvalidCurrency возвращается, если валюта не поддерживается
+	ErrInvalidCurrency = errors.New("currency: unsupported currency")
+	// ErrInvalidRate возвращается, если курс заполнен неверно
+	ErrInvalidRate = errors.New("currency: invalid exchange rate")
+)
+
+// Valid сообщает, поддерживается ли валюта
+func Valid(code string) bool {
+	for _, c := range supported {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported возвращает поддерживаемые валюты
+func Supported() []string {
+	return append([]string{}, supported...)
+}
+
+// ExchangeRate - курс валют компании: 1 единица From стоит Rate единиц To.
+// Курс действует с даты Date до даты следующего курса той же пары.
+type ExchangeRate struct {
+	ID         int     `json:"id"`
+	CustomerID int     `json:"customer_id"` // ID компании
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Rate       float64 `json:"rate"`       // До шести знаков после запятой
+	Date       string  `json:"date"`       // YYYY-MM-DD
+	Source     string  `json:"source"`     // manual, import
+	CreatedBy  int     `json:"created_by"` // ID пользователя; 0 - запрос по API-ключу
+	CreatedAt  string  `json:"created_at"`
+}
+
+// Validate проверяет курс
+func (r *ExchangeRate) Validate() error {
+	switch {
+	case !Valid(r.From) || !Valid(r.To):
+		return fmt.Errorf("%w: unsupported currency", ErrInvalidRate)
+	case r.From == r.To:
+		return fmt.Errorf("%w: currencies must differ", ErrInvalidRate)
+	case r.micro() <= 0:
+		return fmt.Errorf("%w: rate must be positive", ErrInvalidRate)
+	}
+	if _, err := time.Parse(DateLayout, r.Date); err != nil {
+		return fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidRate)
+	}
+	return nil
+}
+
+// micro возвращает курс в миллионных долях
+func (r *ExchangeRate) micro() int64 {
+	return int64(math.Round(r.Rate * rateScale))
+}
+
+// NoRateError возвращается, если для пересчета между валютами нет ни одного курса
+type NoRateError struct {
+	From, To string
+}
+
+func (e *NoRateError) Error() string {
+	return fmt.Sprintf("currency: no exchange rate from %s to %s", e.From, e.To)
+}
+
+// Resolve возвращает валюту записи компании: code, если он указан, иначе базовую валюту.
+// Неподдерживаемая валюта отклоняется с ErrInvalidCurrency.
+func Resolve(ctx context.Context, currencies Currencies, customerID int, code string) (string, error) {
+	if code == "" {
+		return currencies.BaseCurrency(ctx, customerID)
+	}
+	if !Valid(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// Currencies - валюты компании, которые используют модули: базовая валюта
+// для новых записей и пересчет сумм в нее для статистики
+type Currencies interface {
+	// BaseCurrency возвращает базовую валюту компании
+	BaseCurrency(ctx context.Context, customerID int) (string, error)
+	// Converter возвращает пересчет в базовую валюту компании по всем ее курсам
+	Converter(ctx context.Context, customerID int) (*Converter, error)
+}
+
+type pair struct{ from, to string }
+
+// Converter пересчитывает суммы в базовую валюту компании по загруженным курсам.
+// Используется для одного запроса: курсы, добавленные позже, не учитываются.
+type Converter struct {
+	base  string
+	rates map[pair][]ExchangeRate // По возрастанию даты
+}
+
+// NewConverter создает пересчет в валюту base по курсам rates
+func NewConverter(base string, rates []ExchangeRate) *Converter {
+	c := &Converter{base: base, rates: make(map[pair][]ExchangeRate)}
+	for _, r := range rates {
+		key := pair{r.From, r.To}
+		c.rates[key] = append(c.rates[key], r)
+	}
+	for _, list := range c.rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	}
+	return c
+}
+
+// Base возвращает базовую валюту
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// ToBase пересчитывает amount в базовую валюту по курсу на момент on.
+// Сумма без валюты считается суммой в базовой валюте.
+func (c *Converter) ToBase(amount money.Money, on time.Time) (money.Money, error) {
+	return c.Convert(amount, c.base, on)
+}
+
+// Convert пересчитывает amount в валюту to по курсу на момент on: берется последний
+// курс пары, действующий на эту дату, а если курсов до нее нет - самый ранний.
+// Если прямого курса нет, используется обратный.
+func (c *Converter) Convert(amount money.Money, to string, on time.Time) (money.Money, error) {
+	from := amount.Currency()
+	if from == "" {
+		from = c.base
+	}
+	if from == to {
+		return amount.In(to), nil
+	}
+
+	date := on.UTC().Format(DateLayout)
+	if r, ok := pick(c.rates[pair{from, to}], date); ok {
+		return amount.MulRat(r.micro(), rateScale).In(to), nil
+	}
+	if r, ok := pick(c.rates[pair{to, from}], date); ok {
+		return amount.MulRat(rateScale, r.micro()).In(to), nil
+	}
+	return money.Money{}, &NoRateError{From: from, To: to}
+}
+
+// pick выбирает курс, действующий на дату date
+func pick(rates []ExchangeRate, date string) (ExchangeRate, bool) {
+	if len(rates) == 0 {
+		return ExchangeRate{}, false
+	}
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date > date })
+	if i == 0 {
+		return rates[0], true
+	}
+	return rates[i-1], true
+}
+
+// At возвращает момент операции для выбора курса по ее дате в формате RFC3339
+// или YYYY-MM-DD; пустая или неверная дата означает текущий момент
+func At(date string) time.Time {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t
+	}
+	if t, err := time.Parse(DateLayout, date); err == nil {
+		return t
+	}
+	return time.Now()
+}
@@ -0,0 +1,190 @@
+package currency
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/auth"
+	"kit8-backend/internal/core/tenant"
+)
+
+// Settings - валютные настройки компании
+type Settings struct {
+	BaseCurrency string   `json:"base_currency"`
+	Supported    []string `json:"supported"`
+}
+
+// Контроллер валют
+type Controller struct {
+	service *Service
+}
+
+// NewController создает новый контроллер валют
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// GetSettings возвращает базовую валюту компании и поддерживаемые валюты
+func (ctrl *Controller) GetSettings(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	base, err := ctrl.service.BaseCurrency(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(Settings{BaseCurrency: base, Supported: Supported()})
+}
+
+// UpdateSettings меняет базовую валюту компании
+func (ctrl *Controller) UpdateSettings(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var req Settings
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	err = ctrl.service.SetBaseCurrency(c.UserContext(), customerID, req.BaseCurrency)
+	switch {
+	case errors.Is(err, ErrInvalidCurrency):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Company not found"})
+	case err != nil:
+		return err
+	}
+	return c.JSON(Settings{BaseCurrency: req.BaseCurrency, Supported: Supported()})
+}
+
+// GetRates возвращает курсы валют компании
+func (ctrl *Controller) GetRates(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	rates, err := ctrl.service.Rates(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(rates)
+}
+
+// CreateRate добавляет курс, введенный вручную. Курс той же пары на ту же дату заменяется.
+func (ctrl *Controller) CreateRate(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var rate ExchangeRate
+	if err := c.BodyParser(&rate); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	rates := []ExchangeRate{rate}
+	err = ctrl.service.AddRates(c.UserContext(), customerID, rates, SourceManual, createdBy(c))
+	if errors.Is(err, ErrInvalidRate) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": rateError(err)})
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(rates[0])
+}
+
+// ImportRates загружает курсы из CSV-файла: поле формы file или тело запроса text/csv.
+// Файл загружается целиком или не загружается вовсе.
+func (ctrl *Controller) ImportRates(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var file io.Reader = bytes.NewReader(c.Body())
+	if header, err := c.FormFile("file"); err == nil {
+		f, err := header.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	rates, err := ParseRates(file)
+	var importErr *ImportError
+	if errors.As(err, &importErr) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid rates file",
+			"line":   importErr.Line,
+			"reason": importErr.Reason,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ctrl.service.AddRates(c.UserContext(), customerID, rates, SourceImport, createdBy(c)); err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"imported": len(rates), "rates": rates})
+}
+
+// DeleteRate удаляет курс
+func (ctrl *Controller) DeleteRate(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rate ID"})
+	}
+
+	err = ctrl.service.DeleteRate(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Exchange rate not found"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusOK)
+}
+
+// NoRate отвечает модулям на ошибку пересчета в базовую валюту, если курса нет
+func NoRate(c *fiber.Ctx, err *NoRateError) error {
+	return c.Status(http.StatusConflict).JSON(fiber.Map{
+		"error": "No exchange rate from " + err.From + " to " + err.To,
+		"from":  err.From,
+		"to":    err.To,
+	})
+}
+
+// createdBy возвращает ID пользователя запроса; 0 - запрос по API-ключу
+func createdBy(c *fiber.Ctx) int {
+	if claims, ok := auth.CurrentClaims(c); ok {
+		return claims.UserID()
+	}
+	return 0
+}
+
+// rateError возвращает причину ошибки курса без префикса пакета
+func rateError(err error) string {
+	reason := err.Error()
+	if prefix := ErrInvalidRate.Error() + ": "; len(reason) > len(prefix) {
+		return "Invalid exchange rate: " + reason[len(prefix):]
+	}
+	return "Invalid exchange rate"
+}
@@ -0,0 +1,97 @@
+package currency
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ImportError описывает ошибку в строке файла курсов
+type ImportError struct {
+	Line   int // Номер строки файла, начиная с 1
+	Reason string
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("currency: line %d: %s", e.Line, e.Reason)
+}
+
+// ParseRates читает курсы из CSV-файла с заголовком date,from,to,rate, например:
+//
+//	date,from,to,rate
+//	2024-03-01,USD,RUB,91.2345
+//
+// Разделителем может быть запятая или точка с запятой; с точкой с запятой
+// курс можно записать с десятичной запятой, как при выгрузке из Excel.
+func ParseRates(r io.Reader) ([]ExchangeRate, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	reader := csv.NewReader(br)
+	if strings.Contains(strings.SplitN(string(header), "\n", 2)[0], ";") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &ImportError{Line: parseErr.Line, Reason: parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, &ImportError{Line: 1, Reason: "file is empty"}
+	}
+
+	// Колонки могут идти в любом порядке
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"date", "from", "to", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, &ImportError{Line: 1, Reason: "header must contain date, from, to and rate"}
+		}
+	}
+
+	rates := []ExchangeRate{}
+	for i, record := range records[1:] {
+		line := i + 2
+		field := func(name string) string {
+			if idx := columns[name]; idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		var value float64
+		if _, err := fmt.Sscan(strings.Replace(field("rate"), ",", ".", 1), &value); err != nil {
+			return nil, &ImportError{Line: line, Reason: fmt.Sprintf("invalid rate %q", field("rate"))}
+		}
+		rate := ExchangeRate{
+			From: strings.ToUpper(field("from")),
+			To:   strings.ToUpper(field("to")),
+			Rate: value,
+			Date: field("date"),
+		}
+		if err := rate.Validate(); err != nil {
+			return nil, &ImportError{Line: line, Reason: strings.TrimPrefix(err.Error(), ErrInvalidRate.Error()+": ")}
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return nil, &ImportError{Line: 2, Reason: "file has no rates"}
+	}
+	return rates, nil
+}
@@ -0,0 +1,117 @@
+package currency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresStore реализует Store поверх колонки tenants.base_currency и таблицы exchange_rates
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore создает хранилище валют
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) BaseCurrency(ctx context.Context, customerID int) (string, error) {
+	var code string
+	err := database.Conn(ctx, s.db).QueryRowContext(ctx,
+		`SELECT base_currency FROM tenants WHERE id = $1`, customerID).Scan(&code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Default, nil
+	}
+	return code, err
+}
+
+func (s *PostgresStore) SetBaseCurrency(ctx context.Context, customerID int, code string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tenants SET base_currency = $2, updated_at = now() WHERE id = $1`, customerID, code)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const rateColumns = `id, customer_id, from_currency, to_currency, rate, rate_date, source, COALESCE(created_by, 0), created_at`
+
+func scanRate(row interface{ Scan(...interface{}) error }) (*ExchangeRate, error) {
+	var r ExchangeRate
+	var date, createdAt time.Time
+	err := row.Scan(&r.ID, &r.CustomerID, &r.From, &r.To, &r.Rate, &date, &r.Source, &r.CreatedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	r.Date = date.Format(DateLayout)
+	r.CreatedAt = database.FormatTime(createdAt)
+	return &r, nil
+}
+
+func (s *PostgresStore) ListRates(ctx context.Context, customerID int) ([]ExchangeRate, error) {
+	rows, err := database.Conn(ctx, s.db).QueryContext(ctx,
+		`SELECT `+rateColumns+` FROM exchange_rates WHERE customer_id = $1
+		 ORDER BY from_currency, to_currency, rate_date`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		r, err := scanRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *r)
+	}
+	return rates, rows.Err()
+}
+
+func (s *PostgresStore) SaveRates(ctx context.Context, rates []ExchangeRate) error {
+	return database.InTx(ctx, s.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, s.db)
+		for i := range rates {
+			r := &rates[i]
+			saved, err := scanRate(q.QueryRowContext(ctx,
+				`INSERT INTO exchange_rates (customer_id, from_currency, to_currency, rate, rate_date, source, created_by)
+				 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
+				 ON CONFLICT (customer_id, from_currency, to_currency, rate_date) DO UPDATE
+				 SET rate = EXCLUDED.rate, source = EXCLUDED.source, created_by = EXCLUDED.created_by, created_at = now()
+				 RETURNING `+rateColumns,
+				r.CustomerID, r.From, r.To, r.Rate, r.Date, r.Source, r.CreatedBy))
+			if err != nil {
+				return err
+			}
+			*r = *saved
+		}
+		return nil
+	})
+}
+
+func (s *PostgresStore) DeleteRate(ctx context.Context, customerID, id int) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM exchange_rates WHERE customer_id = $1 AND id = $2`, customerID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package currency
+
+import "context"
+
+// Service управляет валютами компаний и реализует Currencies для модулей
+type Service struct {
+	store Store
+}
+
+// NewService создает сервис валют
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// BaseCurrency возвращает базовую валюту компании
+func (s *Service) BaseCurrency(ctx context.Context, customerID int) (string, error) {
+	return s.store.BaseCurrency(ctx, customerID)
+}
+
+// SetBaseCurrency меняет базовую валюту компании. Суммы уже созданных записей
+// остаются в своих валютах и пересчитываются в новую базовую валюту по курсам.
+func (s *Service) SetBaseCurrency(ctx context.Context, customerID int, code string) error {
+	if !Valid(code) {
+		return ErrInvalidCurrency
+	}
+	return s.store.SetBaseCurrency(ctx, customerID, code)
+}
+
+// Rates возвращает курсы компании
+func (s *Service) Rates(ctx context.Context, customerID int) ([]ExchangeRate, error) {
+	return s.store.ListRates(ctx, customerID)
+}
+
+// AddRates проверяет и сохраняет курсы компании из источника source.
+// Если хотя бы один курс неверен, не сохраняется ни один.
+func (s *Service) AddRates(ctx context.Context, customerID int, rates []ExchangeRate, source string, createdBy int) error {
+	for i := range rates {
+		rates[i].CustomerID = customerID
+		rates[i].Source = source
+		rates[i].CreatedBy = createdBy
+		if err := rates[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return s.store.SaveRates(ctx, rates)
+}
+
+// DeleteRate удаляет курс компании
+func (s *Service) DeleteRate(ctx context.Context, customerID, id int) error {
+	return s.store.DeleteRate(ctx, customerID, id)
+}
+
+// Converter возвращает пересчет в базовую валюту компании по всем ее курсам
+func (s *Service) Converter(ctx context.Context, customerID int) (*Converter, error) {
+	base, err := s.store.BaseCurrency(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.store.ListRates(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return NewConverter(base, rates), nil
+}
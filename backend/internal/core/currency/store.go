@@ -0,0 +1,114 @@
+package currency
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store хранит базовые валюты компаний и их курсы. Все методы ограничены компанией customerID.
+type Store interface {
+	// BaseCurrency возвращает базовую валюту компании; Default, если она не выбрана
+	BaseCurrency(ctx context.Context, customerID int) (string, error)
+	// SetBaseCurrency меняет базовую валюту компании; ErrNotFound, если компании нет
+	SetBaseCurrency(ctx context.Context, customerID int, code string) error
+	ListRates(ctx context.Context, customerID int) ([]ExchangeRate, error)
+	// SaveRates сохраняет курсы вместе: курс той же пары на ту же дату заменяется.
+	// ID и дата создания назначаются хранилищем.
+	SaveRates(ctx context.Context, rates []ExchangeRate) error
+	// DeleteRate удаляет курс; ErrNotFound, если его нет
+	DeleteRate(ctx context.Context, customerID, id int) error
+}
+
+// MemoryStore - потокобезопасная реализация Store в памяти процесса
+type MemoryStore struct {
+	mu     sync.RWMutex
+	bases  map[int]string
+	rates  map[int]ExchangeRate
+	nextID int
+}
+
+// NewMemoryStore создает пустое хранилище валют в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{bases: make(map[int]string), rates: make(map[int]ExchangeRate)}
+}
+
+func (s *MemoryStore) BaseCurrency(ctx context.Context, customerID int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if code, ok := s.bases[customerID]; ok {
+		return code, nil
+	}
+	return Default, nil
+}
+
+func (s *MemoryStore) SetBaseCurrency(ctx context.Context, customerID int, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bases[customerID] = code
+	return nil
+}
+
+func (s *MemoryStore) ListRates(ctx context.Context, customerID int) ([]ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rates := []ExchangeRate{}
+	for _, r := range s.rates {
+		if r.CustomerID == customerID {
+			rates = append(rates, r)
+		}
+	}
+	sortRates(rates)
+	return rates, nil
+}
+
+func (s *MemoryStore) SaveRates(ctx context.Context, rates []ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range rates {
+		r := &rates[i]
+		for id, existing := range s.rates {
+			if existing.CustomerID == r.CustomerID && existing.From == r.From &&
+				existing.To == r.To && existing.Date == r.Date {
+				delete(s.rates, id)
+			}
+		}
+		s.nextID++
+		r.ID = s.nextID
+		r.CreatedAt = now
+		s.rates[r.ID] = *r
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteRate(ctx context.Context, customerID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.rates[id]
+	if !ok || r.CustomerID != customerID {
+		return ErrNotFound
+	}
+	delete(s.rates, id)
+	return nil
+}
+
+// sortRates упорядочивает курсы по паре валют и дате, как PostgreSQL
+func sortRates(rates []ExchangeRate) {
+	sort.Slice(rates, func(i, j int) bool {
+		a, b := rates[i], rates[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Date < b.Date
+	})
+}
@@ -0,0 +1,330 @@
+// Package money описывает денежные суммы без ошибок округления float64: сумма
+// хранится целым числом минимальных единиц валюты (копеек, центов) вместе с кодом
+// валюты. В JSON и SQL сумма передается десятичным числом, как прежние поля float64.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Exponent - число десятичных знаков в сумме. Все валюты платформы (RUB, KZT,
+// BYN, USD, EUR) делятся на сотые доли.
+const Exponent = 2
+
+// scale - число минимальных единиц в единице валюты
+const scale = 100
+
+// ErrInvalid возвращается, если строка не является десятичной суммой
+var ErrInvalid = errors.New("money: invalid amount")
+
+// Money - денежная сумма. Нулевое значение - ноль без указания валюты.
+// Пустой код валюты означает валюту компании и совместим с любой валютой.
+//
+// Add, Sub, Cmp и основанные на них функции паникуют на суммах в разных валютах.
+// Суммы из тела запроса и из базы данных читаются без валюты и совместимы с любыми;
+// валюту им назначает код (New, In) после проверки валюты заказа, платежа или смены.
+// Поэтому разные валюты в одной операции - ошибка программы, а не данных запроса.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New создает сумму из минимальных единиц валюты: New(15050, "RUB") - 150,50 ₽
+func New(minor int64, currency string) Money {
+	return Money{minor: minor, currency: normalize(currency)}
+}
+
+// FromFloat создает сумму из float64, округляя до копеек (половина - от нуля)
+func FromFloat(amount float64, currency string) Money {
+	return New(int64(math.Round(amount*scale)), currency)
+}
+
+// Parse разбирает десятичную запись суммы ("150.5", "-0,01", "1e3") без потери точности.
+// Знаки после второго округляются по правилу половина - от нуля.
+func Parse(s, currency string) (Money, error) {
+	minor, err := parseMinor(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(minor, currency), nil
+}
+
+// MustParse - Parse, паникующий при ошибке. Для констант в коде.
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Minor возвращает сумму в минимальных единицах валюты
+func (m Money) Minor() int64 { return m.minor }
+
+// Currency возвращает код валюты; пустая строка - валюта компании
+func (m Money) Currency() string { return m.currency }
+
+// In возвращает ту же сумму в валюте currency (без пересчета по курсу)
+func (m Money) In(currency string) Money {
+	return New(m.minor, currency)
+}
+
+// Float64 возвращает сумму как float64. Только для отображения и статистики.
+func (m Money) Float64() float64 {
+	return float64(m.minor) / scale
+}
+
+// IsZero сообщает, равна ли сумма нулю
+func (m Money) IsZero() bool { return m.minor == 0 }
+
+// IsPositive сообщает, больше ли сумма нуля
+func (m Money) IsPositive() bool { return m.minor > 0 }
+
+// IsNegative сообщает, меньше ли сумма нуля
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Add возвращает m + o
+func (m Money) Add(o Money) Money {
+	return Money{minor: m.minor + o.minor, currency: m.common(o)}
+}
+
+// Sub возвращает m - o
+func (m Money) Sub(o Money) Money {
+	return Money{minor: m.minor - o.minor, currency: m.common(o)}
+}
+
+// Neg возвращает -m
+func (m Money) Neg() Money {
+	return Money{minor: -m.minor, currency: m.currency}
+}
+
+// Mul возвращает сумму, умноженную на целое число (цена позиции на количество)
+func (m Money) Mul(n int64) Money {
+	return Money{minor: m.minor * n, currency: m.currency}
+}
+
+// MulRat возвращает m * num / den с округлением до копеек (половина - от нуля).
+// Используется для процентов и долей: MulRat(20, 120) - НДС 20% в сумме с налогом.
+// При неположительном den возвращается ноль: доля от пустого целого пуста.
+func (m Money) MulRat(num, den int64) Money {
+	if den <= 0 {
+		return Money{currency: m.currency}
+	}
+	// Произведение может не поместиться в int64: сумма в копейках на курс в миллионных долях
+	product := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(num))
+	return Money{minor: divRound(product, den), currency: m.currency}
+}
+
+// Div делит сумму на n частей с округлением до копеек (средний чек).
+// При неположительном n возвращается ноль.
+func (m Money) Div(n int64) Money {
+	return m.MulRat(1, n)
+}
+
+// Cmp сравнивает суммы: -1, если m < o; 0, если равны; 1, если m > o
+func (m Money) Cmp(o Money) int {
+	m.common(o)
+	switch {
+	case m.minor < o.minor:
+		return -1
+	case m.minor > o.minor:
+		return 1
+	}
+	return 0
+}
+
+// Equal сообщает, равны ли суммы
+func (m Money) Equal(o Money) bool { return m.Cmp(o) == 0 }
+
+// Min возвращает меньшую из сумм
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// Max возвращает большую из сумм
+func Max(a, b Money) Money {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// Sum складывает суммы
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+// Decimal возвращает десятичную запись суммы без лишних нулей: "150.5", "-0.01", "100"
+func (m Money) Decimal() string {
+	s := m.Fixed()
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Fixed возвращает десятичную запись суммы с двумя знаками: "150.50"
+func (m Money) Fixed() string {
+	minor := m.minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/scale, minor%scale)
+}
+
+// String возвращает сумму с кодом валюты: "150.50 RUB"
+func (m Money) String() string {
+	if m.currency == "" {
+		return m.Fixed()
+	}
+	return m.Fixed() + " " + m.currency
+}
+
+// MarshalJSON записывает сумму числом, как прежние поля float64
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON принимает число или строку с числом; null - ноль
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*m = Money{currency: m.currency}
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	minor, err := parseMinor(s)
+	if err != nil {
+		return err
+	}
+	m.minor = minor
+	return nil
+}
+
+// Scan читает сумму из колонки NUMERIC
+func (m *Money) Scan(src interface{}) error {
+	var minor int64
+	var err error
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		minor, err = parseMinor(string(v))
+	case string:
+		minor, err = parseMinor(v)
+	case int64:
+		minor = v * scale
+	case float64:
+		minor = int64(math.Round(v * scale))
+	default:
+		err = fmt.Errorf("money: cannot scan %T", src)
+	}
+	if err != nil {
+		return err
+	}
+	m.minor = minor
+	return nil
+}
+
+// Value записывает сумму в колонку NUMERIC десятичной строкой
+func (m Money) Value() (driver.Value, error) {
+	return m.Fixed(), nil
+}
+
+// common возвращает валюту результата операции над m и o.
+// Суммы в разных валютах складывать нельзя - это ошибка программы (см. Money).
+func (m Money) common(o Money) string {
+	switch {
+	case m.currency == "":
+		return o.currency
+	case o.currency == "" || o.currency == m.currency:
+		return m.currency
+	}
+	panic(fmt.Sprintf("money: currency mismatch: %s and %s", m.currency, o.currency))
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// parseMinor переводит десятичную запись в минимальные единицы
+func parseMinor(s string) (int64, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	if s == "" {
+		return 0, ErrInvalid
+	}
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, ErrInvalid
+		}
+		mantissa, exp = s[:i], e
+	}
+	negative := false
+	switch {
+	case strings.HasPrefix(mantissa, "-"):
+		negative = true
+		mantissa = mantissa[1:]
+	case strings.HasPrefix(mantissa, "+"):
+		mantissa = mantissa[1:]
+	}
+	whole, frac := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, frac = mantissa[:i], mantissa[i+1:]
+	}
+	digits := whole + frac
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, ErrInvalid
+	}
+	// Сдвигаем запятую так, чтобы целая часть была в минимальных единицах
+	point := len(whole) + exp + Exponent
+	digits = strings.TrimLeft(digits, "0")
+	point -= len(whole+frac) - len(digits)
+	if point > 18 {
+		return 0, ErrInvalid
+	}
+	var minor int64
+	for i := 0; i < point; i++ {
+		minor *= 10
+		if i < len(digits) {
+			minor += int64(digits[i] - '0')
+		}
+	}
+	// Первая отброшенная цифра определяет округление
+	if point >= 0 && point < len(digits) && digits[point] >= '5' {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// divRound делит a на положительное b с округлением половины от нуля
+func divRound(a *big.Int, b int64) int64 {
+	d := big.NewInt(b)
+	q, r := new(big.Int).QuoRem(a, d, new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(d) >= 0 {
+		if a.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
@@ -0,0 +1,137 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"150.5", 15050},
+		{"-0,01", -1},
+		{"1e3", 100000},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"0.0049", 0},
+		{"12", 1200},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.in, "rub")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if m.Minor() != tt.want || m.Currency() != "RUB" {
+			t.Errorf("Parse(%q) = %d %s, want %d RUB", tt.in, m.Minor(), m.Currency(), tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1.2.3", "1e", "1e30"} {
+		if _, err := Parse(in, "RUB"); err == nil {
+			t.Errorf("Parse(%q): expected error", in)
+		}
+	}
+}
+
+func TestMulRat(t *testing.T) {
+	tests := []struct {
+		name     string
+		minor    int64
+		num, den int64
+		want     int64
+	}{
+		{"vat in gross", 12000, 20, 120, 2000},
+		{"half rounds up", 1, 1, 2, 1},
+		{"negative half rounds away from zero", -1, 1, 2, -1},
+		{"below half rounds down", 4, 1, 10, 0},
+		{"product overflows int64", math.MaxInt64 / 2, 1_000_000, 1_000_000, math.MaxInt64 / 2},
+		{"zero denominator", 1000, 1, 0, 0},
+		{"negative denominator", 1000, 1, -2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.minor, "USD").MulRat(tt.num, tt.den)
+			if got.Minor() != tt.want || got.Currency() != "USD" {
+				t.Fatalf("MulRat = %s, want %d USD minor", got, tt.want)
+			}
+		})
+	}
+
+	if got := New(1000, "RUB").Div(3); got.Minor() != 333 {
+		t.Fatalf("Div(3) = %s", got)
+	}
+	if got := New(1000, "RUB").Div(0); !got.IsZero() {
+		t.Fatalf("Div(0) = %s, want zero", got)
+	}
+}
+
+func TestCurrencyCompatibility(t *testing.T) {
+	rub := New(1000, "RUB")
+	bare := New(500, "")
+
+	// Сумма без валюты совместима с любой и принимает ее валюту
+	if got := rub.Add(bare); got.Minor() != 1500 || got.Currency() != "RUB" {
+		t.Fatalf("RUB + bare = %s", got)
+	}
+	if got := bare.Sub(rub); got.Minor() != -500 || got.Currency() != "RUB" {
+		t.Fatalf("bare - RUB = %s", got)
+	}
+	if rub.Cmp(bare) != 1 {
+		t.Fatal("RUB 10.00 must be greater than 5.00")
+	}
+	if got := Sum(bare, rub, New(1, "rub")); got.Minor() != 1501 || got.Currency() != "RUB" {
+		t.Fatalf("Sum = %s", got)
+	}
+}
+
+func TestCurrencyMismatchPanics(t *testing.T) {
+	rub, usd := New(100, "RUB"), New(100, "USD")
+	ops := map[string]func(){
+		"Add": func() { rub.Add(usd) },
+		"Sub": func() { rub.Sub(usd) },
+		"Cmp": func() { rub.Cmp(usd) },
+		"Min": func() { Min(rub, usd) },
+		"Sum": func() { Sum(rub, usd) },
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic on currency mismatch")
+				}
+			}()
+			op()
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var body struct {
+		Amount Money  `json:"amount"`
+		Tip    Money  `json:"tip"`
+		None   *Money `json:"none"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 150.5, "tip": "0.1", "none": null}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	// Суммы из запроса читаются без валюты
+	if body.Amount.Minor() != 15050 || body.Amount.Currency() != "" || body.Tip.Minor() != 10 || body.None != nil {
+		t.Fatalf("unexpected body %+v", body)
+	}
+
+	out, err := json.Marshal(New(15050, "RUB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "150.5" {
+		t.Fatalf("Marshal = %s, want 150.5", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": "abc"}`), &body); err == nil {
+		t.Fatal("expected error for invalid amount")
+	}
+}
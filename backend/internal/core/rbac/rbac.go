@@ -0,0 +1,138 @@
+// Package rbac описывает роли пользователей внутри компании и права,
+// необходимые для вызова маршрутов модулей.
+package rbac
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// Роли пользователей
+const (
+	RoleOwner     = "owner"     // Владелец: полный доступ
+	RoleManager   = "manager"   // Руководитель: продажи, склад и заказы без движения остатков и возвратов
+	RoleSales     = "sales"     // Продавец: клиенты, сделки и заказы
+	RoleCashier   = "cashier"   // Кассир: платежи и возвраты
+	RoleWarehouse = "warehouse" // Кладовщик: товары, остатки и отгрузка заказов
+)
+
+// Permission - право на группу операций
+type Permission string
+
+// Права доступа
+const (
+	ContactsRead    Permission = "contacts:read"
+	ContactsWrite   Permission = "contacts:write"
+	DealsRead       Permission = "deals:read"
+	DealsWrite      Permission = "deals:write"
+	PipelinesManage Permission = "pipelines:manage" // Воронки сделок и их стадии
+	ProductsRead    Permission = "products:read"
+	ProductsWrite   Permission = "products:write"
+	StockWrite      Permission = "stock:write" // Изменение количества товара
+	OrdersRead      Permission = "orders:read"
+	OrdersWrite     Permission = "orders:write"
+	OrdersDelete    Permission = "orders:delete"
+	OrdersFulfill   Permission = "orders:fulfill" // Сборка, отгрузка и доставка заказа
+	CouponsWrite    Permission = "coupons:write"  // Купоны и промокоды
+	PaymentsRead    Permission = "payments:read"
+	PaymentsWrite   Permission = "payments:write"
+	PaymentsRefund  Permission = "payments:refund"
+	BillingRead     Permission = "billing:read"
+	CurrencyRead    Permission = "currency:read"   // Базовая валюта компании и курсы валют
+	RatesWrite      Permission = "rates:write"     // Ввод и загрузка курсов валют
+	CurrencyManage  Permission = "currency:manage" // Смена базовой валюты компании
+	TaxRead         Permission = "tax:read"
+	TaxManage       Permission = "tax:manage" // Режим цен и налоговая категория компании
+	ModulesRead     Permission = "modules:read"
+	ModulesManage   Permission = "modules:manage"
+	UsersManage     Permission = "users:manage"
+)
+
+// localsKey - ключ, под которым роль пользователя хранится в контексте запроса
+const localsKey = "role"
+
+// ErrForbidden возвращается, если у роли вызывающего нет нужного права
+var ErrForbidden = fiber.NewError(fiber.StatusForbidden, "Your role does not allow this action")
+
+// rolePermissions - права каждой роли. Владелец имеет все права и здесь не указан.
+var rolePermissions = map[string][]Permission{
+	RoleManager: {
+		ContactsRead, ContactsWrite, DealsRead, DealsWrite, PipelinesManage,
+		ProductsRead, ProductsWrite,
+		OrdersRead, OrdersWrite, OrdersDelete, OrdersFulfill, CouponsWrite,
+		PaymentsRead, BillingRead, RatesWrite,
+		CurrencyRead, TaxRead, ModulesRead,
+	},
+	RoleSales: {
+		ContactsRead, ContactsWrite, DealsRead, DealsWrite,
+		ProductsRead,
+		OrdersRead, OrdersWrite,
+		CurrencyRead, TaxRead, ModulesRead,
+	},
+	RoleCashier: {
+		ContactsRead, ProductsRead, OrdersRead,
+		PaymentsRead, PaymentsWrite, PaymentsRefund,
+		CurrencyRead, TaxRead, ModulesRead,
+	},
+	RoleWarehouse: {
+		ProductsRead, ProductsWrite, StockWrite,
+		OrdersRead, OrdersFulfill,
+		CurrencyRead, TaxRead, ModulesRead,
+	},
+}
+
+// ValidRole сообщает, существует ли роль
+func ValidRole(role string) bool {
+	if role == RoleOwner {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Allowed сообщает, есть ли у роли право p
+func Allowed(role string, p Permission) bool {
+	if role == RoleOwner {
+		return true
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRole сохраняет роль вызывающего в контексте запроса
+func SetRole(c *fiber.Ctx, role string) {
+	c.Locals(localsKey, role)
+}
+
+// Role возвращает роль вызывающего; пустая строка, если роль не определена
+func Role(c *fiber.Ctx) string {
+	role, _ := c.Locals(localsKey).(string)
+	return role
+}
+
+// Can сообщает, есть ли у вызывающего право p
+func Can(c *fiber.Ctx, p Permission) bool {
+	return Allowed(Role(c), p)
+}
+
+// Require пропускает запрос, только если у вызывающего есть все перечисленные права.
+// Запрос без роли (например, определенный только по поддомену) отклоняется с 401.
+func Require(permissions ...Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role := Role(c)
+		if role == "" {
+			return tenant.ErrUnauthorized
+		}
+		for _, p := range permissions {
+			if !Allowed(role, p) {
+				return ErrForbidden
+			}
+		}
+		return c.Next()
+	}
+}
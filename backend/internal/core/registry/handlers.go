@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// ModuleStatus - модуль каталога с состоянием подписки текущей компании
+type ModuleStatus struct {
+	Info
+	Subscription *Subscription `json:"subscription"` // nil, если модуль не подключен
+}
+
+// Контроллер каталога модулей
+type Controller struct {
+	registry      *Registry
+	subscriptions SubscriptionStore
+}
+
+// NewController создает новый контроллер каталога модулей
+func NewController(registry *Registry, subscriptions SubscriptionStore) *Controller {
+	return &Controller{registry: registry, subscriptions: subscriptions}
+}
+
+// GetModules возвращает каталог модулей с подписками текущей компании
+func (ctrl *Controller) GetModules(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	subs, err := ctrl.subscriptions.ListSubscriptions(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	byModule := make(map[string]Subscription, len(subs))
+	for _, sub := range subs {
+		byModule[sub.Module] = sub
+	}
+
+	modules := []ModuleStatus{}
+	for _, info := range ctrl.registry.Modules() {
+		status := ModuleStatus{Info: info}
+		if sub, ok := byModule[info.Name]; ok {
+			status.Subscription = &sub
+		}
+		modules = append(modules, status)
+	}
+
+	return c.JSON(modules)
+}
+
+// EnableModule подключает модуль текущей компании
+func (ctrl *Controller) EnableModule(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Params("name")
+	if _, ok := ctrl.registry.Lookup(name); !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Module not found"})
+	}
+
+	sub, err := ctrl.subscriptions.EnableModule(c.UserContext(), customerID, name)
+	if errors.Is(err, ErrAlreadySubscribed) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Module is already enabled"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(sub)
+}
+
+// DisableModule отключает модуль текущей компании
+func (ctrl *Controller) DisableModule(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.Params("name")
+	if _, ok := ctrl.registry.Lookup(name); !ok {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Module not found"})
+	}
+
+	err = ctrl.subscriptions.DisableModule(c.UserContext(), customerID, name)
+	if errors.Is(err, ErrNotSubscribed) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Module is not enabled"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresSubscriptionStore реализует SubscriptionStore поверх таблицы tenant_modules
+type PostgresSubscriptionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresSubscriptionStore создает хранилище подписок
+func NewPostgresSubscriptionStore(db *sql.DB) *PostgresSubscriptionStore {
+	return &PostgresSubscriptionStore{db: db}
+}
+
+const subscriptionColumns = `id, customer_id, module, status, enabled_at, disabled_at`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*Subscription, error) {
+	var sub Subscription
+	var enabledAt time.Time
+	var disabledAt sql.NullTime
+	err := row.Scan(&sub.ID, &sub.CustomerID, &sub.Module, &sub.Status, &enabledAt, &disabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotSubscribed
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub.EnabledAt = database.FormatTime(enabledAt)
+	sub.DisabledAt = database.FormatNullTime(disabledAt)
+	return &sub, nil
+}
+
+func (s *PostgresSubscriptionStore) ListSubscriptions(ctx context.Context, customerID int) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM tenant_modules
+		 WHERE customer_id = $1 AND disabled_at IS NULL ORDER BY module`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *PostgresSubscriptionStore) GetSubscription(ctx context.Context, customerID int, module string) (*Subscription, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM tenant_modules
+		 WHERE customer_id = $1 AND module = $2 AND disabled_at IS NULL`, customerID, module)
+	return scanSubscription(row)
+}
+
+func (s *PostgresSubscriptionStore) EnableModule(ctx context.Context, customerID int, module string) (*Subscription, error) {
+	row := s.db.QueryRowContext(ctx,
+		`INSERT INTO tenant_modules (customer_id, module, status) VALUES ($1, $2, $3)
+		 RETURNING `+subscriptionColumns, customerID, module, StatusActive)
+	sub, err := scanSubscription(row)
+
+	// Уникальный индекс допускает только одну действующую подписку на модуль
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrAlreadySubscribed
+	}
+	return sub, err
+}
+
+func (s *PostgresSubscriptionStore) DisableModule(ctx context.Context, customerID int, module string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tenant_modules SET disabled_at = now(), updated_at = now()
+		 WHERE customer_id = $1 AND module = $2 AND disabled_at IS NULL`, customerID, module)
+	return checkSubscribed(res, err)
+}
+
+func (s *PostgresSubscriptionStore) SetStatus(ctx context.Context, customerID int, module, status string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tenant_modules SET status = $3, updated_at = now()
+		 WHERE customer_id = $1 AND module = $2 AND disabled_at IS NULL`, customerID, module, status)
+	return checkSubscribed(res, err)
+}
+
+func (s *PostgresSubscriptionStore) ListPeriods(ctx context.Context, until time.Time) ([]Subscription, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM tenant_modules WHERE enabled_at < $1 ORDER BY id`, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+func checkSubscribed(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotSubscribed
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package registry хранит каталог модулей платформы и подключает их маршруты
+// так, чтобы компания получала доступ только к модулям, на которые подписана.
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Info - описание модуля в каталоге
+type Info struct {
+	Name         string  `json:"name"` // Используется в URL: /api/{name}
+	Title        string  `json:"title"`
+	Description  string  `json:"description"`
+	MonthlyPrice float64 `json:"monthly_price"` // Стоимость подписки в месяц, USD
+}
+
+// Module - подключаемый модуль платформы
+type Module interface {
+	Info() Info
+	RegisterRoutes(router fiber.Router)
+}
+
+// Registry - каталог зарегистрированных модулей
+type Registry struct {
+	modules map[string]Module
+}
+
+// New создает пустой каталог
+func New() *Registry {
+	return &Registry{modules: make(map[string]Module)}
+}
+
+// Register добавляет модуль в каталог. Повторная регистрация имени - ошибка программиста.
+func (r *Registry) Register(m Module) {
+	name := m.Info().Name
+	if name == "" {
+		panic("registry: module without name")
+	}
+	if _, ok := r.modules[name]; ok {
+		panic(fmt.Sprintf("registry: module %q registered twice", name))
+	}
+	r.modules[name] = m
+}
+
+// Lookup возвращает описание модуля по имени
+func (r *Registry) Lookup(name string) (Info, bool) {
+	m, ok := r.modules[name]
+	if !ok {
+		return Info{}, false
+	}
+	return m.Info(), true
+}
+
+// Modules возвращает описания всех модулей, упорядоченные по имени
+func (r *Registry) Modules() []Info {
+	infos := make([]Info, 0, len(r.modules))
+	for _, m := range r.modules {
+		infos = append(infos, m.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Mount подключает маршруты каждого модуля в группу /{name},
+// закрытую проверкой подписки компании на этот модуль
+func (r *Registry) Mount(router fiber.Router, subscriptions SubscriptionStore) {
+	for _, info := range r.Modules() {
+		group := router.Group("/"+info.Name, RequireModule(subscriptions, info.Name))
+		r.modules[info.Name].RegisterRoutes(group)
+	}
+}
@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// Статусы подписки на модуль
+const (
+	StatusActive    = "active"    // Модуль доступен
+	StatusSuspended = "suspended" // Подписка есть, но доступ временно закрыт
+)
+
+var (
+	// ErrNotSubscribed возвращается, если у компании нет действующей подписки на модуль
+	ErrNotSubscribed = errors.New("registry: module is not subscribed")
+
+	// ErrAlreadySubscribed возвращается при повторном подключении модуля
+	ErrAlreadySubscribed = errors.New("registry: module is already subscribed")
+)
+
+// Subscription - период подписки компании на модуль.
+// Подписка действует, пока DisabledAt пуст; повторное подключение создает новый период.
+type Subscription struct {
+	ID         int    `json:"id"`
+	CustomerID int    `json:"customer_id"` // ID компании
+	Module     string `json:"module"`
+	Status     string `json:"status"` // active, suspended
+	EnabledAt  string `json:"enabled_at"`
+	DisabledAt string `json:"disabled_at,omitempty"`
+}
+
+// SubscriptionStore хранит подписки компаний на модули (таблица tenant_modules)
+type SubscriptionStore interface {
+	// ListSubscriptions возвращает действующие подписки компании
+	ListSubscriptions(ctx context.Context, customerID int) ([]Subscription, error)
+	// GetSubscription возвращает действующую подписку или ErrNotSubscribed
+	GetSubscription(ctx context.Context, customerID int, module string) (*Subscription, error)
+	EnableModule(ctx context.Context, customerID int, module string) (*Subscription, error)
+	DisableModule(ctx context.Context, customerID int, module string) error
+	SetStatus(ctx context.Context, customerID int, module, status string) error
+	// ListPeriods возвращает все периоды подписок всех компаний, начавшиеся до until,
+	// включая завершенные. Используется биллингом.
+	ListPeriods(ctx context.Context, until time.Time) ([]Subscription, error)
+}
+
+// RequireModule пропускает запрос, только если компания подписана на модуль.
+// Без подписки возвращается 402 Payment Required, при приостановленной подписке - 403.
+func RequireModule(subscriptions SubscriptionStore, module string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerID, err := tenant.CustomerID(c)
+		if err != nil {
+			return err
+		}
+
+		sub, err := subscriptions.GetSubscription(c.UserContext(), customerID, module)
+		if errors.Is(err, ErrNotSubscribed) {
+			return fiber.NewError(fiber.StatusPaymentRequired, "Module "+module+" is not included in your subscription")
+		}
+		if err != nil {
+			return err
+		}
+		if sub.Status != StatusActive {
+			return fiber.NewError(fiber.StatusForbidden, "Module "+module+" is suspended for your company")
+		}
+
+		return c.Next()
+	}
+}
+
+// MemorySubscriptionStore - потокобезопасная реализация SubscriptionStore в памяти процесса
+type MemorySubscriptionStore struct {
+	mu     sync.RWMutex
+	subs   []Subscription
+	nextID int
+}
+
+// NewMemorySubscriptionStore создает пустое хранилище подписок в памяти
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{}
+}
+
+// current возвращает индекс действующей подписки или -1
+func (s *MemorySubscriptionStore) current(customerID int, module string) int {
+	for i, sub := range s.subs {
+		if sub.CustomerID == customerID && sub.Module == module && sub.DisabledAt == "" {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemorySubscriptionStore) ListSubscriptions(ctx context.Context, customerID int) ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := []Subscription{}
+	for _, sub := range s.subs {
+		if sub.CustomerID == customerID && sub.DisabledAt == "" {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Module < subs[j].Module })
+	return subs, nil
+}
+
+func (s *MemorySubscriptionStore) GetSubscription(ctx context.Context, customerID int, module string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.current(customerID, module)
+	if i < 0 {
+		return nil, ErrNotSubscribed
+	}
+	sub := s.subs[i]
+	return &sub, nil
+}
+
+func (s *MemorySubscriptionStore) EnableModule(ctx context.Context, customerID int, module string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current(customerID, module) >= 0 {
+		return nil, ErrAlreadySubscribed
+	}
+
+	s.nextID++
+	sub := Subscription{
+		ID:         s.nextID,
+		CustomerID: customerID,
+		Module:     module,
+		Status:     StatusActive,
+		EnabledAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	s.subs = append(s.subs, sub)
+	return &sub, nil
+}
+
+func (s *MemorySubscriptionStore) DisableModule(ctx context.Context, customerID int, module string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.current(customerID, module)
+	if i < 0 {
+		return ErrNotSubscribed
+	}
+	s.subs[i].DisabledAt = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+func (s *MemorySubscriptionStore) SetStatus(ctx context.Context, customerID int, module, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.current(customerID, module)
+	if i < 0 {
+		return ErrNotSubscribed
+	}
+	s.subs[i].Status = status
+	return nil
+}
+
+func (s *MemorySubscriptionStore) ListPeriods(ctx context.Context, until time.Time) ([]Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := []Subscription{}
+	for _, sub := range s.subs {
+		enabledAt, err := time.Parse(time.RFC3339, sub.EnabledAt)
+		if err == nil && enabledAt.Before(until) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
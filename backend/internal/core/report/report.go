@@ -0,0 +1,220 @@
+// Package report задает период отчетов модулей: диапазон дат from/to из запроса
+// и группировку записей по дням, неделям или месяцам для временных рядов.
+package report
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DateLayout - формат дат from и to и начала периода в рядах
+const DateLayout = "2006-01-02"
+
+// Группировки временных рядов
+const (
+	GroupDay   = "day"
+	GroupWeek  = "week" // Неделя начинается в понедельник
+	GroupMonth = "month"
+)
+
+var (
+	// ErrInvalidDate возвращается, если from или to не в формате YYYY-MM-DD
+	ErrInvalidDate = errors.New("report: dates must be in YYYY-MM-DD format")
+	// ErrInvalidRange возвращается, если from позже to
+	ErrInvalidRange = errors.New("report: from is after to")
+	// ErrInvalidGroup возвращается при неизвестной группировке
+	ErrInvalidGroup = errors.New("report: group must be day, week or month")
+	// ErrRangeTooLong возвращается, если период длиннее допустимого для группировки
+	ErrRangeTooLong = errors.New("report: range is too long for the group")
+)
+
+// Range - период отчета в UTC и группировка его временных рядов
+type Range struct {
+	From  time.Time // Начало первого дня включительно; нулевое - без ограничения
+	To    time.Time // Начало дня после последнего; нулевое - без ограничения
+	Group string    // day, week, month
+}
+
+// Period - период отчета в ответе API
+type Period struct {
+	From  string `json:"from"`  // Первый день периода; пусто - без ограничения
+	To    string `json:"to"`    // Последний день периода; пусто - без ограничения
+	Group string `json:"group"` // day, week, month
+}
+
+// FromQuery читает период из параметров запроса from и to (YYYY-MM-DD, оба дня
+// включительно) и group (по умолчанию month). Период ограничен 366 днями
+// при группировке по дням, 5 годами - по неделям и 10 годами - по месяцам.
+func FromQuery(c *fiber.Ctx) (Range, error) {
+	r := Range{Group: c.Query("group", GroupMonth)}
+	if r.Group != GroupDay && r.Group != GroupWeek && r.Group != GroupMonth {
+		return Range{}, ErrInvalidGroup
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(DateLayout, from)
+		if err != nil {
+			return Range{}, ErrInvalidDate
+		}
+		r.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(DateLayout, to)
+		if err != nil {
+			return Range{}, ErrInvalidDate
+		}
+		r.To = t.AddDate(0, 0, 1)
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return Range{}, ErrInvalidRange
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && r.From.Before(r.earliest(r.To)) {
+		return Range{}, ErrRangeTooLong
+	}
+	return r, nil
+}
+
+// Invalid отвечает 400 на ошибку FromQuery
+func Invalid(c *fiber.Ctx, err error) error {
+	reason := "Invalid report period"
+	switch {
+	case errors.Is(err, ErrInvalidDate):
+		reason = "Dates from and to must be in YYYY-MM-DD format"
+	case errors.Is(err, ErrInvalidRange):
+		reason = "Date from is after date to"
+	case errors.Is(err, ErrInvalidGroup):
+		reason = "Group must be day, week or month"
+	case errors.Is(err, ErrRangeTooLong):
+		reason = "Period is too long: up to 366 days by day, 5 years by week and 10 years by month"
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": reason})
+}
+
+// Period возвращает период для ответа API: первый и последний день (YYYY-MM-DD) и группировку
+func (r Range) Period() Period {
+	p := Period{Group: r.Group}
+	if !r.From.IsZero() {
+		p.From = r.From.Format(DateLayout)
+	}
+	if !r.To.IsZero() {
+		p.To = r.To.AddDate(0, 0, -1).Format(DateLayout)
+	}
+	return p
+}
+
+// Contains сообщает, попадает ли момент t в период
+func (r Range) Contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+// ContainsDate сообщает, попадает ли в период момент в формате RFC3339 или день YYYY-MM-DD
+func (r Range) ContainsDate(date string) bool {
+	t, ok := Parse(date)
+	return ok && r.Contains(t)
+}
+
+// Bucket возвращает начало группы, в которую попадает момент t
+func (r Range) Bucket(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch r.Group {
+	case GroupWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case GroupMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// earliest возвращает самое раннее начало периода, оканчивающегося в to,
+// допустимое для группировки
+func (r Range) earliest(to time.Time) time.Time {
+	switch r.Group {
+	case GroupWeek:
+		return to.AddDate(-5, 0, 0)
+	case GroupMonth:
+		return to.AddDate(-10, 0, 0)
+	}
+	return to.AddDate(0, 0, -366)
+}
+
+// next возвращает начало группы, следующей за группой bucket
+func (r Range) next(bucket time.Time) time.Time {
+	switch r.Group {
+	case GroupWeek:
+		return bucket.AddDate(0, 0, 7)
+	case GroupMonth:
+		return bucket.AddDate(0, 1, 0)
+	}
+	return bucket.AddDate(0, 0, 1)
+}
+
+// Buckets возвращает начала групп периода без пропусков в формате YYYY-MM-DD.
+// Открытые границы периода заменяются первым и последним моментом данных first и last;
+// если период открыт, а данных нет (нулевые first и last), групп нет.
+// Период длиннее допустимого для группировки укорачивается до последних групп.
+func (r Range) Buckets(first, last time.Time) []string {
+	from, to := r.From, r.To
+	if from.IsZero() {
+		from = first
+	}
+	if to.IsZero() {
+		if last.IsZero() {
+			return []string{}
+		}
+		to = last.Add(time.Nanosecond)
+	}
+	buckets := []string{}
+	if from.IsZero() {
+		return buckets
+	}
+	if earliest := r.earliest(to); from.Before(earliest) {
+		from = earliest
+	}
+	for b := r.Bucket(from); b.Before(to); b = r.next(b) {
+		buckets = append(buckets, b.Format(DateLayout))
+	}
+	return buckets
+}
+
+// Label возвращает начало группы момента t в формате YYYY-MM-DD, как в Buckets
+func (r Range) Label(t time.Time) string {
+	return r.Bucket(t).Format(DateLayout)
+}
+
+// Bounds - первый и последний момент данных отчета для Range.Buckets
+type Bounds struct {
+	First time.Time
+	Last  time.Time
+}
+
+// Add расширяет границы до момента t
+func (b *Bounds) Add(t time.Time) {
+	if b.First.IsZero() || t.Before(b.First) {
+		b.First = t
+	}
+	if t.After(b.Last) {
+		b.Last = t
+	}
+}
+
+// Parse разбирает момент в формате RFC3339 или день YYYY-MM-DD
+func Parse(date string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(DateLayout, date); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Percent возвращает долю part от whole в процентах с точностью до сотых; 0, если whole = 0
+func Percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(whole)) / 100
+}
@@ -0,0 +1,59 @@
+// Package sales связывает сделки CRM с заказами: выигранная сделка превращается
+// в заказ модуля Заказов с товарами сделки.
+package sales
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/money"
+)
+
+// ErrDealConverted возвращается, если по сделке уже создан заказ
+var ErrDealConverted = errors.New("sales: deal already converted to an order")
+
+// Line - товар сделки, который становится позицией заказа
+type Line struct {
+	ProductID   int
+	ProductName string
+	Quantity    int
+	Price       money.Money // Цена единицы в валюте сделки, в режиме цен компании
+}
+
+// Deal - выигранная сделка, по которой создается заказ
+type Deal struct {
+	ID        int
+	ContactID int    // Клиент заказа
+	Currency  string // Валюта заказа
+	Lines     []Line
+}
+
+// Orders - заказы, которые CRM создает по сделкам. Реализации на PostgreSQL
+// выполняют запросы в транзакции из контекста (database.WithTx), чтобы заказ
+// фиксировался вместе со ссылкой на него в сделке.
+type Orders interface {
+	// CreateOrder создает заказ по сделке и возвращает его ID. Налог позиций считается
+	// по правилам компании, товар проверяется по остаткам Склада: при нехватке возвращает
+	// *stock.InsufficientError. ErrDealConverted, если заказ по сделке уже есть.
+	CreateOrder(ctx context.Context, customerID int, deal Deal) (int, error)
+}
+
+// Deals - сделки, по которым созданы заказы. Хранилища заказов без внешних ключей
+// снимают через него ссылку сделки на удаленный заказ, как ON DELETE SET NULL в PostgreSQL.
+type Deals interface {
+	// UnlinkOrder снимает со сделки dealID ссылку на заказ orderID, если она указывает на него
+	UnlinkOrder(ctx context.Context, customerID, dealID, orderID int) error
+}
+
+// Contacts - контакты CRM, на которые ссылаются заказы. Хранилища заказов без общей базы
+// с CRM проверяют через него, что клиент заказа - контакт компании заказа.
+type Contacts interface {
+	// ContactExists сообщает, есть ли у компании customerID контакт id
+	ContactExists(ctx context.Context, customerID, id int) (bool, error)
+}
+
+// CRM - сделки и контакты CRM, связанные с заказами
+type CRM interface {
+	Deals
+	Contacts
+}
@@ -0,0 +1,67 @@
+// Package settlement связывает платежи Кассы с заказами: Касса сообщает итоги
+// оплаты заказа, а модуль Заказов пересчитывает по ним статус оплаты.
+package settlement
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/money"
+)
+
+// ErrOrderNotFound возвращается, если заказ не найден или принадлежит другой компании
+var ErrOrderNotFound = errors.New("settlement: order not found")
+
+// Статусы оплаты заказа
+const (
+	StatusUnpaid        = "unpaid"
+	StatusPending       = "pending"        // Есть платежи, ожидающие ответа провайдера
+	StatusPartiallyPaid = "partially_paid" // Получена часть суммы заказа
+	StatusPaid          = "paid"
+	StatusRefunded      = "refunded" // Все полученные средства возвращены
+)
+
+// Summary - итоги оплаты заказа по всем его платежам
+type Summary struct {
+	Paid     money.Money // Получено за вычетом возвратов
+	Refunded money.Money // Возвращено
+	Pending  bool        // Есть незавершенные платежи
+}
+
+// Status возвращает статус оплаты заказа на сумму total
+func (s Summary) Status(total money.Money) string {
+	switch {
+	case s.Paid.IsPositive() && s.Paid.Cmp(total) >= 0:
+		return StatusPaid
+	case s.Paid.IsPositive():
+		return StatusPartiallyPaid
+	case s.Pending:
+		return StatusPending
+	case s.Refunded.IsPositive():
+		return StatusRefunded
+	}
+	return StatusUnpaid
+}
+
+// Item - позиция заказа для кассового чека
+type Item struct {
+	Name        string
+	Quantity    int
+	Price       money.Money // Цена единицы с налогом
+	Total       money.Money // Сумма позиции с налогом
+	TaxCategory string      // Налоговая категория (tax.VAT20 и др.)
+	Tax         money.Money // Налог в сумме позиции
+}
+
+// Orders - заказы, оплату которых принимает Касса. Реализации на PostgreSQL
+// выполняют запросы в транзакции из контекста (database.WithTx), чтобы статус
+// оплаты заказа фиксировался вместе с платежом.
+type Orders interface {
+	// OrderTotal возвращает сумму заказа в его валюте. В транзакции из контекста заказ блокируется
+	// до ее завершения, чтобы параллельные платежи не превысили сумму заказа.
+	OrderTotal(ctx context.Context, customerID, orderID int) (money.Money, error)
+	// ApplyPayments пересчитывает статус оплаты и оплаченную сумму заказа по итогам его платежей
+	ApplyPayments(ctx context.Context, customerID, orderID int, summary Summary) error
+	// OrderItems возвращает позиции заказа для кассового чека
+	OrderItems(ctx context.Context, customerID, orderID int) ([]Item, error)
+}
@@ -0,0 +1,67 @@
+// Package stock описывает складские операции, которые модули выполняют
+// над товарами Склада: резервирование, списание и снятие резерва.
+package stock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Line - количество товара, с которым выполняется операция
+type Line struct {
+	ProductID int
+	Quantity  int
+}
+
+// Shortage - нехватка товара: запрошено больше, чем доступно
+type Shortage struct {
+	ProductID int `json:"product_id"`
+	Requested int `json:"requested"`
+	Available int `json:"available"` // 0, если товар не найден
+}
+
+// InsufficientError возвращается, если товара не хватает хотя бы по одной строке.
+// В этом случае операция не меняет ни одного товара.
+type InsufficientError struct {
+	Shortages []Shortage
+}
+
+func (e *InsufficientError) Error() string {
+	return fmt.Sprintf("stock: insufficient stock for %d product(s)", len(e.Shortages))
+}
+
+// Stock - складские операции над товарами компании customerID.
+// Каждая операция применяется ко всем строкам или не применяется вовсе.
+// Реализации на PostgreSQL выполняют операции в транзакции из контекста
+// (database.WithTx), чтобы они фиксировались вместе с изменениями вызывающего.
+type Stock interface {
+	// Shortages возвращает строки, для которых доступного (не зарезервированного) товара недостаточно
+	Shortages(ctx context.Context, customerID int, lines []Line) ([]Shortage, error)
+	// Reserve резервирует товар; при нехватке возвращает *InsufficientError
+	Reserve(ctx context.Context, customerID int, lines []Line) error
+	// Release снимает резерв
+	Release(ctx context.Context, customerID int, lines []Line) error
+	// Deduct списывает зарезервированный товар со склада; при нехватке возвращает *InsufficientError
+	Deduct(ctx context.Context, customerID int, lines []Line) error
+}
+
+// Merge суммирует строки одного товара и отбрасывает строки без товара
+// или с неположительным количеством. Результат упорядочен по ID товара,
+// чтобы параллельные операции блокировали товары в одном порядке.
+func Merge(lines []Line) []Line {
+	totals := make(map[int]int)
+	for _, l := range lines {
+		if l.ProductID == 0 || l.Quantity <= 0 {
+			continue
+		}
+		totals[l.ProductID] += l.Quantity
+	}
+
+	merged := make([]Line, 0, len(totals))
+	for id, qty := range totals {
+		merged = append(merged, Line{ProductID: id, Quantity: qty})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ProductID < merged[j].ProductID })
+	return merged
+}
@@ -0,0 +1,67 @@
+package tax
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/tenant"
+)
+
+// Info - налоговые настройки компании вместе с доступными категориями
+type Info struct {
+	Settings
+	Categories []Category `json:"categories"`
+}
+
+// Контроллер налоговых настроек
+type Controller struct {
+	service *Service
+}
+
+// NewController создает новый контроллер налоговых настроек
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// GetSettings возвращает налоговые настройки компании и налоговые категории
+func (ctrl *Controller) GetSettings(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := ctrl.service.Settings(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(Info{Settings: settings, Categories: Categories()})
+}
+
+// UpdateSettings меняет налоговые настройки компании. Поля, которых нет в запросе, не меняются.
+func (ctrl *Controller) UpdateSettings(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	settings, err := ctrl.service.Settings(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	err = ctrl.service.UpdateSettings(c.UserContext(), customerID, settings)
+	switch {
+	case errors.Is(err, ErrInvalidCategory):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tax category"})
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Company not found"})
+	case err != nil:
+		return err
+	}
+	return c.JSON(Info{Settings: settings, Categories: Categories()})
+}
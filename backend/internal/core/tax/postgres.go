@@ -0,0 +1,47 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresStore реализует Store поверх колонок налоговых настроек таблицы tenants
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore создает хранилище налоговых настроек
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Settings(ctx context.Context, customerID int) (Settings, error) {
+	var settings Settings
+	err := database.Conn(ctx, s.db).QueryRowContext(ctx,
+		`SELECT prices_include_tax, default_tax_category FROM tenants WHERE id = $1`, customerID,
+	).Scan(&settings.PricesIncludeTax, &settings.DefaultCategory)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultSettings(), nil
+	}
+	return settings, err
+}
+
+func (s *PostgresStore) SaveSettings(ctx context.Context, customerID int, settings Settings) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE tenants SET prices_include_tax = $2, default_tax_category = $3, updated_at = now() WHERE id = $1`,
+		customerID, settings.PricesIncludeTax, settings.DefaultCategory)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
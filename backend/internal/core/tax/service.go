@@ -0,0 +1,27 @@
+package tax
+
+import "context"
+
+// Service управляет налоговыми настройками компаний и реализует Rules для модулей
+type Service struct {
+	store Store
+}
+
+// NewService создает сервис налоговых настроек
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// Settings возвращает налоговые настройки компании
+func (s *Service) Settings(ctx context.Context, customerID int) (Settings, error) {
+	return s.store.Settings(ctx, customerID)
+}
+
+// UpdateSettings проверяет и сохраняет налоговые настройки компании.
+// Режим цен применяется к новым заказам: созданные заказы сохраняют свой расчет.
+func (s *Service) UpdateSettings(ctx context.Context, customerID int, settings Settings) error {
+	if !Valid(settings.DefaultCategory) {
+		return ErrInvalidCategory
+	}
+	return s.store.SaveSettings(ctx, customerID, settings)
+}
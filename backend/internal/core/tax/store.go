@@ -0,0 +1,43 @@
+package tax
+
+import (
+	"context"
+	"sync"
+)
+
+// Store хранит налоговые настройки компаний
+type Store interface {
+	// Settings возвращает настройки компании; DefaultSettings, если они не менялись
+	Settings(ctx context.Context, customerID int) (Settings, error)
+	// SaveSettings сохраняет настройки компании; ErrNotFound, если компании нет
+	SaveSettings(ctx context.Context, customerID int, settings Settings) error
+}
+
+// MemoryStore - потокобезопасная реализация Store в памяти процесса
+type MemoryStore struct {
+	mu       sync.RWMutex
+	settings map[int]Settings
+}
+
+// NewMemoryStore создает пустое хранилище налоговых настроек в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{settings: make(map[int]Settings)}
+}
+
+func (s *MemoryStore) Settings(ctx context.Context, customerID int) (Settings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if settings, ok := s.settings[customerID]; ok {
+		return settings, nil
+	}
+	return DefaultSettings(), nil
+}
+
+func (s *MemoryStore) SaveSettings(ctx context.Context, customerID int, settings Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[customerID] = settings
+	return nil
+}
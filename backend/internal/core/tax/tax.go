@@ -0,0 +1,193 @@
+// Package tax рассчитывает НДС по налоговым категориям товаров с учетом режима
+// цен компании: цены включают налог или налог начисляется сверху.
+package tax
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Налоговые категории. Коды совпадают со ставками НДС позиций чека по 54-ФЗ.
+const (
+	VAT20  = "vat20" // НДС 20%
+	VAT10  = "vat10" // НДС 10%
+	VAT0   = "vat0"  // НДС 0%
+	Exempt = "none"  // Без НДС
+)
+
+// categories - категории в порядке отображения и их ставки в процентах
+var categories = []Category{
+	{Code: VAT20, Rate: 20},
+	{Code: VAT10, Rate: 10},
+	{Code: VAT0, Rate: 0},
+	{Code: Exempt, Rate: 0},
+}
+
+var (
+	// ErrNotFound возвращается, если компания не найдена
+	ErrNotFound = errors.New("tax: company not found")
+	// ErrInvalidCategory возвращается, если налоговая категория неизвестна
+	ErrInvalidCategory = errors.New("tax: unknown tax category")
+)
+
+// Category - налоговая категория и ее ставка
+type Category struct {
+	Code string `json:"code"`
+	Rate int64  `json:"rate"` // Ставка в процентах
+}
+
+// Categories возвращает налоговые категории
+func Categories() []Category {
+	return append([]Category{}, categories...)
+}
+
+// Valid сообщает, известна ли налоговая категория
+func Valid(code string) bool {
+	for _, c := range categories {
+		if c.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Rate возвращает ставку категории в процентах; 0 для неизвестной категории
+func Rate(code string) int64 {
+	for _, c := range categories {
+		if c.Code == code {
+			return c.Rate
+		}
+	}
+	return 0
+}
+
+// Settings - налоговые настройки компании
+type Settings struct {
+	PricesIncludeTax bool   `json:"prices_include_tax"` // Цены товаров и позиций включают налог
+	DefaultCategory  string `json:"default_category"`   // Категория товаров и платежей, для которых она не указана
+}
+
+// DefaultSettings - настройки компании, пока они не изменены: цены с налогом, без НДС
+func DefaultSettings() Settings {
+	return Settings{PricesIncludeTax: true, DefaultCategory: Exempt}
+}
+
+// Category возвращает категорию записи: code, если он указан, иначе категорию по умолчанию.
+// Неизвестная категория отклоняется с ErrInvalidCategory.
+func (s Settings) Category(code string) (string, error) {
+	if code == "" {
+		return s.DefaultCategory, nil
+	}
+	if !Valid(code) {
+		return "", ErrInvalidCategory
+	}
+	return code, nil
+}
+
+// Rules - налоговые настройки компании, которые используют модули
+type Rules interface {
+	Settings(ctx context.Context, customerID int) (Settings, error)
+}
+
+// Catalog - налоговые категории товаров Склада
+type Catalog interface {
+	// TaxCategories возвращает категории товаров компании по их ID; товаров, которых нет, в ответе нет
+	TaxCategories(ctx context.Context, customerID int, productIDs []int) (map[int]string, error)
+}
+
+// Line - сумма в одной налоговой категории: позиция заказа или строка разбивки по налогам
+type Line struct {
+	Category string      `json:"category"`
+	Rate     int64       `json:"rate"` // Ставка в процентах
+	Net      money.Money `json:"net"`  // Сумма без налога
+	Tax      money.Money `json:"tax"`
+	Total    money.Money `json:"total"` // Сумма с налогом
+}
+
+// Calculate рассчитывает налог позиции из quantity единиц по цене price категории category.
+// Если цена включает налог, налог выделяется из суммы позиции. Иначе налог начисляется
+// на цену единицы, а итог позиции равен цене с налогом, умноженной на количество:
+// так цена единицы в чеке дает итог позиции без остатка.
+func Calculate(price money.Money, quantity int64, category string, inclusive bool) Line {
+	rate := Rate(category)
+	line := Line{Category: category, Rate: rate}
+	if inclusive {
+		line.Total = price.Mul(quantity)
+		line.Tax = line.Total.MulRat(rate, 100+rate)
+		line.Net = line.Total.Sub(line.Tax)
+		return line
+	}
+	gross := price.Add(price.MulRat(rate, 100))
+	line.Net = price.Mul(quantity)
+	line.Total = gross.Mul(quantity)
+	line.Tax = line.Total.Sub(line.Net)
+	return line
+}
+
+// Summarize складывает строки по категориям: результат - разбивка по налогам
+// в порядке категорий
+func Summarize(lines []Line) []Line {
+	summary := []Line{}
+	for _, c := range categories {
+		sum := Line{Category: c.Code, Rate: c.Rate}
+		found := false
+		for _, l := range lines {
+			if l.Category != c.Code {
+				continue
+			}
+			sum.Net = sum.Net.Add(l.Net)
+			sum.Tax = sum.Tax.Add(l.Tax)
+			sum.Total = sum.Total.Add(l.Total)
+			found = true
+		}
+		if found {
+			summary = append(summary, sum)
+		}
+	}
+	return summary
+}
+
+// Sum возвращает итог строк без категории
+func Sum(lines []Line) Line {
+	var sum Line
+	for _, l := range lines {
+		sum.Net = sum.Net.Add(l.Net)
+		sum.Tax = sum.Tax.Add(l.Tax)
+		sum.Total = sum.Total.Add(l.Total)
+	}
+	return sum
+}
+
+// Allocate делит сумму amount между строками разбивки breakdown пропорционально их итогам,
+// например при частичной оплате заказа. Налог каждой части выделяется в той же доле,
+// что и в исходной строке; последняя часть получает остаток, поэтому части в сумме
+// дают ровно amount. Если итог разбивки не положителен, возвращается пустая разбивка.
+func Allocate(breakdown []Line, amount money.Money) []Line {
+	total := Sum(breakdown).Total
+	shares := []Line{}
+	if !total.IsPositive() {
+		return shares
+	}
+
+	rest := amount
+	for i, l := range breakdown {
+		share := l.Total.MulRat(amount.Minor(), total.Minor())
+		if i == len(breakdown)-1 {
+			share = rest
+		}
+		rest = rest.Sub(share)
+		if share.IsZero() {
+			continue
+		}
+
+		part := Line{Category: l.Category, Rate: l.Rate, Total: share}
+		if l.Total.IsPositive() {
+			part.Tax = share.MulRat(l.Tax.Minor(), l.Total.Minor())
+		}
+		part.Net = share.Sub(part.Tax)
+		shares = append(shares, part)
+	}
+	return shares
+}
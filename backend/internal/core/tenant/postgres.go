@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresStore реализует Store поверх таблицы tenants
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore создает хранилище компаний
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Create(ctx context.Context, name, subdomain string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO tenants (name, subdomain) VALUES ($1, NULLIF($2, '')) RETURNING id`, name, subdomain).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return 0, ErrSubdomainTaken
+	}
+	return id, err
+}
+
+func (s *PostgresStore) Ensure(ctx context.Context, id int, name string) error {
+	return database.InTx(ctx, s.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, s.db)
+		res, err := q.ExecContext(ctx,
+			`INSERT INTO tenants (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, id, name)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+
+		// Компания вставлена с явным ID: последовательность не должна выдать его повторно
+		_, err = q.ExecContext(ctx,
+			`SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants))`)
+		return err
+	})
+}
+
+func (s *PostgresStore) SubdomainID(ctx context.Context, subdomain string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM tenants WHERE subdomain = $1`, subdomain).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
@@ -0,0 +1,108 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHeader - заголовок, в котором передается API-ключ компании
+const APIKeyHeader = "X-API-Key"
+
+// TokenVerifier проверяет подписанный токен и возвращает ID компании из него.
+// Реализация может сохранить в контексте запроса дополнительные данные токена,
+// например пользователя.
+type TokenVerifier interface {
+	VerifyToken(c *fiber.Ctx, token string) (int, error)
+}
+
+// BearerToken определяет компанию по токену из заголовка Authorization: Bearer
+func BearerToken(v TokenVerifier) Resolver {
+	return ResolverFunc(func(c *fiber.Ctx) (int, error) {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return 0, nil
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		token = strings.TrimSpace(token)
+		if !ok || token == "" {
+			return 0, ErrUnauthorized
+		}
+
+		customerID, err := v.VerifyToken(c, token)
+		if err != nil || customerID == 0 {
+			return 0, ErrUnauthorized
+		}
+		return customerID, nil
+	})
+}
+
+// APIKey определяет компанию по API-ключу из заголовка X-API-Key
+func APIKey(lookup func(key string) (int, bool)) Resolver {
+	return ResolverFunc(func(c *fiber.Ctx) (int, error) {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return 0, nil
+		}
+
+		customerID, ok := lookup(key)
+		if !ok {
+			return 0, ErrUnauthorized
+		}
+		return customerID, nil
+	})
+}
+
+// Subdomain определяет компанию по поддомену, например acme.kit8.app: lookup возвращает
+// ID компании поддомена или ErrNotFound. Запросы к самому baseDomain и к другим доменам пропускаются.
+func Subdomain(baseDomain string, lookup func(ctx context.Context, subdomain string) (int, error)) Resolver {
+	suffix := "." + strings.TrimPrefix(baseDomain, ".")
+	return ResolverFunc(func(c *fiber.Ctx) (int, error) {
+		sub, ok := strings.CutSuffix(c.Hostname(), suffix)
+		if !ok || sub == "" || strings.Contains(sub, ".") {
+			return 0, nil
+		}
+
+		customerID, err := lookup(c.UserContext(), sub)
+		if errors.Is(err, ErrNotFound) {
+			return 0, ErrForbidden
+		}
+		return customerID, err
+	})
+}
+
+// StaticLookup возвращает функцию поиска по фиксированной таблице соответствий
+func StaticLookup(m map[string]int) func(string) (int, bool) {
+	return func(key string) (int, bool) {
+		id, ok := m[key]
+		return id, ok
+	}
+}
+
+// ParseMapping разбирает строку вида "key1=1,key2=2" в таблицу соответствий.
+// Используется для настройки API-ключей и поддоменов через переменные окружения.
+func ParseMapping(s string) (map[string]int, error) {
+	m := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tenant: invalid mapping %q", pair)
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("tenant: invalid customer id in %q", pair)
+		}
+		m[key] = id
+	}
+	return m, nil
+}
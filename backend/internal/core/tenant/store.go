@@ -0,0 +1,77 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound возвращается, если компания не найдена
+	ErrNotFound = errors.New("tenant: company not found")
+
+	// ErrSubdomainTaken возвращается, если поддомен уже занят другой компанией
+	ErrSubdomainTaken = errors.New("tenant: subdomain already taken")
+)
+
+// Store хранит компании платформы. Записи всех модулей ссылаются на компанию,
+// поэтому она должна существовать до первого запроса от ее имени.
+type Store interface {
+	// Create создает компанию с названием name и необязательным поддоменом subdomain
+	// и возвращает ее ID. Если поддомен занят, возвращает ErrSubdomainTaken.
+	Create(ctx context.Context, name, subdomain string) (int, error)
+	// Ensure создает компанию с ID id, если ее еще нет; существующая компания не меняется
+	Ensure(ctx context.Context, id int, name string) error
+	// SubdomainID возвращает ID компании с поддоменом subdomain или ErrNotFound
+	SubdomainID(ctx context.Context, subdomain string) (int, error)
+}
+
+// MemoryStore - реализация Store в памяти процесса. Хранилища модулей в памяти
+// не проверяют компанию, поэтому хранятся только занятые ID и поддомены.
+type MemoryStore struct {
+	mu         sync.Mutex
+	ids        map[int]bool
+	subdomains map[string]int
+}
+
+// NewMemoryStore создает пустое хранилище компаний в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{ids: make(map[int]bool), subdomains: make(map[string]int)}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, name, subdomain string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subdomains[subdomain]; ok && subdomain != "" {
+		return 0, ErrSubdomainTaken
+	}
+	id := 1
+	for s.ids[id] {
+		id++
+	}
+	s.ids[id] = true
+	if subdomain != "" {
+		s.subdomains[subdomain] = id
+	}
+	return id, nil
+}
+
+func (s *MemoryStore) Ensure(ctx context.Context, id int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ids[id] = true
+	return nil
+}
+
+func (s *MemoryStore) SubdomainID(ctx context.Context, subdomain string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.subdomains[subdomain]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return id, nil
+}
@@ -0,0 +1,78 @@
+// Package tenant определяет компанию (customer_id), от имени которой
+// выполняется запрос, и передает ее обработчикам модулей.
+package tenant
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// localsKey - ключ, под которым ID компании хранится в контексте запроса
+const localsKey = "customer_id"
+
+var (
+	// ErrUnauthorized возвращается, если запрос не содержит данных для определения компании
+	ErrUnauthorized = fiber.NewError(fiber.StatusUnauthorized, "Missing or invalid credentials")
+
+	// ErrForbidden возвращается, если данные запроса указывают на разные компании
+	// или компания не имеет доступа к API
+	ErrForbidden = fiber.NewError(fiber.StatusForbidden, "Access to this company is forbidden")
+)
+
+// Resolver определяет ID компании по входящему запросу.
+// Если запрос не содержит данных для этого способа, возвращается (0, nil).
+// Если данные есть, но они недействительны, возвращается ошибка.
+type Resolver interface {
+	Resolve(c *fiber.Ctx) (int, error)
+}
+
+// ResolverFunc позволяет использовать обычную функцию как Resolver
+type ResolverFunc func(c *fiber.Ctx) (int, error)
+
+// Resolve вызывает f(c)
+func (f ResolverFunc) Resolve(c *fiber.Ctx) (int, error) {
+	return f(c)
+}
+
+// Middleware опрашивает все resolvers и сохраняет найденный ID компании в контексте.
+// Если ни один способ не сработал, запрос отклоняется с 401.
+// Если разные способы указывают на разные компании, запрос отклоняется с 403.
+func Middleware(resolvers ...Resolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customerID := 0
+		for _, r := range resolvers {
+			id, err := r.Resolve(c)
+			if err != nil {
+				return err
+			}
+			if id == 0 {
+				continue
+			}
+			if customerID != 0 && customerID != id {
+				return ErrForbidden
+			}
+			customerID = id
+		}
+
+		if customerID == 0 {
+			return ErrUnauthorized
+		}
+
+		SetCustomerID(c, customerID)
+		return c.Next()
+	}
+}
+
+// SetCustomerID сохраняет ID компании в контексте запроса
+func SetCustomerID(c *fiber.Ctx, customerID int) {
+	c.Locals(localsKey, customerID)
+}
+
+// CustomerID возвращает ID компании, установленный middleware.
+// Если компания не определена, возвращается ErrUnauthorized.
+func CustomerID(c *fiber.Ctx) (int, error) {
+	customerID, ok := c.Locals(localsKey).(int)
+	if !ok || customerID == 0 {
+		return 0, ErrUnauthorized
+	}
+	return customerID, nil
+}
@@ -0,0 +1,46 @@
+// Package database содержит общие средства работы с PostgreSQL
+// для репозиториев модулей.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	// Драйвер PostgreSQL для database/sql
+	_ "github.com/lib/pq"
+)
+
+// Open подключается к PostgreSQL по строке подключения (DATABASE_URL)
+// и проверяет соединение
+func Open(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// FormatTime приводит время из базы данных к формату, используемому в API
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// FormatNullTime форматирует необязательное время; NULL превращается в пустую строку
+func FormatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return FormatTime(t.Time)
+}
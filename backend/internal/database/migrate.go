@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaOutdated возвращается, если в базе применены не все миграции
+var ErrSchemaOutdated = errors.New("database: schema is behind, run `kit8 migrate up`")
+
+// migrationLockID - ключ advisory lock, исключающий одновременный запуск миграций
+const migrationLockID = 80080080
+
+// Migration - одна версия схемы с SQL для применения и отката
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus - состояние миграции в конкретной базе данных
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time // Нулевое время, если миграция не применена
+}
+
+// Applied сообщает, применена ли миграция
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// Migrations возвращает встроенные миграции, упорядоченные по версии.
+// Файлы называются NNNN_name.up.sql и NNNN_name.down.sql.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("database: unexpected migration file %q", name)
+		}
+		prefix, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("database: invalid migration version in %q", name)
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("database: migration %04d must have both up and down files", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateStatus возвращает все известные миграции с отметкой о применении
+func MigrateStatus(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Migration: m, AppliedAt: applied[m.Version]}
+	}
+	return statuses, nil
+}
+
+// MigrateUp применяет все непримененные миграции по порядку.
+// Возвращает список примененных версий.
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		statuses, err := MigrateStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied() {
+				continue
+			}
+			err := runMigration(ctx, conn, s.Migration, s.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, s.Version, s.Name)
+			if err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown откатывает steps последних примененных миграций.
+// Возвращает список откаченных версий.
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		statuses, err := MigrateStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			s := statuses[i]
+			if !s.Applied() {
+				continue
+			}
+			err := runMigration(ctx, conn, s.Migration, s.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, s.Version)
+			if err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// CheckSchema возвращает ErrSchemaOutdated, если в базе применены не все миграции
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	statuses, err := MigrateStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if !s.Applied() {
+			return fmt.Errorf("%w (migration %04d_%s is not applied)", ErrSchemaOutdated, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// runMigration выполняет SQL миграции и запись в schema_migrations в одной транзакции
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("database: migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// withMigrationLock создает таблицу schema_migrations и выполняет fn,
+// удерживая advisory lock на выделенном соединении
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+// appliedVersions возвращает время применения каждой примененной версии.
+// Если таблица schema_migrations еще не создана, ни одна версия не считается примененной.
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,7 @@
+DROP TABLE payments;
+DROP TABLE order_items;
+DROP TABLE orders;
+DROP TABLE products;
+DROP TABLE deals;
+DROP TABLE contacts;
+DROP TABLE tenants;
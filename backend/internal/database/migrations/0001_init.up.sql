@@ -0,0 +1,89 @@
+-- Базовая схема платформы: компании и данные четырех модулей.
+-- Все таблицы модулей содержат customer_id - ID компании-владельца записи.
+
+CREATE TABLE tenants (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    subdomain  TEXT UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE contacts (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    name        TEXT NOT NULL DEFAULT '',
+    email       TEXT NOT NULL DEFAULT '',
+    phone       TEXT NOT NULL DEFAULT '',
+    company     TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX contacts_customer_id_idx ON contacts (customer_id);
+
+CREATE TABLE deals (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    title       TEXT NOT NULL DEFAULT '',
+    value       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    contact_id  INTEGER REFERENCES contacts (id) ON DELETE SET NULL,
+    stage       TEXT NOT NULL DEFAULT 'new',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX deals_customer_id_idx ON deals (customer_id);
+
+CREATE TABLE products (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    name        TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    price       NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    quantity    INTEGER NOT NULL DEFAULT 0,
+    sku         TEXT NOT NULL DEFAULT '',
+    category    TEXT NOT NULL DEFAULT '',
+    image_url   TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX products_customer_id_idx ON products (customer_id);
+
+CREATE TABLE orders (
+    id               SERIAL PRIMARY KEY,
+    customer_id      INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    contact_id       INTEGER REFERENCES contacts (id) ON DELETE SET NULL,
+    total_amount     NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL DEFAULT 'new',
+    payment_status   TEXT NOT NULL DEFAULT 'unpaid',
+    shipping_address TEXT NOT NULL DEFAULT '',
+    notes            TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+
+CREATE TABLE order_items (
+    id           SERIAL PRIMARY KEY,
+    order_id     INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id   INTEGER REFERENCES products (id) ON DELETE SET NULL,
+    product_name TEXT NOT NULL DEFAULT '',
+    quantity     INTEGER NOT NULL CHECK (quantity > 0),
+    price        NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    total        NUMERIC(14, 2) NOT NULL DEFAULT 0
+);
+CREATE INDEX order_items_order_id_idx ON order_items (order_id);
+
+CREATE TABLE payments (
+    id             SERIAL PRIMARY KEY,
+    customer_id    INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    order_id       INTEGER REFERENCES orders (id) ON DELETE SET NULL,
+    amount         NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    payment_method TEXT NOT NULL DEFAULT '',
+    status         TEXT NOT NULL DEFAULT 'pending',
+    transaction_id TEXT NOT NULL DEFAULT '',
+    payment_date   TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX payments_customer_id_idx ON payments (customer_id);
+CREATE INDEX payments_order_id_idx ON payments (order_id);
@@ -0,0 +1,16 @@
+-- Подписки компаний на модули. Каждая строка - период подписки:
+-- подписка действует, пока disabled_at пуст, повторное подключение создает новую строку.
+
+CREATE TABLE tenant_modules (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    module      TEXT NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+    enabled_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    disabled_at TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Не более одной действующей подписки компании на модуль
+CREATE UNIQUE INDEX tenant_modules_current_idx ON tenant_modules (customer_id, module)
+    WHERE disabled_at IS NULL;
@@ -0,0 +1,26 @@
+-- Счета компаний за подписки на модули: один счет на компанию за биллинговый цикл
+
+CREATE TABLE invoices (
+    id           SERIAL PRIMARY KEY,
+    customer_id  INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end   TIMESTAMPTZ NOT NULL,
+    total        NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    currency     TEXT NOT NULL DEFAULT 'USD',
+    status       TEXT NOT NULL DEFAULT 'open',
+    issued_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (customer_id, period_start)
+);
+
+CREATE TABLE invoice_lines (
+    id            SERIAL PRIMARY KEY,
+    invoice_id    INTEGER NOT NULL REFERENCES invoices (id) ON DELETE CASCADE,
+    module        TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    period_start  TIMESTAMPTZ NOT NULL,
+    period_end    TIMESTAMPTZ NOT NULL,
+    trial_until   TIMESTAMPTZ,
+    monthly_price NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    amount        NUMERIC(14, 2) NOT NULL DEFAULT 0
+);
+CREATE INDEX invoice_lines_invoice_id_idx ON invoice_lines (invoice_id);
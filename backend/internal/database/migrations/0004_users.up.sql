@@ -0,0 +1,26 @@
+-- Пользователи компаний с ролями и выданные им токены
+
+CREATE TABLE users (
+    id            SERIAL PRIMARY KEY,
+    customer_id   INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    email         TEXT NOT NULL UNIQUE,
+    name          TEXT NOT NULL DEFAULT '',
+    role          TEXT NOT NULL CHECK (role IN ('owner', 'manager', 'sales', 'cashier', 'warehouse')),
+    password_hash TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX users_customer_id_idx ON users (customer_id);
+
+CREATE TABLE refresh_tokens (
+    id         TEXT PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked    BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Отозванные до истечения срока access токены (выход из системы)
+CREATE TABLE revoked_tokens (
+    id         TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,12 @@
+-- История статусов заказов: каждая строка - один переход по жизненному циклу заказа
+
+CREATE TABLE order_status_history (
+    id          SERIAL PRIMARY KEY,
+    order_id    INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    changed_by  INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    comment     TEXT NOT NULL DEFAULT '',
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX order_status_history_order_id_idx ON order_status_history (order_id);
@@ -0,0 +1,5 @@
+-- Резерв товара под подтвержденные, но еще не отгруженные заказы.
+-- Доступно для новых заказов quantity - reserved. Заказы, подтвержденные
+-- до этой миграции, товар не резервировали: при отгрузке он просто списывается.
+
+ALTER TABLE products ADD COLUMN reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0);
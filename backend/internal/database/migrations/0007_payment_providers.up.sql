@@ -0,0 +1,4 @@
+-- Платежный провайдер, через который проведен платеж; пусто для наличных и платежей, внесенных вручную
+
+ALTER TABLE payments ADD COLUMN provider TEXT NOT NULL DEFAULT '';
+CREATE INDEX payments_transaction_id_idx ON payments (provider, transaction_id) WHERE transaction_id <> '';
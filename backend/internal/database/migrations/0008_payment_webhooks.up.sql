@@ -0,0 +1,18 @@
+-- Уведомления платежных провайдеров. Хранятся вместе с исходным телом запроса для аудита,
+-- включая отклоненные. Пара (provider, event_id) отсекает повторную доставку;
+-- у уведомлений с неверной подписью event_id не сохраняется.
+
+CREATE TABLE payment_webhooks (
+    id             SERIAL PRIMARY KEY,
+    provider       TEXT NOT NULL,
+    event_id       TEXT,
+    transaction_id TEXT NOT NULL DEFAULT '',
+    payment_id     INTEGER REFERENCES payments (id) ON DELETE SET NULL,
+    customer_id    INTEGER REFERENCES tenants (id) ON DELETE SET NULL,
+    status         TEXT NOT NULL DEFAULT '',
+    result         TEXT NOT NULL DEFAULT '',
+    payload        TEXT NOT NULL,
+    received_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, event_id)
+);
+CREATE INDEX payment_webhooks_payment_id_idx ON payment_webhooks (payment_id);
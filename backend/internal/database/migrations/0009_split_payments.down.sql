@@ -0,0 +1,6 @@
+ALTER TABLE payments
+    DROP COLUMN overpayment,
+    DROP COLUMN change_given,
+    DROP COLUMN tendered;
+
+ALTER TABLE orders DROP COLUMN paid_amount;
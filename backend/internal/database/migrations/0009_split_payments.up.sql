@@ -0,0 +1,18 @@
+-- Несколько платежей на один заказ: оплаченная сумма заказа, сдача с наличных
+-- и явно принятая переплата
+
+ALTER TABLE orders ADD COLUMN paid_amount NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+UPDATE orders o SET paid_amount = p.paid
+FROM (
+    SELECT order_id, SUM(amount) AS paid
+    FROM payments
+    WHERE status = 'completed' AND order_id IS NOT NULL
+    GROUP BY order_id
+) p
+WHERE p.order_id = o.id;
+
+ALTER TABLE payments
+    ADD COLUMN tendered     NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN change_given NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN overpayment  NUMERIC(14, 2) NOT NULL DEFAULT 0;
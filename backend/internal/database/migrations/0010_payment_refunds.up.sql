@@ -0,0 +1,22 @@
+-- Возвраты по платежам. Платеж можно вернуть несколькими частями; сумма возвратов
+-- в статусах pending и completed не превышает сумму платежа (проверяется приложением).
+
+CREATE TABLE payment_refunds (
+    id             SERIAL PRIMARY KEY,
+    payment_id     INTEGER NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+    customer_id    INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    amount         NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    reason         TEXT NOT NULL DEFAULT '',
+    status         TEXT NOT NULL DEFAULT 'pending',
+    transaction_id TEXT NOT NULL DEFAULT '',
+    created_by     INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX payment_refunds_payment_id_idx ON payment_refunds (payment_id);
+
+-- Ранее возвращенные платежи возвращались целиком
+INSERT INTO payment_refunds (payment_id, customer_id, amount, status, transaction_id, created_at, updated_at)
+SELECT id, customer_id, amount, 'completed', transaction_id, updated_at, updated_at
+FROM payments
+WHERE status = 'refunded' AND amount > 0;
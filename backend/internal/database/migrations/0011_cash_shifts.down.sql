@@ -0,0 +1,5 @@
+ALTER TABLE payment_refunds DROP COLUMN shift_id;
+ALTER TABLE payments DROP COLUMN shift_id;
+
+DROP TABLE cash_operations;
+DROP TABLE cash_shifts;
@@ -0,0 +1,38 @@
+-- Кассовые смены. У компании может быть открыта только одна смена; платежи и возвраты,
+-- проведенные за время смены, ссылаются на нее.
+
+CREATE TABLE cash_shifts (
+    id            SERIAL PRIMARY KEY,
+    customer_id   INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    number        INTEGER NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'open',
+    opening_float NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    expected_cash NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    counted_cash  NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    discrepancy   NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    opened_by     INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    closed_by     INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    opened_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_at     TIMESTAMPTZ,
+    UNIQUE (customer_id, number)
+);
+CREATE UNIQUE INDEX cash_shifts_open_idx ON cash_shifts (customer_id) WHERE status = 'open';
+
+-- Внесения и изъятия наличных
+CREATE TABLE cash_operations (
+    id          SERIAL PRIMARY KEY,
+    shift_id    INTEGER NOT NULL REFERENCES cash_shifts (id) ON DELETE CASCADE,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    type        TEXT NOT NULL,
+    amount      NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+    reason      TEXT NOT NULL DEFAULT '',
+    created_by  INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX cash_operations_shift_id_idx ON cash_operations (shift_id);
+
+ALTER TABLE payments ADD COLUMN shift_id INTEGER REFERENCES cash_shifts (id) ON DELETE SET NULL;
+CREATE INDEX payments_shift_id_idx ON payments (shift_id);
+
+ALTER TABLE payment_refunds ADD COLUMN shift_id INTEGER REFERENCES cash_shifts (id) ON DELETE SET NULL;
+CREATE INDEX payment_refunds_shift_id_idx ON payment_refunds (shift_id);
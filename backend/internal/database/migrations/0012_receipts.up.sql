@@ -0,0 +1,33 @@
+-- Фискальные чеки по 54-ФЗ: кассовые чеки прихода и возврата прихода по платежам Кассы
+-- и чеки коррекции. Позиции и основание коррекции хранятся как сформированы при выдаче чека.
+
+CREATE TABLE receipts (
+    id              SERIAL PRIMARY KEY,
+    customer_id     INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    payment_id      INTEGER REFERENCES payments (id) ON DELETE SET NULL,
+    refund_id       INTEGER REFERENCES payment_refunds (id) ON DELETE SET NULL,
+    shift_id        INTEGER REFERENCES cash_shifts (id) ON DELETE SET NULL,
+    kind            TEXT NOT NULL,
+    sign            TEXT NOT NULL,
+    taxation        TEXT NOT NULL,
+    items           JSONB NOT NULL,
+    total           NUMERIC(14, 2) NOT NULL,
+    cash            NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    electronic      NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    correction      JSONB,
+    status          TEXT NOT NULL DEFAULT 'pending',
+    error           TEXT NOT NULL DEFAULT '',
+    document_number INTEGER NOT NULL DEFAULT 0,
+    fiscal_sign     TEXT NOT NULL DEFAULT '',
+    drive_number    TEXT NOT NULL DEFAULT '',
+    registered_at   TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX receipts_customer_id_idx ON receipts (customer_id);
+CREATE INDEX receipts_payment_id_idx ON receipts (payment_id);
+
+-- Не более одного чека прихода на платеж и одного чека возврата на возврат
+CREATE UNIQUE INDEX receipts_payment_sale_idx ON receipts (payment_id)
+    WHERE kind = 'receipt' AND sign = 'income' AND payment_id IS NOT NULL;
+CREATE UNIQUE INDEX receipts_refund_idx ON receipts (refund_id) WHERE refund_id IS NOT NULL;
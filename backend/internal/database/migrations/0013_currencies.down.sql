@@ -0,0 +1,12 @@
+ALTER TABLE receipts DROP COLUMN currency;
+ALTER TABLE cash_operations DROP COLUMN currency;
+ALTER TABLE cash_shifts DROP COLUMN currency;
+ALTER TABLE payment_refunds DROP COLUMN currency;
+ALTER TABLE payments DROP COLUMN currency;
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE products DROP COLUMN currency;
+ALTER TABLE deals DROP COLUMN currency;
+
+DROP TABLE exchange_rates;
+
+ALTER TABLE tenants DROP COLUMN base_currency;
@@ -0,0 +1,28 @@
+-- Валюты: базовая валюта компании, курсы валют и валюта каждой денежной записи.
+-- Все существующие суммы вносились в рублях.
+
+ALTER TABLE tenants ADD COLUMN base_currency TEXT NOT NULL DEFAULT 'RUB';
+
+-- Курсы валют компании: 1 from_currency = rate to_currency начиная с rate_date
+CREATE TABLE exchange_rates (
+    id            SERIAL PRIMARY KEY,
+    customer_id   INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    from_currency TEXT NOT NULL,
+    to_currency   TEXT NOT NULL,
+    rate          NUMERIC(18, 6) NOT NULL CHECK (rate > 0),
+    rate_date     DATE NOT NULL,
+    source        TEXT NOT NULL DEFAULT 'manual',
+    created_by    INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (from_currency <> to_currency),
+    UNIQUE (customer_id, from_currency, to_currency, rate_date)
+);
+
+ALTER TABLE deals ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE products ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE payments ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE payment_refunds ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE cash_shifts ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE cash_operations ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE receipts ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
@@ -0,0 +1,11 @@
+ALTER TABLE receipts DROP COLUMN tax_breakdown;
+ALTER TABLE payments DROP COLUMN tax_breakdown;
+
+ALTER TABLE order_items DROP COLUMN tax;
+ALTER TABLE order_items DROP COLUMN tax_category;
+ALTER TABLE orders DROP COLUMN prices_include_tax;
+
+ALTER TABLE products DROP COLUMN tax_category;
+
+ALTER TABLE tenants DROP COLUMN default_tax_category;
+ALTER TABLE tenants DROP COLUMN prices_include_tax;
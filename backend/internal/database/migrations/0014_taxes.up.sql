@@ -0,0 +1,17 @@
+-- НДС: налоговые категории товаров, режим цен компании (с налогом или без)
+-- и расчет налога по позициям заказов, платежам и чекам.
+-- Существующие цены считаются включающими налог, товары - без НДС.
+
+ALTER TABLE tenants ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE tenants ADD COLUMN default_tax_category TEXT NOT NULL DEFAULT 'none';
+
+ALTER TABLE products ADD COLUMN tax_category TEXT NOT NULL DEFAULT 'none';
+
+-- Режим цен фиксируется в заказе при создании: смена настроек компании не меняет старые заказы
+ALTER TABLE orders ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE order_items ADD COLUMN tax_category TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE order_items ADD COLUMN tax NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+-- Разбивка суммы по налоговым категориям, как она рассчитана при проведении
+ALTER TABLE payments ADD COLUMN tax_breakdown JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE receipts ADD COLUMN tax_breakdown JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,10 @@
+ALTER TABLE order_items DROP COLUMN discount_amount;
+ALTER TABLE order_items DROP COLUMN discount;
+
+DROP INDEX orders_coupon_id_idx;
+ALTER TABLE orders DROP COLUMN discounts;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN coupon_code;
+ALTER TABLE orders DROP COLUMN coupon_id;
+
+DROP TABLE coupons;
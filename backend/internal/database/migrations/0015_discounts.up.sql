@@ -0,0 +1,29 @@
+-- Скидки: скидки на позиции и заказ, купоны (промокоды) компании и разбивка скидок заказа.
+-- Скидки считаются до налога и хранятся в заказе в том виде, в каком применены при создании.
+
+CREATE TABLE coupons (
+    id               SERIAL PRIMARY KEY,
+    customer_id      INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    code             TEXT NOT NULL,
+    percent          INTEGER NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+    amount           NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    currency         TEXT NOT NULL DEFAULT 'RUB',
+    min_order_amount NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (min_order_amount >= 0),
+    valid_from       DATE,
+    valid_until      DATE,
+    usage_limit      INTEGER NOT NULL DEFAULT 0 CHECK (usage_limit >= 0), -- 0 - без ограничения
+    active           BOOLEAN NOT NULL DEFAULT true,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (customer_id, code)
+);
+
+-- Удаление купона не меняет заказы: код и скидка остаются в заказе
+ALTER TABLE orders ADD COLUMN coupon_id INTEGER REFERENCES coupons (id) ON DELETE SET NULL;
+ALTER TABLE orders ADD COLUMN coupon_code TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN discount JSONB;
+ALTER TABLE orders ADD COLUMN discounts JSONB NOT NULL DEFAULT '[]';
+CREATE INDEX orders_coupon_id_idx ON orders (coupon_id);
+
+ALTER TABLE order_items ADD COLUMN discount JSONB;
+ALTER TABLE order_items ADD COLUMN discount_amount NUMERIC(14, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+ALTER TABLE deals ADD COLUMN stage TEXT NOT NULL DEFAULT 'new';
+UPDATE deals d SET stage = s.name FROM pipeline_stages s WHERE s.id = d.stage_id;
+
+DROP INDEX deals_pipeline_id_idx;
+ALTER TABLE deals DROP COLUMN stage_id;
+ALTER TABLE deals DROP COLUMN pipeline_id;
+
+DROP TABLE pipeline_stages;
+DROP TABLE pipelines;
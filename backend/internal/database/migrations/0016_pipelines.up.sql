@@ -0,0 +1,52 @@
+-- Воронки сделок: у каждой компании одна или несколько воронок с упорядоченными стадиями.
+-- Стадия открытая, выигрышная или проигрышная; сделка ссылается на воронку и стадию.
+-- Существующие сделки переносятся в воронку по умолчанию с прежними этапами.
+
+CREATE TABLE pipelines (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    is_default  BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX pipelines_customer_id_idx ON pipelines (customer_id);
+-- У компании ровно одна воронка по умолчанию
+CREATE UNIQUE INDEX pipelines_default_idx ON pipelines (customer_id) WHERE is_default;
+
+CREATE TABLE pipeline_stages (
+    id          SERIAL PRIMARY KEY,
+    pipeline_id INTEGER NOT NULL REFERENCES pipelines (id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    position    INTEGER NOT NULL,
+    kind        TEXT NOT NULL DEFAULT 'open' CHECK (kind IN ('open', 'won', 'lost')),
+    UNIQUE (pipeline_id, id)
+);
+
+INSERT INTO pipelines (customer_id, name, is_default)
+SELECT id, 'Продажи', true FROM tenants;
+
+INSERT INTO pipeline_stages (pipeline_id, name, position, kind)
+SELECT p.id, s.name, s.position, s.kind
+FROM pipelines p
+CROSS JOIN (VALUES ('new', 1, 'open'), ('in-progress', 2, 'open'), ('won', 3, 'won'), ('lost', 4, 'lost'))
+    AS s (name, position, kind);
+
+-- Стадия сделки всегда принадлежит ее воронке
+ALTER TABLE deals ADD COLUMN pipeline_id INTEGER REFERENCES pipelines (id);
+ALTER TABLE deals ADD COLUMN stage_id INTEGER;
+ALTER TABLE deals ADD CONSTRAINT deals_stage_fkey
+    FOREIGN KEY (pipeline_id, stage_id) REFERENCES pipeline_stages (pipeline_id, id);
+
+-- Этапы вне прежнего списка попадают на первую стадию
+UPDATE deals d SET pipeline_id = p.id,
+    stage_id = COALESCE(
+        (SELECT s.id FROM pipeline_stages s WHERE s.pipeline_id = p.id AND s.name = d.stage),
+        (SELECT s.id FROM pipeline_stages s WHERE s.pipeline_id = p.id AND s.position = 1))
+FROM pipelines p
+WHERE p.customer_id = d.customer_id AND p.is_default;
+
+ALTER TABLE deals ALTER COLUMN pipeline_id SET NOT NULL;
+ALTER TABLE deals ALTER COLUMN stage_id SET NOT NULL;
+ALTER TABLE deals DROP COLUMN stage;
+CREATE INDEX deals_pipeline_id_idx ON deals (pipeline_id, stage_id);
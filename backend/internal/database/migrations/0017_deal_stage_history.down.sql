@@ -0,0 +1 @@
+DROP TABLE deal_stage_history;
@@ -0,0 +1,21 @@
+-- История стадий сделок: каждая строка - попадание сделки на стадию, первая - создание сделки.
+-- Название и вид стадии сохраняются на момент перехода: стадию могут переименовать или удалить.
+
+CREATE TABLE deal_stage_history (
+    id            SERIAL PRIMARY KEY,
+    deal_id       INTEGER NOT NULL REFERENCES deals (id) ON DELETE CASCADE,
+    pipeline_id   INTEGER REFERENCES pipelines (id) ON DELETE SET NULL,
+    from_stage_id INTEGER REFERENCES pipeline_stages (id) ON DELETE SET NULL,
+    from_stage    TEXT NOT NULL DEFAULT '',
+    to_stage_id   INTEGER REFERENCES pipeline_stages (id) ON DELETE SET NULL,
+    to_stage      TEXT NOT NULL,
+    to_kind       TEXT NOT NULL,
+    changed_by    INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    changed_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX deal_stage_history_deal_id_idx ON deal_stage_history (deal_id, changed_at);
+
+-- Прежние переходы неизвестны: считаем, что сделка на текущей стадии с момента создания
+INSERT INTO deal_stage_history (deal_id, pipeline_id, to_stage_id, to_stage, to_kind, changed_at)
+SELECT d.id, d.pipeline_id, d.stage_id, s.name, s.kind, d.created_at
+FROM deals d JOIN pipeline_stages s ON s.id = d.stage_id;
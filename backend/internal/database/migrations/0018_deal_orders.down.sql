@@ -0,0 +1,3 @@
+ALTER TABLE deals DROP COLUMN order_id;
+ALTER TABLE orders DROP COLUMN deal_id;
+DROP TABLE deal_items;
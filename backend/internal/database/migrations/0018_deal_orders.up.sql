@@ -0,0 +1,18 @@
+-- Товары сделок и связь сделки с заказом, созданным по ней.
+-- Название и цена товара хранятся в сделке: товар могут изменить или удалить со Склада.
+
+CREATE TABLE deal_items (
+    id           SERIAL PRIMARY KEY,
+    deal_id      INTEGER NOT NULL REFERENCES deals (id) ON DELETE CASCADE,
+    product_id   INTEGER REFERENCES products (id) ON DELETE SET NULL,
+    product_name TEXT NOT NULL DEFAULT '',
+    quantity     INTEGER NOT NULL CHECK (quantity > 0),
+    price        NUMERIC(14, 2) NOT NULL DEFAULT 0
+);
+CREATE INDEX deal_items_deal_id_idx ON deal_items (deal_id);
+
+-- По сделке создается не больше одного заказа. Удаление заказа снимает ссылку
+-- со сделки, и ее можно конвертировать снова.
+ALTER TABLE orders ADD COLUMN deal_id INTEGER REFERENCES deals (id) ON DELETE SET NULL;
+ALTER TABLE orders ADD CONSTRAINT orders_deal_id_key UNIQUE (deal_id);
+ALTER TABLE deals ADD COLUMN order_id INTEGER REFERENCES orders (id) ON DELETE SET NULL;
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier - общие методы *sql.DB и *sql.Tx, которыми пользуются репозитории
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// WithTx возвращает контекст, несущий транзакцию tx. Репозитории, получившие
+// такой контекст, выполняют запросы в этой транзакции (см. Conn).
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn возвращает транзакцию из контекста, если она есть, иначе db.
+// Так операции разных модулей выполняются атомарно в транзакции вызывающего.
+func Conn(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// InTx выполняет fn в транзакции: в транзакции из контекста, если она есть,
+// иначе в новой, которая фиксируется, если fn не вернула ошибку.
+// Контекст, переданный fn, несет транзакцию.
+func InTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(WithTx(ctx, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,54 @@
+package cashier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FiscalEmulator - эмулятор фискального накопителя для разработки без кассы.
+// Нумерует фискальные документы каждой компании по порядку и вычисляет
+// фискальный признак как хеш реквизитов чека. Счетчики хранятся в памяти процесса.
+type FiscalEmulator struct {
+	mu        sync.Mutex
+	documents map[int]int // Номер последнего документа по компаниям
+}
+
+// NewFiscalEmulator создает эмулятор фискального накопителя
+func NewFiscalEmulator() *FiscalEmulator {
+	return &FiscalEmulator{documents: make(map[int]int)}
+}
+
+func (e *FiscalEmulator) Name() string {
+	return "emulator"
+}
+
+func (e *FiscalEmulator) Register(ctx context.Context, receipt *Receipt) (*FiscalData, error) {
+	if err := receipt.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.documents[receipt.CustomerID]++
+	number := e.documents[receipt.CustomerID]
+	e.mu.Unlock()
+
+	// Каждой компании соответствует свой эмулируемый накопитель
+	data := &FiscalData{
+		DocumentNumber: number,
+		DriveNumber:    fmt.Sprintf("99990789%08d", receipt.CustomerID),
+		RegisteredAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// ФПД - 10 цифр, как у настоящего накопителя
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s|%d|%s", data.DriveNumber, data.DocumentNumber,
+		receipt.Kind, receipt.Sign, receipt.Total.Minor(), data.RegisteredAt)))
+	data.Sign = fmt.Sprintf("%010d", binary.BigEndian.Uint32(sum[:4]))
+	return data, nil
+}
@@ -0,0 +1,223 @@
+package cashier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/tax"
+)
+
+// Статусы регистрации чека
+const (
+	ReceiptPending    = "pending"    // Чек сформирован, но еще не зарегистрирован
+	ReceiptRegistered = "registered" // Чек зарегистрирован, фискальные реквизиты получены
+	ReceiptFailed     = "failed"     // Регистрация не удалась; чек можно зарегистрировать повторно
+)
+
+// Виды чеков
+const (
+	KindReceipt    = "receipt"    // Кассовый чек
+	KindCorrection = "correction" // Чек коррекции
+)
+
+// Признаки расчета (тег 1054)
+const (
+	SignIncome       = "income"        // Приход
+	SignIncomeRefund = "income_refund" // Возврат прихода
+)
+
+// Системы налогообложения (тег 1055)
+const (
+	TaxationOSN              = "osn"                // Общая
+	TaxationUSNIncome        = "usn_income"         // Упрощенная, доходы
+	TaxationUSNIncomeOutcome = "usn_income_outcome" // Упрощенная, доходы минус расходы
+	TaxationESN              = "esn"                // Единый сельскохозяйственный налог
+	TaxationPatent           = "patent"             // Патентная
+)
+
+// Ставки НДС позиции (тег 1199). Ставки полного расчета совпадают с кодами налоговых категорий.
+const (
+	VATNone = tax.Exempt // Без НДС
+	VAT0    = tax.VAT0   // 0%
+	VAT10   = tax.VAT10  // 10%
+	VAT20   = tax.VAT20  // 20%
+	VAT110  = "vat110"   // Расчетная 10/110
+	VAT120  = "vat120"   // Расчетная 20/120
+)
+
+// Признаки способа расчета (тег 1214)
+const (
+	MethodFullPrepayment = "full_prepayment" // Предоплата 100%
+	MethodPrepayment     = "prepayment"      // Частичная предоплата
+	MethodAdvance        = "advance"         // Аванс
+	MethodFullPayment    = "full_payment"    // Полный расчет
+	MethodPartialPayment = "partial_payment" // Частичный расчет и кредит
+	MethodCredit         = "credit"          // Передача в кредит
+	MethodCreditPayment  = "credit_payment"  // Оплата кредита
+)
+
+// Признаки предмета расчета (тег 1212)
+const (
+	ObjectCommodity = "commodity" // Товар
+	ObjectService   = "service"   // Услуга
+	ObjectPayment   = "payment"   // Платеж
+)
+
+// Типы коррекции (тег 1173)
+const (
+	CorrectionSelf        = "self"        // Самостоятельная
+	CorrectionInstruction = "instruction" // По предписанию налогового органа
+)
+
+var (
+	// ErrReceiptNotFound возвращается, если чек не найден или принадлежит другой компании
+	ErrReceiptNotFound = errors.New("cashier: receipt not found")
+	// ErrReceiptExists возвращается при повторном формировании чека для того же платежа или возврата
+	ErrReceiptExists = errors.New("cashier: receipt already exists")
+	// ErrInvalidReceipt возвращается фискальным накопителем, если чек не проходит проверку
+	ErrInvalidReceipt = errors.New("cashier: invalid receipt")
+)
+
+// ValidTaxation сообщает, известна ли система налогообложения
+func ValidTaxation(taxation string) bool {
+	switch taxation {
+	case TaxationOSN, TaxationUSNIncome, TaxationUSNIncomeOutcome, TaxationESN, TaxationPatent:
+		return true
+	}
+	return false
+}
+
+// ValidVAT сообщает, известна ли ставка НДС
+func ValidVAT(vat string) bool {
+	switch vat {
+	case VATNone, VAT0, VAT10, VAT20, VAT110, VAT120:
+		return true
+	}
+	return false
+}
+
+// ReceiptItem - позиция чека
+type ReceiptItem struct {
+	Name          string      `json:"name"`
+	Quantity      float64     `json:"quantity"` // Может быть дробным (весовой товар), до тысячных
+	Price         money.Money `json:"price"`
+	Total         money.Money `json:"total"`
+	VAT           string      `json:"vat"`            // Ставка НДС
+	PaymentMethod string      `json:"payment_method"` // Признак способа расчета
+	PaymentObject string      `json:"payment_object"` // Признак предмета расчета
+}
+
+// Correction - основание чека коррекции
+type Correction struct {
+	Type           string `json:"type"`            // self или instruction
+	DocumentNumber string `json:"document_number"` // Номер предписания или документа-основания
+	DocumentDate   string `json:"document_date"`   // Дата расчета, который корректируется (YYYY-MM-DD)
+	Reason         string `json:"reason"`
+}
+
+// Receipt - фискальный чек по платежу или возврату в формате данных 54-ФЗ
+type Receipt struct {
+	ID           int           `json:"id"`
+	CustomerID   int           `json:"customer_id"` // ID компании
+	PaymentID    int           `json:"payment_id"`  // 0 - чек коррекции без платежа
+	RefundID     int           `json:"refund_id"`   // Возврат, по которому выбит чек возврата прихода
+	ShiftID      int           `json:"shift_id"`
+	Kind         string        `json:"kind"`     // receipt или correction
+	Sign         string        `json:"sign"`     // Признак расчета: income, income_refund
+	Taxation     string        `json:"taxation"` // Система налогообложения
+	Items        []ReceiptItem `json:"items"`
+	Total        money.Money   `json:"total"`
+	TaxBreakdown []tax.Line    `json:"tax_breakdown"` // Налог в сумме чека по категориям
+	Currency     string        `json:"currency"`      // Валюта платежа; для коррекции - указанная или базовая
+	Cash         money.Money   `json:"cash"`          // Оплачено наличными
+	Electronic   money.Money   `json:"electronic"`    // Оплачено безналичными
+	Correction   *Correction   `json:"correction,omitempty"`
+	Status       string        `json:"status"` // pending, registered, failed
+	Error        string        `json:"error"`  // Причина неудачной регистрации
+	Fiscal       FiscalData    `json:"fiscal"`
+	CreatedAt    string        `json:"created_at"`
+	UpdatedAt    string        `json:"updated_at"`
+}
+
+// FiscalData - фискальные реквизиты зарегистрированного чека
+type FiscalData struct {
+	DocumentNumber int    `json:"document_number"` // Номер фискального документа (ФД)
+	Sign           string `json:"sign"`            // Фискальный признак документа (ФПД)
+	DriveNumber    string `json:"drive_number"`    // Номер фискального накопителя (ФН)
+	RegisteredAt   string `json:"registered_at"`
+}
+
+// FiscalDriver регистрирует чеки в фискальном накопителе кассы
+// и передает их оператору фискальных данных
+type FiscalDriver interface {
+	// Name возвращает название драйвера
+	Name() string
+	// Register регистрирует чек и возвращает его фискальные реквизиты.
+	// Некорректный чек отклоняется с ErrInvalidReceipt.
+	Register(ctx context.Context, receipt *Receipt) (*FiscalData, error)
+}
+
+// Fiscalization - настройки формирования чеков компании
+type Fiscalization struct {
+	Driver   FiscalDriver
+	Taxation string // Система налогообложения
+}
+
+// CorrectionRequest - запрос на чек коррекции
+type CorrectionRequest struct {
+	Sign          string      `json:"sign"` // income или income_refund
+	Amount        money.Money `json:"amount"`
+	Currency      string      `json:"currency"`       // По умолчанию - базовая валюта компании
+	TaxCategory   string      `json:"tax_category"`   // По умолчанию - категория компании
+	PaymentMethod string      `json:"payment_method"` // cash или безналичный способ оплаты
+	Correction    Correction  `json:"correction"`
+}
+
+// Validate проверяет согласованность чека: суммы позиций и оплат сходятся с итогом,
+// реквизиты позиций заполнены известными значениями
+func (r *Receipt) Validate() error {
+	if r.Kind != KindReceipt && r.Kind != KindCorrection {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidReceipt, r.Kind)
+	}
+	if r.Sign != SignIncome && r.Sign != SignIncomeRefund {
+		return fmt.Errorf("%w: unknown sign %q", ErrInvalidReceipt, r.Sign)
+	}
+	if !ValidTaxation(r.Taxation) {
+		return fmt.Errorf("%w: unknown taxation %q", ErrInvalidReceipt, r.Taxation)
+	}
+	if r.Kind == KindCorrection {
+		if r.Correction == nil || (r.Correction.Type != CorrectionSelf && r.Correction.Type != CorrectionInstruction) {
+			return fmt.Errorf("%w: correction type must be %s or %s", ErrInvalidReceipt, CorrectionSelf, CorrectionInstruction)
+		}
+	}
+	if len(r.Items) == 0 {
+		return fmt.Errorf("%w: receipt has no items", ErrInvalidReceipt)
+	}
+
+	var total money.Money
+	for i, item := range r.Items {
+		switch {
+		case item.Name == "":
+			return fmt.Errorf("%w: item %d has no name", ErrInvalidReceipt, i+1)
+		case item.Quantity <= 0:
+			return fmt.Errorf("%w: item %d quantity must be positive", ErrInvalidReceipt, i+1)
+		case !ValidVAT(item.VAT):
+			return fmt.Errorf("%w: item %d has unknown VAT %q", ErrInvalidReceipt, i+1, item.VAT)
+		case item.PaymentMethod == "" || item.PaymentObject == "":
+			return fmt.Errorf("%w: item %d has no payment method or object", ErrInvalidReceipt, i+1)
+		case !item.Price.MulRat(int64(math.Round(item.Quantity*1000)), 1000).Equal(item.Total):
+			return fmt.Errorf("%w: item %d total does not match price and quantity", ErrInvalidReceipt, i+1)
+		}
+		total = total.Add(item.Total)
+	}
+	if !total.Equal(r.Total) {
+		return fmt.Errorf("%w: items total %s does not match receipt total", ErrInvalidReceipt, total.Fixed())
+	}
+	if !r.Cash.Add(r.Electronic).Equal(r.Total) || !r.Total.IsPositive() {
+		return fmt.Errorf("%w: payments do not match receipt total", ErrInvalidReceipt)
+	}
+	return nil
+}
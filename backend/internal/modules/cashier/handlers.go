@@ -1,10 +1,23 @@
 package cashier
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/auth"
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/report"
+	"kit8-backend/internal/core/settlement"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
 )
 
 // PaymentMethod представляет способ оплаты
@@ -19,175 +32,1779 @@ type Payment struct {
 	ID             int     `json:"id"`
 	OrderID        int     `json:"order_id"`
 	CustomerID     int     `json:"customer_id"` // ID компании
-	Amount         float64 `json:"amount"`
+	Amount         money.Money `json:"amount"`
+	Currency       string  `json:"currency"`       // Валюта заказа; без заказа - указанная или базовая валюта компании
 	PaymentMethod  string  `json:"payment_method"` // Способ оплаты
-	Status         string  `json:"status"`         // pending, completed, failed, refunded
+	Status         string  `json:"status"`         // pending, authorized, completed, failed, voided, refunded
+	Provider       string  `json:"provider"`       // Платежный провайдер; пусто для наличных
+	Tendered       money.Money `json:"tendered"`       // Получено наличными от покупателя
+	Change         money.Money `json:"change"`         // Сдача: Tendered - Amount
+	Overpayment    money.Money `json:"overpayment"`    // Часть Amount сверх остатка к оплате заказа, принятая явно
+	RefundedAmount money.Money `json:"refunded_amount"` // Сумма проведенных возвратов; вычисляется хранилищем
+	ShiftID        int     `json:"shift_id"`       // Кассовая смена, в которой принят платеж; 0 - вне смены
+	TaxBreakdown   []tax.Line `json:"tax_breakdown"` // Налог в сумме платежа по категориям позиций заказа
 	TransactionID  string  `json:"transaction_id"` // ID транзакции у платежного провайдера
 	PaymentDate    string `json:"payment_date"`
 	CreatedAt      string  `json:"created_at"`
 	UpdatedAt      string  `json:"updated_at"`
 }
 
-// CashierStats представляет статистику по кассе
+// CashierStats представляет статистику по кассе: итоги за период и за сегодня
 type CashierStats struct {
-	TotalRevenue     float64 `json:"total_revenue"`
-	TodaysRevenue    float64 `json:"todays_revenue"`
+	report.Period
+	TotalRevenue     money.Money `json:"total_revenue"`
+	TodaysRevenue    money.Money `json:"todays_revenue"` // За сегодня независимо от периода
 	TotalTransactions int    `json:"total_transactions"`
 	TodaysTransactions int   `json:"todays_transactions"`
-	RefundAmount     float64 `json:"refund_amount"`
+	RefundAmount     money.Money `json:"refund_amount"`
+	Currency         string `json:"currency"` // Базовая валюта компании, в которой считаются суммы
+	Series           []CashierStatsPoint `json:"series"`
+}
+
+// CashierStatsPoint - проведенные платежи за период группировки
+type CashierStatsPoint struct {
+	Period       string      `json:"period"` // Начало периода, YYYY-MM-DD
+	Transactions int         `json:"transactions"`
+	Revenue      money.Money `json:"revenue"` // За вычетом возвратов, в базовой валюте компании
+	Refunds      money.Money `json:"refunds"`
+}
+
+// ProcessPaymentRequest - запрос на проведение платежа
+type ProcessPaymentRequest struct {
+	OrderID       int     `json:"order_id"`
+	Amount        money.Money `json:"amount"`
+	Currency      string  `json:"currency"`       // Необязательно: для платежа по заказу должна совпадать с валютой заказа
+	PaymentMethod string  `json:"payment_method"` // cash проводится без провайдера
+	Capture       *bool   `json:"capture"`        // false - только авторизовать; по умолчанию средства списываются сразу
+	Tendered      money.Money `json:"tendered"`       // Для наличных: сколько передал покупатель, если больше amount
+	// AllowOverpayment разрешает принять больше остатка к оплате заказа;
+	// превышение сохраняется в платеже как overpayment
+	AllowOverpayment bool `json:"allow_overpayment"`
 }
 
 // Контроллер Кассы
 type Controller struct {
-	// Здесь будут зависимости, например, сервисы и репозитории
-	// Для упрощения в этом примере будем использовать заглушку
+	payments PaymentRepository
+	shifts   ShiftRepository
+	receipts ReceiptRepository
+	provider PaymentProvider    // Провайдер безналичных платежей
+	fiscal   Fiscalization      // Регистрация чеков
+	orders   settlement.Orders // Заказы, статус оплаты которых следует за платежами
+	currencies currency.Currencies // Базовая валюта и курсы компании
+	taxes    tax.Rules          // Налоговые настройки компании
 }
 
 // NewController создает новый контроллер Кассы
-func NewController() *Controller {
-	return &Controller{}
+func NewController(payments PaymentRepository, shifts ShiftRepository, receipts ReceiptRepository,
+	provider PaymentProvider, fiscal Fiscalization, orders settlement.Orders, currencies currency.Currencies,
+	taxes tax.Rules) *Controller {
+	return &Controller{
+		payments:   payments,
+		shifts:     shifts,
+		receipts:   receipts,
+		provider:   provider,
+		fiscal:     fiscal,
+		orders:     orders,
+		currencies: currencies,
+		taxes:      taxes,
+	}
 }
 
 // GetPayments возвращает список платежей
 func (ctrl *Controller) GetPayments(c *fiber.Ctx) error {
-	// Получаем ID компании из контекста (предполагается, что он был установлен в middleware)
-	customerID := c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// и фильтрация по customerID
-	payments := []Payment{
-		{
-			ID: 1, OrderID: 1, CustomerID: customerID, Amount: 50000.0, 
-			PaymentMethod: "card", Status: "completed", TransactionID: "txn_123456789", 
-			PaymentDate: "2023-01-01T10:00:00Z", CreatedAt: "2023-01-01T10:00:00Z", UpdatedAt: "2023-01-01T10:00Z",
-		},
-		{
-			ID: 2, OrderID: 2, CustomerID: customerID, Amount: 3000.0, 
-			PaymentMethod: "cash", Status: "completed", TransactionID: "cash_987654321", 
-			PaymentDate: "2023-01-02T11:30:00Z", CreatedAt: "2023-01-02T11:30:00Z", UpdatedAt: "2023-01-02T11:30:00Z",
-		},
-	}
-	
+	// Получаем ID компании из контекста (устанавливается в tenant middleware)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	payments, err := ctrl.payments.ListPayments(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(payments)
 }
 
 // CreatePayment создает новый платеж
 func (ctrl *Controller) CreatePayment(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Парсим тело запроса
 	var payment Payment
 	if err := c.BodyParser(&payment); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// Устанавливаем ID компании для нового платежа
+
+	// Устанавливаем ID компании для нового платежа; платеж, внесенный вручную, не связан с провайдером
 	payment.CustomerID = customerID
-	payment.Status = "pending" // Устанавливаем начальный статус
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обработки платежа через платежный шлюз
-	// payment.ID = generateNextID() // генерация нового ID
-	// payment.TransactionID = generateTransactionID() // генерация ID транзакции
-	
+	payment.Status = StatusPending // Устанавливаем начальный статус
+	payment.Provider = ""
+
+	// Сохраняем платеж, ID и даты назначаются хранилищем.
+	// Переплату вручную вносят явно, указав overpayment.
+	if err := ctrl.create(c.UserContext(), &payment, payment.Overpayment.IsPositive()); err != nil {
+		return paymentError(c, err)
+	}
+
 	// Возвращаем созданный платеж
 	return c.JSON(payment)
 }
 
-// UpdatePayment обновляет существующий платеж
+var (
+	// errStatusChange - статус платежа меняется только операциями Кассы, а не обновлением
+	errStatusChange = errors.New("cashier: payment status is changed by payment operations")
+	// errPaymentSettled - у проведенного платежа нельзя менять сумму, валюту, заказ и способ оплаты
+	errPaymentSettled = errors.New("cashier: payment is no longer pending")
+)
+
+// UpdatePayment обновляет существующий платеж. Статус, провайдер, транзакция, дата
+// и смена платежа меняются только операциями Кассы; сумму, валюту и заказ можно
+// изменить, только пока платеж в ожидании, как и способ оплаты, по которому считается
+// наличность смены.
 func (ctrl *Controller) UpdatePayment(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID платежа из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID"})
 	}
-	
+
 	// Парсим тело запроса
 	var updatedPayment Payment
 	if err := c.BodyParser(&updatedPayment); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обновления платежа в базе данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
-	// Возвращаем обновленный платеж
+
+	// Обновляем платеж; если он перенесен на другой заказ, пересчитываем оплату обоих
 	updatedPayment.ID = id
 	updatedPayment.CustomerID = customerID
+	err = ctrl.payments.WithTx(c.UserContext(), func(ctx context.Context) error {
+		// Платеж блокируется до конца транзакции: параллельный возврат или операция
+		// провайдера не изменят его между проверкой и сохранением
+		current, err := ctrl.payments.LockPayment(ctx, customerID, id)
+		if err != nil {
+			return err
+		}
+		if updatedPayment.Status != "" && updatedPayment.Status != current.Status {
+			return errStatusChange
+		}
+		updatedPayment.Status = current.Status
+		updatedPayment.Provider = current.Provider
+		updatedPayment.TransactionID = current.TransactionID
+		updatedPayment.PaymentDate = current.PaymentDate
+		updatedPayment.ShiftID = current.ShiftID
+		if updatedPayment.Currency == "" {
+			updatedPayment.Currency = current.Currency
+		}
+
+		// Проведенный платеж уже учтен провайдером, чеком, возвратами и наличностью смены:
+		// сумма, валюта, заказ и способ оплаты не меняются, их можно не передавать
+		if current.Status != StatusPending {
+			if (!updatedPayment.Amount.IsZero() && !updatedPayment.Amount.Equal(current.Amount)) ||
+				updatedPayment.Currency != current.Currency ||
+				(updatedPayment.OrderID != 0 && updatedPayment.OrderID != current.OrderID) ||
+				(updatedPayment.PaymentMethod != "" && updatedPayment.PaymentMethod != current.PaymentMethod) {
+				return errPaymentSettled
+			}
+			updatedPayment.Amount = current.Amount
+			updatedPayment.OrderID = current.OrderID
+			updatedPayment.PaymentMethod = current.PaymentMethod
+			updatedPayment.Tendered = current.Tendered
+			updatedPayment.Change = current.Change
+			updatedPayment.Overpayment = current.Overpayment
+			updatedPayment.TaxBreakdown = current.TaxBreakdown
+			return ctrl.save(ctx, &updatedPayment)
+		}
+
+		if err := ctrl.resolveCurrency(ctx, &updatedPayment); err != nil {
+			return err
+		}
+		if err := ctrl.applyTax(ctx, &updatedPayment); err != nil {
+			return err
+		}
+		if err := ctrl.checkBalance(ctx, &updatedPayment, updatedPayment.Overpayment.IsPositive()); err != nil {
+			return err
+		}
+		if err := ctrl.save(ctx, &updatedPayment); err != nil {
+			return err
+		}
+		if current.OrderID != updatedPayment.OrderID {
+			return ctrl.settle(ctx, customerID, current.OrderID)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	case errors.Is(err, errStatusChange):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Use process, capture, void and refund to change payment status",
+		})
+	case errors.Is(err, errPaymentSettled):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Amount, currency, order and payment method can be changed only while the payment is pending",
+		})
+	case err != nil:
+		return paymentError(c, err)
+	}
+
+	// Возвращаем обновленный платеж
 	return c.JSON(updatedPayment)
 }
 
-// ProcessPayment обрабатывает платеж (основной метод кассы)
+// ProcessPayment обрабатывает платеж (основной метод кассы).
+// Наличные принимаются сразу, остальные способы оплаты проводятся через платежного провайдера.
 func (ctrl *Controller) ProcessPayment(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Парсим тело запроса
-	var payment Payment
-	if err := c.BodyParser(&payment); err != nil {
+	var req ProcessPaymentRequest
+	if err := c.BodyParser(&req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// Устанавливаем ID компании для платежа
-	payment.CustomerID = customerID
-	payment.Status = "pending"
-	
-	// В реальном приложении здесь будет:
-	// 1. Проверка данных заказа
-	// 2. Вызов платежного шлюза
-	// 3. Обработка ответа от платежного шлюза
-	// 4. Обновление статуса платежа
-	// 5. Обновление статуса заказа
-	
+	if !req.Amount.IsPositive() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be positive"})
+	}
+	if !req.Tendered.IsZero() && req.PaymentMethod != MethodCash {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Tendered amount is only accepted for cash payments"})
+	}
+	if !req.Tendered.IsZero() && req.Tendered.Cmp(req.Amount) < 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Tendered amount is less than payment amount"})
+	}
+
+	payment := Payment{
+		CustomerID:    customerID,
+		OrderID:       req.OrderID,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		PaymentMethod: req.PaymentMethod,
+		Status:        StatusPending,
+	}
+
+	// Наличные не требуют провайдера; сдача сохраняется вместе с платежом
+	if payment.PaymentMethod == MethodCash {
+		payment.Status = StatusCompleted
+		payment.PaymentDate = time.Now().UTC().Format(time.RFC3339)
+		if !req.Tendered.IsZero() {
+			payment.Tendered = req.Tendered
+			payment.Change = req.Tendered.Sub(req.Amount)
+		}
+		if err := ctrl.create(c.UserContext(), &payment, req.AllowOverpayment); err != nil {
+			return paymentError(c, err)
+		}
+		ctrl.issueSaleReceipt(c.UserContext(), &payment)
+		return c.JSON(paymentResult(&payment, ""))
+	}
+
+	// Регистрируем платеж до обращения к провайдеру, чтобы ответ можно было сверить.
+	// Платеж в ожидании уже учитывается в остатке к оплате заказа.
+	payment.Provider = ctrl.provider.Name()
+	if err := ctrl.create(c.UserContext(), &payment, req.AllowOverpayment); err != nil {
+		return paymentError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), ProviderTimeout)
+	defer cancel()
+
+	result, err := ctrl.provider.Authorize(ctx, AuthorizeRequest{
+		Reference: strconv.Itoa(payment.ID),
+		Amount:    payment.Amount.In(payment.Currency),
+		Method:    payment.PaymentMethod,
+	})
+	if errors.Is(err, ErrProviderTimeout) {
+		// Исход неизвестен: платеж остается в ожидании до ответа провайдера
+		return c.Status(http.StatusAccepted).JSON(paymentResult(&payment, "Payment provider did not respond, payment is pending"))
+	}
+	if err != nil {
+		return ctrl.providerFailed(c, &payment, err)
+	}
+	if err := ctrl.applyResult(c.UserContext(), &payment, result); err != nil {
+		return err
+	}
+
+	// Одностадийная оплата: сразу списываем авторизованные средства
+	if payment.Status == StatusAuthorized && (req.Capture == nil || *req.Capture) {
+		result, err := ctrl.provider.Capture(ctx, payment.TransactionID, payment.Amount.In(payment.Currency))
+		if errors.Is(err, ErrProviderTimeout) {
+			return c.Status(http.StatusAccepted).JSON(paymentResult(&payment, "Payment provider did not respond, payment is authorized"))
+		}
+		if err != nil {
+			// Авторизация снимается, чтобы средства покупателя не остались заблокированными
+			if _, err := ctrl.provider.Void(ctx, payment.TransactionID); err != nil {
+				log.Printf("cashier: void payment %d after failed capture: %v", payment.ID, err)
+			}
+			return ctrl.providerFailed(c, &payment, err)
+		}
+		if err := ctrl.applyResult(c.UserContext(), &payment, result); err != nil {
+			return err
+		}
+	}
+
 	// Возвращаем результат обработки платежа
-	return c.JSON(fiber.Map{
-		"status": "pending",
-		"transaction_id": payment.TransactionID,
-		"amount": payment.Amount,
+	ctrl.issueSaleReceipt(c.UserContext(), &payment)
+	return c.JSON(paymentResult(&payment, result.Message))
+}
+
+// providerFailed отклоняет платеж, который провайдер не провел из-за ошибки cause: платеж
+// переходит в статус failed и больше не учитывается в остатке к оплате заказа
+func (ctrl *Controller) providerFailed(c *fiber.Ctx, payment *Payment, cause error) error {
+	log.Printf("cashier: payment %d failed at provider: %v", payment.ID, cause)
+	payment.Status = StatusFailed
+	err := ctrl.payments.WithTx(c.UserContext(), func(ctx context.Context) error {
+		return ctrl.save(ctx, payment)
 	})
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusBadGateway).JSON(paymentResult(payment, "Payment provider failed to process the payment"))
 }
 
-// RefundPayment возвращает средства
+// CapturePayment списывает средства по авторизованному платежу
+func (ctrl *Controller) CapturePayment(c *fiber.Ctx) error {
+	return ctrl.providerOperation(c, StatusAuthorized, func(ctx context.Context, p *Payment) (*ProviderResult, error) {
+		return ctrl.provider.Capture(ctx, p.TransactionID, p.Amount.In(p.Currency))
+	})
+}
+
+// VoidPayment отменяет авторизацию платежа
+func (ctrl *Controller) VoidPayment(c *fiber.Ctx) error {
+	return ctrl.providerOperation(c, StatusAuthorized, func(ctx context.Context, p *Payment) (*ProviderResult, error) {
+		return ctrl.provider.Void(ctx, p.TransactionID)
+	})
+}
+
+// SyncPayment запрашивает статус платежа у провайдера и сохраняет его.
+// Возвраты, оставшиеся без ответа провайдера, сверяются с суммой возвратов по транзакции.
+func (ctrl *Controller) SyncPayment(c *fiber.Ctx) error {
+	return ctrl.providerOperation(c, "", func(ctx context.Context, p *Payment) (*ProviderResult, error) {
+		result, err := ctrl.provider.Status(ctx, p.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		return result, ctrl.reconcileRefunds(c.UserContext(), p, result.Refunded)
+	})
+}
+
+// RefundPayment возвращает средства по платежу полностью или частично.
+// Сумма всех возвратов не может превышать сумму платежа.
 func (ctrl *Controller) RefundPayment(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID платежа из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID"})
 	}
 
+	// Тело запроса необязательно: без него возвращается весь остаток платежа
+	var req RefundRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if req.Amount.IsNegative() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Refund amount must be positive"})
+	}
+
+	// Платеж должен принадлежать текущей компании
+	payment, err := ctrl.payments.GetPayment(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	}
+	if err != nil {
+		return err
+	}
+	if payment.Provider != "" && (payment.Provider != ctrl.provider.Name() || payment.TransactionID == "") {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment has no transaction with the configured provider"})
+	}
+
+	refund := Refund{
+		PaymentID:  id,
+		CustomerID: customerID,
+		Amount:     req.Amount,
+		Currency:   payment.Currency,
+		Reason:     req.Reason,
+		Status:     RefundPending,
+	}
+	if claims, ok := auth.CurrentClaims(c); ok {
+		refund.CreatedBy = claims.UserID()
+	}
+
+	// Резервируем сумму возврата до обращения к провайдеру, чтобы параллельные
+	// возвраты не превысили сумму платежа. Возврат относится к открытой смене;
+	// наличные возвращаются из кассы только при открытой смене и в ее валюте.
+	var mismatch *CurrencyMismatchError
+	err = ctrl.payments.WithTx(c.UserContext(), func(ctx context.Context) error {
+		shift, err := ctrl.currentShift(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		if err := shift.accepts(payment.PaymentMethod, payment.Currency); err != nil {
+			return err
+		}
+		refund.ShiftID = shift.ID
+		return ctrl.payments.CreateRefund(ctx, &refund, ctrl.checkRefund(&refund, payment))
+	})
+	var limit *RefundLimitError
+	switch {
+	case errors.As(err, &mismatch):
+		return currencyMismatch(c, mismatch)
+	case errors.Is(err, ErrNoOpenShift):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "No open shift"})
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	case errors.Is(err, ErrNotRefundable):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Payment must be %s, current status is %s", StatusCompleted, payment.Status),
+		})
+	case errors.As(err, &limit):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":      "Refund exceeds refundable amount",
+			"refundable": limit.Refundable,
+		})
+	case err != nil:
+		return err
+	}
+
+	// Наличные возвращаются из кассы без провайдера
+	if payment.Provider == "" {
+		refund.Status = RefundCompleted
+	} else {
+		ctx, cancel := context.WithTimeout(c.UserContext(), ProviderTimeout)
+		defer cancel()
+
+		result, err := ctrl.provider.Refund(ctx, payment.TransactionID, refund.Amount.In(refund.Currency))
+		switch {
+		case errors.Is(err, ErrProviderTimeout):
+			// Провайдер мог провести возврат: сумма остается зарезервированной
+			return c.Status(http.StatusAccepted).JSON(refund)
+		case err != nil:
+			// Провайдер ответил ошибкой: возврат не проведен и резерв снимается
+			refund.Status = RefundFailed
+			if err := ctrl.payments.UpdateRefund(c.UserContext(), &refund); err != nil {
+				return err
+			}
+			if errors.Is(err, ErrInvalidOperation) || errors.Is(err, ErrUnknownTransaction) {
+				return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment provider rejected the operation"})
+			}
+			return err
+		}
+		refund.Status = RefundCompleted
+		refund.TransactionID = result.TransactionID
+	}
+
+	if err := ctrl.completeRefund(c.UserContext(), &refund); err != nil {
+		return err
+	}
+	ctrl.issueRefundReceipt(c.UserContext(), payment, &refund)
+	return c.JSON(refund)
+}
+
+// checkRefund возвращает проверку возврата для CreateRefund: платеж должен быть проведен,
+// а возврат - не больше остатка, доступного к возврату. Без суммы возвращается весь остаток.
+// Платеж, прочитанный под блокировкой, сохраняется в payment.
+func (ctrl *Controller) checkRefund(refund *Refund, payment *Payment) func(p *Payment, reserved money.Money) error {
+	return func(p *Payment, reserved money.Money) error {
+		*payment = *p
+		if p.Status != StatusCompleted {
+			return ErrNotRefundable
+		}
+		refundable := money.Max(p.Amount.Sub(reserved), money.Money{})
+		if refund.Amount.IsZero() {
+			refund.Amount = refundable
+		}
+		if !refund.Amount.IsPositive() || refund.Amount.Cmp(refundable) > 0 {
+			return &RefundLimitError{Refundable: refundable}
+		}
+		return nil
+	}
+}
+
+// GetPaymentRefunds возвращает возвраты платежа
+func (ctrl *Controller) GetPaymentRefunds(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обработки возврата средств через платежный шлюз
-	// с проверкой, принадлежит ли платеж текущей компании
-	
-	// Возвращаем результат возврата
-	return c.JSON(fiber.Map{
-	"status": "refunded",
-		"payment_id": id,
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID платежа из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID"})
+	}
+
+	refunds, err := ctrl.payments.PaymentRefunds(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(refunds)
+}
+
+// completeRefund сохраняет проведенный возврат, переводит полностью возвращенный платеж
+// в статус refunded и пересчитывает оплату его заказа в одной транзакции
+func (ctrl *Controller) completeRefund(ctx context.Context, refund *Refund) error {
+	return ctrl.payments.WithTx(ctx, func(ctx context.Context) error {
+		if err := ctrl.payments.UpdateRefund(ctx, refund); err != nil {
+			return err
+		}
+
+		// Платеж читается заново: сумма возвратов учитывает параллельные возвраты
+		payment, err := ctrl.payments.LockPayment(ctx, refund.CustomerID, refund.PaymentID)
+		if err != nil {
+			return err
+		}
+		if payment.Status == StatusCompleted && payment.RefundedAmount.Cmp(payment.Amount) >= 0 {
+			payment.Status = StatusRefunded
+		}
+		return ctrl.save(ctx, payment)
 	})
 }
 
-// GetCashierStats возвращает статистику по кассе
+// reconcileRefunds сверяет возвраты платежа, оставшиеся без ответа провайдера, с суммой
+// возвратов по транзакции refunded и перечитывает платеж в payment. Полностью возвращенный
+// платеж переводится в статус refunded, по проведенным возвратам выдаются чеки.
+func (ctrl *Controller) reconcileRefunds(ctx context.Context, payment *Payment, refunded money.Money) error {
+	// Возвраты моложе ProviderTimeout могут еще ждать ответа провайдера в другом запросе
+	cutoff := time.Now().Add(-ProviderTimeout)
+
+	var completed []Refund
+	err := ctrl.payments.WithTx(ctx, func(ctx context.Context) error {
+		p, err := ctrl.payments.LockPayment(ctx, payment.CustomerID, payment.ID)
+		if err != nil {
+			return err
+		}
+		completed, err = ctrl.settleRefunds(ctx, p, refunded, cutoff)
+		if err != nil || len(completed) == 0 {
+			return err
+		}
+
+		// Сумма проведенных возвратов изменилась: платеж читается заново
+		p, err = ctrl.payments.LockPayment(ctx, payment.CustomerID, payment.ID)
+		if err != nil {
+			return err
+		}
+		if p.Status == StatusCompleted && p.RefundedAmount.Cmp(p.Amount) >= 0 {
+			p.Status = StatusRefunded
+		}
+		*payment = *p
+		return ctrl.save(ctx, p)
+	})
+	if err != nil {
+		return err
+	}
+	for i := range completed {
+		ctrl.issueRefundReceipt(ctx, payment, &completed[i])
+	}
+	return nil
+}
+
+// settleRefunds сверяет ожидающие возвраты платежа p с суммой refunded, которую провайдер
+// вернул по транзакции: возвраты, покрытые ею сверх уже проведенных, проводятся в порядке
+// создания, остальные отклоняются. Возвраты, созданные позже cutoff, остаются в ожидании.
+// Возвращает проведенные возвраты. Вызывается в транзакции с заблокированным платежом.
+func (ctrl *Controller) settleRefunds(ctx context.Context, p *Payment, refunded money.Money, cutoff time.Time) ([]Refund, error) {
+	refunds, err := ctrl.payments.PaymentRefunds(ctx, p.CustomerID, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	covered := refunded.Sub(p.RefundedAmount)
+	var completed []Refund
+	for _, refund := range refunds {
+		if refund.Status != RefundPending {
+			continue
+		}
+		if created, err := time.Parse(time.RFC3339, refund.CreatedAt); err == nil && created.After(cutoff) {
+			continue
+		}
+		if refund.Amount.Cmp(covered) <= 0 {
+			refund.Status = RefundCompleted
+			covered = covered.Sub(refund.Amount)
+		} else {
+			refund.Status = RefundFailed
+		}
+		if err := ctrl.payments.UpdateRefund(ctx, &refund); err != nil {
+			return nil, err
+		}
+		if refund.Status == RefundCompleted {
+			completed = append(completed, refund)
+		}
+	}
+	return completed, nil
+}
+
+// providerOperation выполняет операцию провайдера над платежом из URL и сохраняет результат.
+// Если status не пуст, платеж должен находиться в этом статусе.
+func (ctrl *Controller) providerOperation(c *fiber.Ctx, status string, op func(ctx context.Context, p *Payment) (*ProviderResult, error)) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID платежа из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID"})
+	}
+
+	// Платеж должен принадлежать текущей компании
+	payment, err := ctrl.payments.GetPayment(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	if status != "" && payment.Status != status {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Payment must be %s, current status is %s", status, payment.Status),
+		})
+	}
+	// Операция выполняется у провайдера, проводившего платеж
+	if payment.Provider != "" && (payment.Provider != ctrl.provider.Name() || payment.TransactionID == "") {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment has no transaction with the configured provider"})
+	}
+	if payment.Provider == "" {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment was not processed by a payment provider"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), ProviderTimeout)
+	defer cancel()
+
+	result, err := op(ctx, payment)
+	switch {
+	case errors.Is(err, ErrProviderTimeout):
+		return c.Status(http.StatusGatewayTimeout).JSON(fiber.Map{"error": "Payment provider did not respond"})
+	case errors.Is(err, ErrInvalidOperation), errors.Is(err, ErrUnknownTransaction):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment provider rejected the operation"})
+	case err != nil:
+		return err
+	}
+
+	if err := ctrl.applyResult(c.UserContext(), payment, result); err != nil {
+		return err
+	}
+	ctrl.issueSaleReceipt(c.UserContext(), payment)
+	return c.JSON(paymentResult(payment, result.Message))
+}
+
+// HandleWebhook принимает уведомление платежного провайдера о статусе платежа.
+// Маршрут публичный: подлинность проверяется подписью, компания определяется по платежу.
+func (ctrl *Controller) HandleWebhook(c *fiber.Ctx) error {
+	name := c.Params("provider")
+	provider, ok := ctrl.provider.(WebhookProvider)
+	if !ok || name != ctrl.provider.Name() {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Unknown payment provider"})
+	}
+
+	// Тело сохраняется как есть, включая отклоненные уведомления
+	body := c.Body()
+	hook := Webhook{Provider: name, Payload: string(body)}
+
+	event, err := provider.ParseWebhook(func(key string) string { return c.Get(key) }, body)
+	if err != nil {
+		hook.Result = WebhookRejected
+		if err := ctrl.payments.SaveWebhook(c.UserContext(), &hook); err != nil {
+			return err
+		}
+		if errors.Is(err, ErrWebhookSignature) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid webhook signature"})
+		}
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid webhook payload"})
+	}
+
+	hook.EventID = event.ID
+	hook.TransactionID = event.TransactionID
+	hook.Status = event.Status
+	hook.PaymentID, _ = strconv.Atoi(event.Reference)
+
+	var refunds []Refund
+	err = ctrl.payments.ProcessWebhook(c.UserContext(), &hook, func(ctx context.Context, p *Payment) (bool, error) {
+		if !webhookCanApply(p.Status, event.Status) {
+			return false, nil
+		}
+		// Платеж возвращен полностью: возвраты, ожидающие ответа провайдера, проведены
+		if event.Status == StatusRefunded {
+			var err error
+			if refunds, err = ctrl.settleRefunds(ctx, p, p.Amount, time.Now()); err != nil {
+				return false, err
+			}
+		}
+		if p.TransactionID == "" {
+			p.TransactionID = event.TransactionID
+		}
+		if event.Status == StatusCompleted {
+			p.PaymentDate = time.Now().UTC().Format(time.RFC3339)
+		}
+		p.Status = event.Status
+		return true, ctrl.save(ctx, p)
+	})
+	if errors.Is(err, ErrDuplicateWebhook) {
+		// Провайдер повторил доставку: отвечаем успехом, чтобы он прекратил попытки
+		return c.JSON(fiber.Map{"result": "duplicate"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Платеж, проведенный по уведомлению, получает чек прихода
+	if hook.Result == WebhookProcessed && event.Status == StatusCompleted {
+		payment, err := ctrl.payments.GetPayment(c.UserContext(), hook.CustomerID, hook.PaymentID)
+		if err != nil {
+			return err
+		}
+		ctrl.issueSaleReceipt(c.UserContext(), payment)
+	}
+	if hook.Result == WebhookProcessed && len(refunds) > 0 {
+		payment, err := ctrl.payments.GetPayment(c.UserContext(), hook.CustomerID, hook.PaymentID)
+		if err != nil {
+			return err
+		}
+		for i := range refunds {
+			ctrl.issueRefundReceipt(c.UserContext(), payment, &refunds[i])
+		}
+	}
+
+	return c.JSON(fiber.Map{"result": hook.Result})
+}
+
+// applyResult сохраняет в платеже ответ провайдера
+func (ctrl *Controller) applyResult(ctx context.Context, payment *Payment, result *ProviderResult) error {
+	if result.TransactionID != "" {
+		payment.TransactionID = result.TransactionID
+	}
+	if result.Status == StatusCompleted && payment.Status != StatusCompleted {
+		payment.PaymentDate = time.Now().UTC().Format(time.RFC3339)
+	}
+	payment.Status = result.Status
+	return ctrl.payments.WithTx(ctx, func(ctx context.Context) error {
+		return ctrl.save(ctx, payment)
+	})
+}
+
+// create проверяет остаток к оплате заказа, сохраняет новый платеж
+// и пересчитывает оплату заказа в одной транзакции
+func (ctrl *Controller) create(ctx context.Context, payment *Payment, allowOverpayment bool) error {
+	return ctrl.payments.WithTx(ctx, func(ctx context.Context) error {
+		if err := ctrl.resolveCurrency(ctx, payment); err != nil {
+			return err
+		}
+		if err := ctrl.applyTax(ctx, payment); err != nil {
+			return err
+		}
+		if err := ctrl.checkBalance(ctx, payment, allowOverpayment); err != nil {
+			return err
+		}
+		shift, err := ctrl.currentShift(ctx, payment.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := shift.accepts(payment.PaymentMethod, payment.Currency); err != nil {
+			return err
+		}
+		payment.ShiftID = shift.ID
+		if err := ctrl.payments.CreatePayment(ctx, payment); err != nil {
+			return err
+		}
+		return ctrl.settle(ctx, payment.CustomerID, payment.OrderID)
+	})
+}
+
+// save сохраняет платеж и пересчитывает оплату его заказа.
+// Вызывается в транзакции (PaymentRepository.WithTx).
+func (ctrl *Controller) save(ctx context.Context, payment *Payment) error {
+	if err := ctrl.payments.UpdatePayment(ctx, payment); err != nil {
+		return err
+	}
+	return ctrl.settle(ctx, payment.CustomerID, payment.OrderID)
+}
+
+// resolveCurrency назначает валюту платежа. Платеж по заказу принимается только в валюте
+// заказа; платеж без заказа или по удаленному заказу - в указанной или базовой валюте компании.
+// Вызывается в транзакции: заказ блокируется до ее конца.
+func (ctrl *Controller) resolveCurrency(ctx context.Context, payment *Payment) error {
+	if payment.OrderID != 0 {
+		total, err := ctrl.orders.OrderTotal(ctx, payment.CustomerID, payment.OrderID)
+		if err != nil && !errors.Is(err, settlement.ErrOrderNotFound) {
+			return err
+		}
+		if err == nil {
+			if payment.Currency != "" && payment.Currency != total.Currency() {
+				return &CurrencyMismatchError{Currency: payment.Currency, Expected: total.Currency()}
+			}
+			payment.Currency = total.Currency()
+			return nil
+		}
+	}
+
+	code, err := currency.Resolve(ctx, ctrl.currencies, payment.CustomerID, payment.Currency)
+	if err != nil {
+		return err
+	}
+	payment.Currency = code
+	return nil
+}
+
+// applyTax рассчитывает налог в сумме платежа. Платеж по заказу делится между
+// налоговыми категориями пропорционально суммам позиций заказа; платеж без заказа
+// или по заказу без позиций считается в категории компании по умолчанию.
+func (ctrl *Controller) applyTax(ctx context.Context, payment *Payment) error {
+	if payment.OrderID != 0 {
+		items, err := ctrl.orders.OrderItems(ctx, payment.CustomerID, payment.OrderID)
+		if err != nil && !errors.Is(err, settlement.ErrOrderNotFound) {
+			return err
+		}
+		lines := make([]tax.Line, 0, len(items))
+		for _, item := range items {
+			lines = append(lines, tax.Line{
+				Category: item.TaxCategory,
+				Rate:     tax.Rate(item.TaxCategory),
+				Net:      item.Total.Sub(item.Tax),
+				Tax:      item.Tax,
+				Total:    item.Total,
+			})
+		}
+		payment.TaxBreakdown = tax.Allocate(tax.Summarize(lines), payment.Amount)
+		if len(payment.TaxBreakdown) > 0 {
+			return nil
+		}
+	}
+
+	settings, err := ctrl.taxes.Settings(ctx, payment.CustomerID)
+	if err != nil {
+		return err
+	}
+	payment.TaxBreakdown = []tax.Line{tax.Calculate(payment.Amount, 1, settings.DefaultCategory, true)}
+	return nil
+}
+
+// checkBalance проверяет, что платеж вместе с остальными действующими платежами заказа
+// не превышает сумму заказа. Превышение допускается только с allowOverpayment
+// и сохраняется в payment.Overpayment. Вызывается в транзакции: заказ блокируется до ее конца.
+func (ctrl *Controller) checkBalance(ctx context.Context, payment *Payment, allowOverpayment bool) error {
+	payment.Overpayment = money.Money{}
+	if payment.OrderID == 0 || !collecting(payment.Status) {
+		return nil
+	}
+
+	total, err := ctrl.orders.OrderTotal(ctx, payment.CustomerID, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	payments, err := ctrl.payments.OrderPayments(ctx, payment.CustomerID, payment.OrderID)
+	if err != nil {
+		return err
+	}
+
+	// Платежи в ожидании тоже занимают остаток: иначе два параллельных платежа могут оплатить заказ дважды
+	due := total
+	for _, p := range payments {
+		if p.ID != payment.ID && collecting(p.Status) {
+			due = due.Sub(p.Amount).Add(p.RefundedAmount)
+		}
+	}
+	due = money.Max(due, money.Money{})
+
+	if excess := payment.Amount.Sub(due); excess.IsPositive() {
+		if !allowOverpayment {
+			return &OverpaymentError{Outstanding: due}
+		}
+		payment.Overpayment = excess
+	}
+	return nil
+}
+
+// collecting сообщает, учитывается ли платеж в статусе status в оплате заказа
+func collecting(status string) bool {
+	return status == StatusPending || status == StatusAuthorized || status == StatusCompleted
+}
+
+// paymentError отвечает на ошибки проверки платежа по заказу
+func paymentError(c *fiber.Ctx, err error) error {
+	var overpayment *OverpaymentError
+	var mismatch *CurrencyMismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		return currencyMismatch(c, mismatch)
+	case errors.Is(err, ErrNoOpenShift):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "No open shift"})
+	case errors.Is(err, currency.ErrInvalidCurrency):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	case errors.As(err, &overpayment):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":       "Payment exceeds outstanding balance",
+			"outstanding": overpayment.Outstanding,
+		})
+	case errors.Is(err, settlement.ErrOrderNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	return err
+}
+
+// currencyMismatch отвечает на операцию не в той валюте
+func currencyMismatch(c *fiber.Ctx, err *CurrencyMismatchError) error {
+	return c.Status(http.StatusConflict).JSON(fiber.Map{
+		"error":    "Currency must be " + err.Expected,
+		"currency": err.Currency,
+		"expected": err.Expected,
+	})
+}
+
+// settle пересчитывает статус оплаты заказа по всем его платежам.
+// Итоги считаются заново, поэтому повторный вызов безопасен.
+func (ctrl *Controller) settle(ctx context.Context, customerID, orderID int) error {
+	if orderID == 0 {
+		return nil
+	}
+
+	payments, err := ctrl.payments.OrderPayments(ctx, customerID, orderID)
+	if err != nil {
+		return err
+	}
+
+	var summary settlement.Summary
+	for _, p := range payments {
+		switch p.Status {
+		case StatusCompleted:
+			summary.Paid = summary.Paid.Add(p.Amount).Sub(p.RefundedAmount)
+			summary.Refunded = summary.Refunded.Add(p.RefundedAmount)
+		case StatusRefunded:
+			// Платеж, возвращенный по уведомлению провайдера, может не иметь записей о возвратах
+			summary.Refunded = summary.Refunded.Add(p.Amount)
+		case StatusPending, StatusAuthorized:
+			summary.Pending = true
+		}
+	}
+
+	// Платеж может ссылаться на удаленный заказ: платеж при этом остается в Кассе
+	err = ctrl.orders.ApplyPayments(ctx, customerID, orderID, summary)
+	if errors.Is(err, settlement.ErrOrderNotFound) {
+		return nil
+	}
+	return err
+}
+
+// paymentResult - ответ кассы на операцию с платежом
+func paymentResult(payment *Payment, message string) fiber.Map {
+	result := fiber.Map{
+		"payment_id":     payment.ID,
+		"status":         payment.Status,
+		"transaction_id": payment.TransactionID,
+		"amount":         payment.Amount,
+	}
+	if !payment.Tendered.IsZero() {
+		result["tendered"] = payment.Tendered
+		result["change"] = payment.Change
+	}
+	if !payment.Overpayment.IsZero() {
+		result["overpayment"] = payment.Overpayment
+	}
+	if message != "" {
+		result["message"] = message
+	}
+	return result
+}
+
+// GetCashierStats возвращает статистику по кассе за период from - to с рядом по группам group
 func (ctrl *Controller) GetCashierStats(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения статистики из базы данных с фильтрацией по customerID
-	stats := CashierStats{
-		TotalRevenue:     125000.0,
-		TodaysRevenue:    1500.0,
-		TotalTransactions: 50,
-		TodaysTransactions: 5,
-		RefundAmount:     2500.0,
-	}
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	stats, err := ctrl.cashierStats(c.UserContext(), customerID, period)
+	var noRate *currency.NoRateError
+	if errors.As(err, &noRate) {
+		return currency.NoRate(c, noRate)
+	}
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(stats)
-}
\ No newline at end of file
+}
+
+// cashierStats подводит итоги по проведенным платежам и возвратам компании в ее базовой
+// валюте за период: платежи относятся к дате платежа, возвраты - к дате возврата, и каждая
+// сумма пересчитывается по курсу на свою дату. Выручка считается за вычетом возвратов.
+func (ctrl *Controller) cashierStats(ctx context.Context, customerID int, period report.Range) (*CashierStats, error) {
+	payments, err := ctrl.payments.ListPayments(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := ctrl.payments.ListRefunds(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := ctrl.currencies.Converter(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().UTC().Format(currency.DateLayout)
+	stats := &CashierStats{Period: period.Period(), Currency: conv.Base()}
+	points := make(map[string]*CashierStatsPoint)
+	var bounds report.Bounds
+
+	// add относит к моменту on платежи и суммы поступлений и возвратов в базовой валюте
+	add := func(on time.Time, transactions int, received, refunded money.Money) {
+		revenue := received.Sub(refunded)
+		if on.UTC().Format(currency.DateLayout) == today {
+			stats.TodaysTransactions += transactions
+			stats.TodaysRevenue = stats.TodaysRevenue.Add(revenue)
+		}
+		if !period.Contains(on) {
+			return
+		}
+
+		label := period.Label(on)
+		point, ok := points[label]
+		if !ok {
+			point = &CashierStatsPoint{Period: label}
+			points[label] = point
+		}
+		bounds.Add(on)
+
+		stats.TotalTransactions += transactions
+		stats.TotalRevenue = stats.TotalRevenue.Add(revenue)
+		stats.RefundAmount = stats.RefundAmount.Add(refunded)
+		point.Transactions += transactions
+		point.Revenue = point.Revenue.Add(revenue)
+		point.Refunds = point.Refunds.Add(refunded)
+	}
+
+	for _, p := range payments {
+		if p.Status != StatusCompleted && p.Status != StatusRefunded {
+			continue
+		}
+
+		date := p.PaymentDate
+		if date == "" {
+			date = p.CreatedAt
+		}
+		on := currency.At(date)
+		received, err := conv.ToBase(p.Amount.In(p.Currency), on)
+		if err != nil {
+			return nil, err
+		}
+		add(on, 1, received, money.Money{})
+
+		// Платеж, возвращенный по уведомлению провайдера без записей возвратов,
+		// считается возвращенным в момент последнего изменения
+		if p.Status == StatusRefunded && p.RefundedAmount.Cmp(p.Amount) < 0 {
+			on := currency.At(p.UpdatedAt)
+			refunded, err := conv.ToBase(p.Amount.Sub(p.RefundedAmount).In(p.Currency), on)
+			if err != nil {
+				return nil, err
+			}
+			add(on, 0, money.Money{}, refunded)
+		}
+	}
+
+	for _, refund := range refunds {
+		if refund.Status != RefundCompleted {
+			continue
+		}
+		on := currency.At(refund.CreatedAt)
+		refunded, err := conv.ToBase(refund.Amount.In(refund.Currency), on)
+		if err != nil {
+			return nil, err
+		}
+		add(on, 0, money.Money{}, refunded)
+	}
+
+	stats.Series = []CashierStatsPoint{}
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		point := CashierStatsPoint{Period: label}
+		if found, ok := points[label]; ok {
+			point = *found
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	return stats, nil
+}
+
+// currentShift возвращает открытую смену компании; пустую смену, если смена не открыта
+func (ctrl *Controller) currentShift(ctx context.Context, customerID int) (*Shift, error) {
+	shift, err := ctrl.shifts.CurrentShift(ctx, customerID)
+	if errors.Is(err, ErrNoOpenShift) {
+		return &Shift{}, nil
+	}
+	return shift, err
+}
+
+// shiftReport подводит итоги смены
+func (ctrl *Controller) shiftReport(ctx context.Context, kind string, shift *Shift) (*ShiftReport, error) {
+	payments, err := ctrl.shifts.ShiftPayments(ctx, shift.CustomerID, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := ctrl.shifts.ShiftRefunds(ctx, shift.CustomerID, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := ctrl.shifts.ShiftOperations(ctx, shift.CustomerID, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newShiftReport(kind, *shift, payments, refunds, operations), nil
+}
+
+// GetShifts возвращает смены компании, начиная с последней
+func (ctrl *Controller) GetShifts(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	shifts, err := ctrl.shifts.ListShifts(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(shifts)
+}
+
+// GetCurrentShift возвращает открытую смену
+func (ctrl *Controller) GetCurrentShift(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	shift, err := ctrl.shifts.CurrentShift(c.UserContext(), customerID)
+	if errors.Is(err, ErrNoOpenShift) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No open shift"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(shift)
+}
+
+// OpenShift открывает смену с разменом в кассе
+func (ctrl *Controller) OpenShift(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Тело запроса необязательно: без него смена открывается без размена
+	var req OpenShiftRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if req.OpeningFloat.IsNegative() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Opening float cannot be negative"})
+	}
+
+	// Смена ведется в базовой валюте компании: в ней учитываются наличные в кассе
+	shift := Shift{CustomerID: customerID, OpeningFloat: req.OpeningFloat}
+	shift.Currency, err = ctrl.currencies.BaseCurrency(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	if claims, ok := auth.CurrentClaims(c); ok {
+		shift.OpenedBy = claims.UserID()
+	}
+	err = ctrl.shifts.OpenShift(c.UserContext(), &shift)
+	if errors.Is(err, ErrShiftOpen) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "A shift is already open"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(shift)
+}
+
+// CashIn вносит наличные в кассу
+func (ctrl *Controller) CashIn(c *fiber.Ctx) error {
+	return ctrl.cashOperation(c, CashIn)
+}
+
+// CashOut изымает наличные из кассы. Изъять больше, чем есть в кассе по учету, нельзя.
+func (ctrl *Controller) CashOut(c *fiber.Ctx) error {
+	return ctrl.cashOperation(c, CashOut)
+}
+
+// cashOperation записывает внесение или изъятие наличных в открытой смене
+func (ctrl *Controller) cashOperation(c *fiber.Ctx, kind string) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var req CashOperationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !req.Amount.IsPositive() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be positive"})
+	}
+
+	op := CashOperation{CustomerID: customerID, Type: kind, Amount: req.Amount, Reason: req.Reason}
+	if claims, ok := auth.CurrentClaims(c); ok {
+		op.CreatedBy = claims.UserID()
+	}
+
+	var available money.Money
+	err = ctrl.payments.WithTx(c.UserContext(), func(ctx context.Context) error {
+		shift, err := ctrl.shifts.CurrentShift(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		if kind == CashOut {
+			report, err := ctrl.shiftReport(ctx, ReportX, shift)
+			if err != nil {
+				return err
+			}
+			if req.Amount.Cmp(report.ExpectedCash) > 0 {
+				available = report.ExpectedCash
+				return errInsufficientCash
+			}
+		}
+		op.ShiftID = shift.ID
+		op.Currency = shift.Currency
+		return ctrl.shifts.AddCashOperation(ctx, &op)
+	})
+	switch {
+	case errors.Is(err, ErrNoOpenShift):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Open a shift first"})
+	case errors.Is(err, errInsufficientCash):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":     "Not enough cash in the register",
+			"available": available,
+		})
+	case err != nil:
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(op)
+}
+
+// errInsufficientCash - в кассе меньше наличных, чем запрошено к изъятию
+var errInsufficientCash = errors.New("cashier: not enough cash in the register")
+
+// GetXReport возвращает X-отчет по открытой смене
+func (ctrl *Controller) GetXReport(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	shift, err := ctrl.shifts.CurrentShift(c.UserContext(), customerID)
+	if errors.Is(err, ErrNoOpenShift) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "No open shift"})
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := ctrl.shiftReport(c.UserContext(), ReportX, shift)
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// CloseShift закрывает открытую смену по пересчитанным наличным и возвращает Z-отчет
+func (ctrl *Controller) CloseShift(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var req CloseShiftRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.CountedCash == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Counted cash is required"})
+	}
+	if req.CountedCash.IsNegative() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Counted cash cannot be negative"})
+	}
+
+	closedBy := 0
+	if claims, ok := auth.CurrentClaims(c); ok {
+		closedBy = claims.UserID()
+	}
+
+	// Итоги считаются под блокировкой смены, поэтому в Z-отчет попадают все ее платежи
+	shift, err := ctrl.shifts.CloseShift(c.UserContext(), customerID, func(ctx context.Context, shift *Shift) error {
+		report, err := ctrl.shiftReport(ctx, ReportZ, shift)
+		if err != nil {
+			return err
+		}
+		shift.ExpectedCash = report.ExpectedCash
+		shift.CountedCash = *req.CountedCash
+		shift.Discrepancy = req.CountedCash.Sub(report.ExpectedCash)
+		shift.ClosedBy = closedBy
+		return nil
+	})
+	if errors.Is(err, ErrNoOpenShift) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "No open shift"})
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := ctrl.shiftReport(c.UserContext(), ReportZ, shift)
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// GetShiftReport возвращает отчет по смене: Z-отчет по закрытой смене или X-отчет по открытой
+func (ctrl *Controller) GetShiftReport(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID смены из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid shift ID"})
+	}
+
+	shift, err := ctrl.shifts.GetShift(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrShiftNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Shift not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	kind := ReportX
+	if shift.Status == ShiftClosed {
+		kind = ReportZ
+	}
+	report, err := ctrl.shiftReport(c.UserContext(), kind, shift)
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// newReceipt создает чек по платежу на сумму amount; позиции заполняет вызывающий
+func (ctrl *Controller) newReceipt(p *Payment, sign string, amount money.Money) *Receipt {
+	receipt := &Receipt{
+		CustomerID: p.CustomerID,
+		PaymentID:  p.ID,
+		ShiftID:    p.ShiftID,
+		Currency:   p.Currency,
+		Kind:       KindReceipt,
+		Sign:       sign,
+		Taxation:   ctrl.fiscal.Taxation,
+		Total:      amount,
+		TaxBreakdown: taxShares(p, amount),
+	}
+	if p.PaymentMethod == MethodCash {
+		receipt.Cash = amount
+	} else {
+		receipt.Electronic = amount
+	}
+	return receipt
+}
+
+// taxShares возвращает налог в части amount платежа по разбивке платежа. Платеж,
+// принятый до расчета налогов, считается без НДС.
+func taxShares(p *Payment, amount money.Money) []tax.Line {
+	if shares := tax.Allocate(p.TaxBreakdown, amount); len(shares) > 0 {
+		return shares
+	}
+	return []tax.Line{tax.Calculate(amount, 1, tax.Exempt, true)}
+}
+
+// vat возвращает ставку НДС позиции чека по налоговой категории. При предоплате
+// и авансе налог выделяется по расчетной ставке (20/120, 10/110).
+func vat(category, method string) string {
+	advance := method == MethodFullPrepayment || method == MethodPrepayment || method == MethodAdvance
+	switch {
+	case advance && category == tax.VAT20:
+		return VAT120
+	case advance && category == tax.VAT10:
+		return VAT110
+	}
+	return category
+}
+
+// shareItems возвращает по одной позиции чека на каждую строку разбивки по налогам
+func shareItems(name string, shares []tax.Line, method, object string) []ReceiptItem {
+	items := make([]ReceiptItem, 0, len(shares))
+	for _, share := range shares {
+		items = append(items, ReceiptItem{
+			Name:          name,
+			Quantity:      1,
+			Price:         share.Total,
+			Total:         share.Total,
+			VAT:           vat(share.Category, method),
+			PaymentMethod: method,
+			PaymentObject: object,
+		})
+	}
+	return items
+}
+
+// receiptItems возвращает позиции чека прихода по платежу на сумму amount. Если сумма
+// совпадает с суммой заказа, в чек попадают позиции заказа с полным расчетом и ставками
+// НДС их категорий, иначе - позиции предоплаты по заказу, по одной на налоговую категорию.
+// Платеж без заказа оформляется так же, позициями по категориям его разбивки.
+func (ctrl *Controller) receiptItems(ctx context.Context, p *Payment, amount money.Money) ([]ReceiptItem, error) {
+	name := fmt.Sprintf("Оплата по платежу №%d", p.ID)
+	method, object := MethodFullPayment, ObjectCommodity
+
+	if p.OrderID != 0 {
+		items, err := ctrl.orders.OrderItems(ctx, p.CustomerID, p.OrderID)
+		if err != nil && !errors.Is(err, settlement.ErrOrderNotFound) {
+			return nil, err
+		}
+
+		var total money.Money
+		for _, item := range items {
+			total = total.Add(item.Total)
+		}
+		if len(items) > 0 && total.Equal(amount) {
+			receiptItems := make([]ReceiptItem, 0, len(items))
+			for _, item := range items {
+				receiptItems = append(receiptItems, orderItemLines(item)...)
+			}
+			return receiptItems, nil
+		}
+
+		name = fmt.Sprintf("Предоплата по заказу №%d", p.OrderID)
+		method, object = MethodPrepayment, ObjectPayment
+	}
+
+	return shareItems(name, taxShares(p, amount), method, object), nil
+}
+
+// orderItemLines возвращает позиции чека полного расчета по позиции заказа. Если итог позиции
+// со скидкой не делится на количество без остатка, позиция делится на две: все единицы,
+// кроме одной, по цене позиции и последняя единица с остатком.
+func orderItemLines(item settlement.Item) []ReceiptItem {
+	line := ReceiptItem{
+		Name:          item.Name,
+		Quantity:      float64(item.Quantity),
+		Price:         item.Price,
+		Total:         item.Total,
+		VAT:           vat(item.TaxCategory, MethodFullPayment),
+		PaymentMethod: MethodFullPayment,
+		PaymentObject: ObjectCommodity,
+	}
+	if item.Quantity <= 1 || item.Price.Mul(int64(item.Quantity)).Equal(item.Total) {
+		return []ReceiptItem{line}
+	}
+
+	line.Quantity = float64(item.Quantity - 1)
+	line.Total = item.Price.Mul(int64(item.Quantity - 1))
+	last := line
+	last.Quantity = 1
+	last.Price = item.Total.Sub(line.Total)
+	last.Total = last.Price
+	return []ReceiptItem{line, last}
+}
+
+// issueSaleReceipt выдает чек прихода по проведенному платежу, если он еще не выдан.
+// Ошибки чека не отменяют платеж: чек остается в статусе failed и регистрируется повторно.
+func (ctrl *Controller) issueSaleReceipt(ctx context.Context, p *Payment) {
+	if p.Status != StatusCompleted {
+		return
+	}
+	receipt := ctrl.newReceipt(p, SignIncome, p.Amount)
+	items, err := ctrl.receiptItems(ctx, p, p.Amount)
+	if err == nil {
+		receipt.Items = items
+		err = ctrl.fiscalize(ctx, receipt)
+	}
+	if err != nil && !errors.Is(err, ErrReceiptExists) {
+		log.Printf("cashier: receipt for payment %d: %v", p.ID, err)
+	}
+}
+
+// issueRefundReceipt выдает чек возврата прихода по проведенному возврату. Позиции
+// повторяют чек прихода платежа: при полном возврате - все позиции, при частичном -
+// по позиции на налоговую категорию с тем же способом расчета.
+func (ctrl *Controller) issueRefundReceipt(ctx context.Context, p *Payment, refund *Refund) {
+	if refund.Status != RefundCompleted {
+		return
+	}
+	receipt := ctrl.newReceipt(p, SignIncomeRefund, refund.Amount)
+	receipt.RefundID = refund.ID
+	receipt.ShiftID = refund.ShiftID
+
+	err := ctrl.refundReceiptItems(ctx, p, receipt)
+	if err == nil {
+		err = ctrl.fiscalize(ctx, receipt)
+	}
+	if err != nil && !errors.Is(err, ErrReceiptExists) {
+		log.Printf("cashier: receipt for refund %d of payment %d: %v", refund.ID, p.ID, err)
+	}
+}
+
+// refundReceiptItems заполняет позиции чека возврата по чеку прихода платежа
+func (ctrl *Controller) refundReceiptItems(ctx context.Context, p *Payment, receipt *Receipt) error {
+	receipts, err := ctrl.receipts.PaymentReceipts(ctx, p.CustomerID, p.ID)
+	if err != nil {
+		return err
+	}
+	var sale *Receipt
+	for i := range receipts {
+		if receipts[i].Kind == KindReceipt && receipts[i].Sign == SignIncome && len(receipts[i].Items) > 0 {
+			sale = &receipts[i]
+		}
+	}
+
+	// Платеж без чека прихода оформляется так же, как оформлялся бы его приход
+	if sale == nil {
+		items, err := ctrl.receiptItems(ctx, p, receipt.Total)
+		receipt.Items = items
+		return err
+	}
+	if sale.Total.Equal(receipt.Total) {
+		receipt.Items = sale.Items
+		return nil
+	}
+
+	name := fmt.Sprintf("Частичный возврат по платежу №%d", p.ID)
+	if sale.Fiscal.DocumentNumber != 0 {
+		name = fmt.Sprintf("Частичный возврат по чеку ФД №%d", sale.Fiscal.DocumentNumber)
+	}
+	receipt.Items = shareItems(name, receipt.TaxBreakdown, sale.Items[0].PaymentMethod, sale.Items[0].PaymentObject)
+	return nil
+}
+
+// fiscalize сохраняет новый чек и регистрирует его в фискальном накопителе
+func (ctrl *Controller) fiscalize(ctx context.Context, receipt *Receipt) error {
+	receipt.Status = ReceiptPending
+	if err := ctrl.receipts.CreateReceipt(ctx, receipt); err != nil {
+		return err
+	}
+	return ctrl.register(ctx, receipt)
+}
+
+// register регистрирует сохраненный чек и сохраняет результат: фискальные реквизиты
+// или причину отказа
+func (ctrl *Controller) register(ctx context.Context, receipt *Receipt) error {
+	driverCtx, cancel := context.WithTimeout(ctx, ProviderTimeout)
+	defer cancel()
+
+	data, err := ctrl.fiscal.Driver.Register(driverCtx, receipt)
+	if err != nil {
+		receipt.Status = ReceiptFailed
+		receipt.Error = err.Error()
+	} else {
+		receipt.Status = ReceiptRegistered
+		receipt.Error = ""
+		receipt.Fiscal = *data
+	}
+	return ctrl.receipts.UpdateReceipt(ctx, receipt)
+}
+
+// GetReceipts возвращает чеки компании
+func (ctrl *Controller) GetReceipts(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	receipts, err := ctrl.receipts.ListReceipts(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(receipts)
+}
+
+// GetReceipt возвращает чек
+func (ctrl *Controller) GetReceipt(c *fiber.Ctx) error {
+	receipt, err := ctrl.receiptParam(c)
+	if err != nil || receipt == nil {
+		return err
+	}
+	return c.JSON(receipt)
+}
+
+// GetPaymentReceipts возвращает чеки по платежу: чек прихода и чеки возвратов
+func (ctrl *Controller) GetPaymentReceipts(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID платежа из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID"})
+	}
+
+	receipts, err := ctrl.receipts.PaymentReceipts(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Payment not found"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(receipts)
+}
+
+// RegisterReceipt повторно регистрирует чек, регистрация которого не удалась
+func (ctrl *Controller) RegisterReceipt(c *fiber.Ctx) error {
+	receipt, err := ctrl.receiptParam(c)
+	if err != nil || receipt == nil {
+		return err
+	}
+	if receipt.Status == ReceiptRegistered {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Receipt is already registered"})
+	}
+
+	if err := ctrl.register(c.UserContext(), receipt); err != nil {
+		return err
+	}
+	return c.JSON(receipt)
+}
+
+// receiptParam возвращает чек по ID из параметров URL. Если чек не найден,
+// ответ уже отправлен и возвращается nil.
+func (ctrl *Controller) receiptParam(c *fiber.Ctx) (*Receipt, error) {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Получаем ID чека из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid receipt ID"})
+	}
+
+	receipt, err := ctrl.receipts.GetReceipt(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrReceiptNotFound) {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Receipt not found"})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// CreateCorrection выдает чек коррекции: исправляет расчет, проведенный без чека
+// или с ошибкой, в том числе возврат
+func (ctrl *Controller) CreateCorrection(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	var req CorrectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if !req.Amount.IsPositive() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Amount must be positive"})
+	}
+	if req.Sign != SignIncome && req.Sign != SignIncomeRefund {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Sign must be income or income_refund"})
+	}
+	if req.Correction.Type != CorrectionSelf && req.Correction.Type != CorrectionInstruction {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Correction type must be self or instruction"})
+	}
+
+	settings, err := ctrl.taxes.Settings(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	category, err := settings.Category(req.TaxCategory)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tax category"})
+	}
+
+	name := "Коррекция прихода"
+	if req.Sign == SignIncomeRefund {
+		name = "Коррекция возврата прихода"
+	}
+	correction := req.Correction
+	receipt := Receipt{
+		CustomerID: customerID,
+		Kind:       KindCorrection,
+		Sign:       req.Sign,
+		Taxation:   ctrl.fiscal.Taxation,
+		Items: []ReceiptItem{{
+			Name:          name,
+			Quantity:      1,
+			Price:         req.Amount,
+			Total:         req.Amount,
+			VAT:           vat(category, MethodFullPayment),
+			PaymentMethod: MethodFullPayment,
+			PaymentObject: ObjectCommodity,
+		}},
+		Total:      req.Amount,
+		TaxBreakdown: []tax.Line{tax.Calculate(req.Amount, 1, category, true)},
+		Correction: &correction,
+	}
+	receipt.Currency, err = currency.Resolve(c.UserContext(), ctrl.currencies, customerID, req.Currency)
+	if errors.Is(err, currency.ErrInvalidCurrency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
+	if err != nil {
+		return err
+	}
+	if req.PaymentMethod == MethodCash {
+		receipt.Cash = req.Amount
+	} else {
+		receipt.Electronic = req.Amount
+	}
+
+	shift, err := ctrl.currentShift(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	receipt.ShiftID = shift.ID
+	if err := ctrl.fiscalize(c.UserContext(), &receipt); err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(receipt)
+}
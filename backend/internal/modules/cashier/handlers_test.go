@@ -0,0 +1,194 @@
+package cashier
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
+)
+
+// testApp собирает маршруты Кассы на хранилище в памяти с mock-провайдером и эмулятором
+// фискального накопителя. Платежи в тестах не привязаны к заказам. Компания запроса
+// берется из заголовка X-Customer-ID, вызывающий - владелец компании.
+func testApp() *fiber.App {
+	repo := NewMemoryRepository()
+	fiscal := Fiscalization{Driver: NewFiscalEmulator(), Taxation: TaxationUSNIncome}
+	ctrl := NewController(repo, repo, repo, NewMockProvider(MockConfig{}), fiscal, nil,
+		currency.NewService(currency.NewMemoryStore()), tax.NewService(tax.NewMemoryStore()))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		customerID, err := strconv.Atoi(c.Get("X-Customer-ID"))
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+		tenant.SetCustomerID(c, customerID)
+		rbac.SetRole(c, rbac.RoleOwner)
+		return c.Next()
+	})
+	NewModule(ctrl).RegisterRoutes(app)
+	return app
+}
+
+// call выполняет запрос от имени компании customerID и разбирает JSON ответа в out, если он не nil
+func call(t *testing.T, app *fiber.App, customerID int, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Customer-ID", strconv.Itoa(customerID))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// processResult - ответ на проведение платежа
+type processResult struct {
+	ID            int    `json:"payment_id"`
+	Status        string `json:"status"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// process проводит платеж компании customerID без заказа
+func process(t *testing.T, app *fiber.App, customerID int, method string, amount int) processResult {
+	t.Helper()
+
+	var result processResult
+	body := fiber.Map{"amount": amount, "payment_method": method}
+	if code := call(t, app, customerID, http.MethodPost, "/process", body, &result); code != http.StatusOK {
+		t.Fatalf("process %s payment: status %d", method, code)
+	}
+	return result
+}
+
+func TestProcessAndRefund(t *testing.T) {
+	app := testApp()
+
+	if code := call(t, app, 1, http.MethodPost, "/shifts/open", nil, nil); code != http.StatusCreated {
+		t.Fatalf("open shift: status %d", code)
+	}
+	payment := process(t, app, 1, MethodCash, 100)
+	if payment.ID == 0 || payment.Status != StatusCompleted {
+		t.Fatalf("process: unexpected payment %+v", payment)
+	}
+	card := process(t, app, 1, "card", 50)
+	if card.Status != StatusCompleted || card.TransactionID == "" {
+		t.Fatalf("process card: unexpected payment %+v", card)
+	}
+
+	path := "/refund/" + strconv.Itoa(payment.ID)
+	if code := call(t, app, 1, http.MethodPost, path, fiber.Map{"amount": 30}, nil); code != http.StatusOK {
+		t.Fatalf("partial refund: status %d", code)
+	}
+	if code := call(t, app, 1, http.MethodPost, path, fiber.Map{"amount": 80}, nil); code != http.StatusConflict {
+		t.Fatalf("refund over payment amount: status %d, want 409", code)
+	}
+
+	var refunds []Refund
+	call(t, app, 1, http.MethodGet, "/payments/"+strconv.Itoa(payment.ID)+"/refunds", nil, &refunds)
+	if len(refunds) != 1 || refunds[0].Amount.Minor() != 3000 {
+		t.Fatalf("refunds: unexpected %+v", refunds)
+	}
+}
+
+func TestPaymentTenantIsolation(t *testing.T) {
+	app := testApp()
+
+	call(t, app, 1, http.MethodPost, "/shifts/open", nil, nil)
+	cash := process(t, app, 1, MethodCash, 100)
+	card := process(t, app, 1, "card", 100)
+	cashPath := "/payments/" + strconv.Itoa(cash.ID)
+	cardPath := "/payments/" + strconv.Itoa(card.ID)
+
+	var receipts []Receipt
+	call(t, app, 1, http.MethodGet, "/receipts", nil, &receipts)
+	if len(receipts) == 0 {
+		t.Fatal("no sale receipts issued")
+	}
+	receiptPath := "/receipts/" + strconv.Itoa(receipts[0].ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"update", http.MethodPut, cashPath, fiber.Map{"amount": 1, "payment_method": MethodCash}},
+		{"refund", http.MethodPost, "/refund/" + strconv.Itoa(cash.ID), nil},
+		{"refunds", http.MethodGet, cashPath + "/refunds", nil},
+		{"payment receipts", http.MethodGet, cashPath + "/receipts", nil},
+		{"sync", http.MethodPost, cardPath + "/sync", nil},
+		{"void", http.MethodPost, cardPath + "/void", nil},
+		{"receipt", http.MethodGet, receiptPath, nil},
+		{"register receipt", http.MethodPost, receiptPath + "/register", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, app, 2, tt.method, tt.path, tt.body, nil); code != http.StatusNotFound {
+				t.Fatalf("status %d, want 404", code)
+			}
+		})
+	}
+
+	var payments []Payment
+	call(t, app, 2, http.MethodGet, "/payments", nil, &payments)
+	var others []Receipt
+	call(t, app, 2, http.MethodGet, "/receipts", nil, &others)
+	if len(payments) != 0 || len(others) != 0 {
+		t.Fatalf("other company sees %d payments and %d receipts", len(payments), len(others))
+	}
+	if code := call(t, app, 2, http.MethodGet, "/shifts/current", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("other company current shift: status %d, want 404", code)
+	}
+}
+
+func TestCashRequiresOpenShift(t *testing.T) {
+	app := testApp()
+
+	// Безналичные платежи проводятся и вне смены
+	card := process(t, app, 1, "card", 100)
+	if code := call(t, app, 1, http.MethodPost, "/refund/"+strconv.Itoa(card.ID), fiber.Map{"amount": 10}, nil); code != http.StatusOK {
+		t.Fatalf("card refund without shift: status %d", code)
+	}
+
+	body := fiber.Map{"amount": 100, "payment_method": MethodCash}
+	if code := call(t, app, 1, http.MethodPost, "/process", body, nil); code != http.StatusConflict {
+		t.Fatalf("cash payment without shift: status %d, want 409", code)
+	}
+	if code := call(t, app, 1, http.MethodPost, "/payments", body, nil); code != http.StatusConflict {
+		t.Fatalf("manual cash payment without shift: status %d, want 409", code)
+	}
+
+	call(t, app, 1, http.MethodPost, "/shifts/open", nil, nil)
+	cash := process(t, app, 1, MethodCash, 100)
+	if code := call(t, app, 1, http.MethodPost, "/shifts/close", fiber.Map{"counted_cash": 100}, nil); code != http.StatusOK {
+		t.Fatalf("close shift: status %d", code)
+	}
+	if code := call(t, app, 1, http.MethodPost, "/refund/"+strconv.Itoa(cash.ID), nil, nil); code != http.StatusConflict {
+		t.Fatalf("cash refund without shift: status %d, want 409", code)
+	}
+}
@@ -0,0 +1,503 @@
+package cashier
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kit8-backend/internal/core/money"
+)
+
+// MemoryRepository - потокобезопасная реализация PaymentRepository, ShiftRepository
+// и ReceiptRepository в памяти процесса.
+// Как и в PostgreSQL, ID назначаются общей последовательностью для всех компаний,
+// а платежи других компаний не видны.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	payments map[int]Payment
+	nextID   int
+
+	webhooks      []Webhook
+	webhookEvents map[[2]string]bool // Сохраненные пары (провайдер, ID события)
+
+	refunds      map[int]Refund
+	nextRefundID int
+
+	shifts      map[int]Shift
+	nextShiftID int
+	operations  []CashOperation
+
+	receipts []Receipt
+}
+
+// NewMemoryRepository создает пустой репозиторий платежей в памяти
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		payments:      make(map[int]Payment),
+		webhookEvents: make(map[[2]string]bool),
+		refunds:       make(map[int]Refund),
+		shifts:        make(map[int]Shift),
+	}
+}
+
+// withRefunds заполняет сумму проведенных возвратов платежа, как это делает PostgreSQL.
+// Вызывается под блокировкой.
+func (r *MemoryRepository) withRefunds(p Payment) Payment {
+	var refunded money.Money
+	for _, refund := range r.refunds {
+		if refund.PaymentID == p.ID && refund.Status == RefundCompleted {
+			refunded = refunded.Add(refund.Amount)
+		}
+	}
+	p.RefundedAmount = refunded
+	return p
+}
+
+func (r *MemoryRepository) ListPayments(ctx context.Context, customerID int) ([]Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payments := []Payment{}
+	for _, p := range r.payments {
+		if p.CustomerID == customerID {
+			payments = append(payments, r.withRefunds(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+func (r *MemoryRepository) GetPayment(ctx context.Context, customerID, id int) (*Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.payments[id]
+	if !ok || p.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	p = r.withRefunds(p)
+	return &p, nil
+}
+
+func (r *MemoryRepository) LockPayment(ctx context.Context, customerID, id int) (*Payment, error) {
+	return r.GetPayment(ctx, customerID, id)
+}
+
+func (r *MemoryRepository) CreatePayment(ctx context.Context, p *Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextID++
+	p.ID = r.nextID
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	p.RefundedAmount = money.Money{}
+	r.payments[p.ID] = *p
+	return nil
+}
+
+func (r *MemoryRepository) UpdatePayment(ctx context.Context, p *Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.payments[p.ID]
+	if !ok || existing.CustomerID != p.CustomerID {
+		return ErrNotFound
+	}
+	p.CreatedAt = existing.CreatedAt
+	p.ShiftID = existing.ShiftID // Смена назначается только при создании платежа
+	p.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	*p = r.withRefunds(*p)
+	r.payments[p.ID] = *p
+	return nil
+}
+
+// saveWebhook сохраняет уведомление. Вызывается под блокировкой.
+func (r *MemoryRepository) saveWebhook(hook *Webhook) error {
+	if hook.EventID != "" {
+		key := [2]string{hook.Provider, hook.EventID}
+		if r.webhookEvents[key] {
+			return ErrDuplicateWebhook
+		}
+		r.webhookEvents[key] = true
+	}
+	hook.ID = len(r.webhooks) + 1
+	hook.ReceivedAt = time.Now().UTC().Format(time.RFC3339)
+	r.webhooks = append(r.webhooks, *hook)
+	return nil
+}
+
+func (r *MemoryRepository) SaveWebhook(ctx context.Context, hook *Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.saveWebhook(hook)
+}
+
+func (r *MemoryRepository) ProcessWebhook(ctx context.Context, hook *Webhook, apply func(ctx context.Context, p *Payment) (bool, error)) error {
+	found, err := r.findWebhookPayment(hook)
+	if err != nil {
+		return err
+	}
+
+	// apply сохраняет платеж через методы репозитория, поэтому вызывается без блокировки
+	if found == nil {
+		hook.PaymentID = 0
+		hook.Result = WebhookNoPayment
+	} else {
+		hook.PaymentID = found.ID
+		hook.CustomerID = found.CustomerID
+		hook.Result = WebhookIgnored
+		applied, err := apply(ctx, found)
+		if err != nil {
+			return err
+		}
+		if applied {
+			hook.Result = WebhookProcessed
+		}
+	}
+
+	r.mu.Lock()
+	r.webhooks[hook.ID-1] = *hook
+	r.mu.Unlock()
+	return nil
+}
+
+// findWebhookPayment сохраняет уведомление и, как и PostgreSQL, ищет его платеж
+// по транзакции, затем по ID платежа
+func (r *MemoryRepository) findWebhookPayment(hook *Webhook) (*Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.saveWebhook(hook); err != nil {
+		return nil, err
+	}
+
+	var found *Payment
+	for _, p := range r.payments {
+		p := r.withRefunds(p)
+		if p.Provider != hook.Provider {
+			continue
+		}
+		if hook.TransactionID != "" && p.TransactionID == hook.TransactionID {
+			return &p, nil
+		}
+		if p.ID == hook.PaymentID && (p.TransactionID == "" || p.TransactionID == hook.TransactionID) {
+			found = &p
+		}
+	}
+	return found, nil
+}
+
+func (r *MemoryRepository) OrderPayments(ctx context.Context, customerID, orderID int) ([]Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payments := []Payment{}
+	for _, p := range r.payments {
+		if p.CustomerID == customerID && p.OrderID == orderID {
+			payments = append(payments, r.withRefunds(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (r *MemoryRepository) CreateRefund(ctx context.Context, refund *Refund, check func(p *Payment, reserved money.Money) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.payments[refund.PaymentID]
+	if !ok || p.CustomerID != refund.CustomerID {
+		return ErrNotFound
+	}
+	p = r.withRefunds(p)
+
+	// Незавершенные возвраты тоже занимают сумму платежа
+	var reserved money.Money
+	for _, existing := range r.refunds {
+		if existing.PaymentID == p.ID && existing.Status != RefundFailed {
+			reserved = reserved.Add(existing.Amount)
+		}
+	}
+	if err := check(&p, reserved); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextRefundID++
+	refund.ID = r.nextRefundID
+	refund.PaymentMethod = p.PaymentMethod
+	refund.Currency = p.Currency
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+	r.refunds[refund.ID] = *refund
+	return nil
+}
+
+func (r *MemoryRepository) UpdateRefund(ctx context.Context, refund *Refund) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.refunds[refund.ID]
+	if !ok || existing.CustomerID != refund.CustomerID {
+		return ErrNotFound
+	}
+	existing.Status = refund.Status
+	existing.TransactionID = refund.TransactionID
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.refunds[refund.ID] = existing
+	*refund = existing
+	return nil
+}
+
+func (r *MemoryRepository) PaymentRefunds(ctx context.Context, customerID, paymentID int) ([]Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, ok := r.payments[paymentID]; !ok || p.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	refunds := []Refund{}
+	for _, refund := range r.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, refund)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+func (r *MemoryRepository) ListShifts(ctx context.Context, customerID int) ([]Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	shifts := []Shift{}
+	for _, s := range r.shifts {
+		if s.CustomerID == customerID {
+			shifts = append(shifts, s)
+		}
+	}
+	sort.Slice(shifts, func(i, j int) bool { return shifts[i].Number > shifts[j].Number })
+	return shifts, nil
+}
+
+func (r *MemoryRepository) GetShift(ctx context.Context, customerID, id int) (*Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.shifts[id]
+	if !ok || s.CustomerID != customerID {
+		return nil, ErrShiftNotFound
+	}
+	return &s, nil
+}
+
+// currentShift ищет открытую смену компании. Вызывается под блокировкой.
+func (r *MemoryRepository) currentShift(customerID int) (*Shift, error) {
+	for _, s := range r.shifts {
+		if s.CustomerID == customerID && s.Status == ShiftOpen {
+			return &s, nil
+		}
+	}
+	return nil, ErrNoOpenShift
+}
+
+func (r *MemoryRepository) CurrentShift(ctx context.Context, customerID int) (*Shift, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.currentShift(customerID)
+}
+
+func (r *MemoryRepository) OpenShift(ctx context.Context, shift *Shift) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.currentShift(shift.CustomerID); err == nil {
+		return ErrShiftOpen
+	}
+	number := 0
+	for _, s := range r.shifts {
+		if s.CustomerID == shift.CustomerID {
+			number = max(number, s.Number)
+		}
+	}
+
+	r.nextShiftID++
+	shift.ID = r.nextShiftID
+	shift.Number = number + 1
+	shift.Status = ShiftOpen
+	shift.OpenedAt = time.Now().UTC().Format(time.RFC3339)
+	r.shifts[shift.ID] = *shift
+	return nil
+}
+
+func (r *MemoryRepository) CloseShift(ctx context.Context, customerID int, total func(ctx context.Context, shift *Shift) error) (*Shift, error) {
+	// total читает платежи смены через методы репозитория, поэтому вызывается без блокировки
+	shift, err := r.CurrentShift(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := total(ctx, shift); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.shifts[shift.ID]; !ok || current.Status != ShiftOpen {
+		return nil, ErrNoOpenShift
+	}
+	shift.Status = ShiftClosed
+	shift.ClosedAt = time.Now().UTC().Format(time.RFC3339)
+	r.shifts[shift.ID] = *shift
+	return shift, nil
+}
+
+func (r *MemoryRepository) AddCashOperation(ctx context.Context, op *CashOperation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op.ID = len(r.operations) + 1
+	op.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.operations = append(r.operations, *op)
+	return nil
+}
+
+func (r *MemoryRepository) ShiftOperations(ctx context.Context, customerID, shiftID int) ([]CashOperation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	operations := []CashOperation{}
+	for _, op := range r.operations {
+		if op.CustomerID == customerID && op.ShiftID == shiftID {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+func (r *MemoryRepository) ShiftPayments(ctx context.Context, customerID, shiftID int) ([]Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payments := []Payment{}
+	for _, p := range r.payments {
+		if p.CustomerID == customerID && p.ShiftID == shiftID {
+			payments = append(payments, r.withRefunds(p))
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID < payments[j].ID })
+	return payments, nil
+}
+
+func (r *MemoryRepository) ListRefunds(ctx context.Context, customerID int) ([]Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	refunds := []Refund{}
+	for _, refund := range r.refunds {
+		if refund.CustomerID == customerID {
+			refunds = append(refunds, refund)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+func (r *MemoryRepository) ShiftRefunds(ctx context.Context, customerID, shiftID int) ([]Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	refunds := []Refund{}
+	for _, refund := range r.refunds {
+		if refund.CustomerID == customerID && refund.ShiftID == shiftID {
+			refunds = append(refunds, refund)
+		}
+	}
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].ID < refunds[j].ID })
+	return refunds, nil
+}
+
+func (r *MemoryRepository) ListReceipts(ctx context.Context, customerID int) ([]Receipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	receipts := []Receipt{}
+	for _, receipt := range r.receipts {
+		if receipt.CustomerID == customerID {
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
+}
+
+func (r *MemoryRepository) GetReceipt(ctx context.Context, customerID, id int) (*Receipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id < 1 || id > len(r.receipts) || r.receipts[id-1].CustomerID != customerID {
+		return nil, ErrReceiptNotFound
+	}
+	receipt := r.receipts[id-1]
+	return &receipt, nil
+}
+
+func (r *MemoryRepository) PaymentReceipts(ctx context.Context, customerID, paymentID int) ([]Receipt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, ok := r.payments[paymentID]; !ok || p.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	receipts := []Receipt{}
+	for _, receipt := range r.receipts {
+		if receipt.PaymentID == paymentID {
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
+}
+
+func (r *MemoryRepository) CreateReceipt(ctx context.Context, receipt *Receipt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.receipts {
+		sale := receipt.Kind == KindReceipt && receipt.Sign == SignIncome && existing.Kind == KindReceipt &&
+			existing.Sign == SignIncome && existing.PaymentID == receipt.PaymentID
+		if (receipt.PaymentID != 0 && sale) || (receipt.RefundID != 0 && existing.RefundID == receipt.RefundID) {
+			return ErrReceiptExists
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	receipt.ID = len(r.receipts) + 1
+	receipt.CreatedAt = now
+	receipt.UpdatedAt = now
+	r.receipts = append(r.receipts, *receipt)
+	return nil
+}
+
+func (r *MemoryRepository) UpdateReceipt(ctx context.Context, receipt *Receipt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if receipt.ID < 1 || receipt.ID > len(r.receipts) || r.receipts[receipt.ID-1].CustomerID != receipt.CustomerID {
+		return ErrReceiptNotFound
+	}
+	existing := &r.receipts[receipt.ID-1]
+	existing.Status = receipt.Status
+	existing.Error = receipt.Error
+	existing.Fiscal = receipt.Fiscal
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	*receipt = *existing
+	return nil
+}
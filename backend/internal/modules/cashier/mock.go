@@ -0,0 +1,192 @@
+package cashier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Исходы авторизации в MockProvider
+const (
+	MockApprove = "approve" // Платеж одобряется
+	MockDecline = "decline" // Платеж отклоняется
+	MockTimeout = "timeout" // Провайдер не отвечает
+)
+
+// MockConfig - настройки MockProvider
+type MockConfig struct {
+	Outcome       string        // approve, decline или timeout; по умолчанию approve
+	Delay         time.Duration // Задержка ответа; в режиме timeout - время до ErrProviderTimeout (по умолчанию 5s)
+	WebhookSecret []byte        // Секрет подписи уведомлений; без него уведомления отклоняются
+}
+
+// MockSignatureHeader - заголовок с подписью уведомления mock-провайдера (см. SignWebhook)
+const MockSignatureHeader = "X-Mock-Signature"
+
+// mockWebhook - тело уведомления mock-провайдера
+type mockWebhook struct {
+	ID            string `json:"id"`
+	TransactionID string `json:"transaction_id"`
+	Reference     string `json:"reference"`
+	Status        string `json:"status"`
+}
+
+// MockProvider - встроенный платежный провайдер для разработки и тестов без сети.
+// Хранит транзакции в памяти процесса.
+type MockProvider struct {
+	config MockConfig
+
+	mu           sync.Mutex
+	transactions map[string]*mockTransaction
+}
+
+// mockTransaction хранит суммы в копейках, как платежные шлюзы
+type mockTransaction struct {
+	status   string
+	amount   int64
+	captured int64
+	refunded int64
+}
+
+// NewMockProvider создает mock-провайдер
+func NewMockProvider(config MockConfig) *MockProvider {
+	if config.Outcome == "" {
+		config.Outcome = MockApprove
+	}
+	if config.Outcome == MockTimeout && config.Delay == 0 {
+		config.Delay = 5 * time.Second
+	}
+	return &MockProvider{config: config, transactions: make(map[string]*mockTransaction)}
+}
+
+// ValidMockOutcome сообщает, поддерживается ли исход авторизации
+func ValidMockOutcome(outcome string) bool {
+	return outcome == MockApprove || outcome == MockDecline || outcome == MockTimeout
+}
+
+func (p *MockProvider) Name() string { return "mock" }
+
+// wait имитирует задержку сети с учетом дедлайна контекста
+func (p *MockProvider) wait(ctx context.Context) error {
+	if p.config.Delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(p.config.Delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		if p.config.Outcome == MockTimeout {
+			return ErrProviderTimeout
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrProviderTimeout, ctx.Err())
+	}
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, req AuthorizeRequest) (*ProviderResult, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	id := "mock_" + uuid.NewString()
+	tx := &mockTransaction{status: StatusAuthorized, amount: req.Amount.Minor()}
+	result := &ProviderResult{TransactionID: id, Status: StatusAuthorized}
+	if p.config.Outcome == MockDecline {
+		tx.status = StatusFailed
+		result.Status = StatusFailed
+		result.Message = "Declined by issuer"
+	}
+
+	p.mu.Lock()
+	p.transactions[id] = tx
+	p.mu.Unlock()
+	return result, nil
+}
+
+// update выполняет операцию над транзакцией после имитации задержки
+func (p *MockProvider) update(ctx context.Context, id string, op func(tx *mockTransaction) error) (*ProviderResult, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tx, ok := p.transactions[id]
+	if !ok {
+		return nil, ErrUnknownTransaction
+	}
+	if op != nil {
+		if err := op(tx); err != nil {
+			return nil, err
+		}
+	}
+	return &ProviderResult{TransactionID: id, Status: tx.status, Refunded: money.New(tx.refunded, "")}, nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, transactionID string, amount money.Money) (*ProviderResult, error) {
+	return p.update(ctx, transactionID, func(tx *mockTransaction) error {
+		if tx.status != StatusAuthorized || !amount.IsPositive() || amount.Minor() > tx.amount {
+			return ErrInvalidOperation
+		}
+		tx.captured = amount.Minor()
+		tx.status = StatusCompleted
+		return nil
+	})
+}
+
+func (p *MockProvider) Void(ctx context.Context, transactionID string) (*ProviderResult, error) {
+	return p.update(ctx, transactionID, func(tx *mockTransaction) error {
+		if tx.status != StatusAuthorized {
+			return ErrInvalidOperation
+		}
+		tx.status = StatusVoided
+		return nil
+	})
+}
+
+func (p *MockProvider) Refund(ctx context.Context, transactionID string, amount money.Money) (*ProviderResult, error) {
+	return p.update(ctx, transactionID, func(tx *mockTransaction) error {
+		if tx.status != StatusCompleted || !amount.IsPositive() || tx.refunded+amount.Minor() > tx.captured {
+			return ErrInvalidOperation
+		}
+		tx.refunded += amount.Minor()
+		if tx.refunded == tx.captured {
+			tx.status = StatusRefunded
+		}
+		return nil
+	})
+}
+
+func (p *MockProvider) Status(ctx context.Context, transactionID string) (*ProviderResult, error) {
+	return p.update(ctx, transactionID, nil)
+}
+
+func (p *MockProvider) ParseWebhook(header func(key string) string, body []byte) (*WebhookEvent, error) {
+	if !VerifyWebhook(p.config.WebhookSecret, body, header(MockSignatureHeader)) {
+		return nil, ErrWebhookSignature
+	}
+
+	var hook mockWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("cashier: invalid mock webhook: %w", err)
+	}
+	if hook.ID == "" || hook.Status == "" || (hook.TransactionID == "" && hook.Reference == "") {
+		return nil, errors.New("cashier: mock webhook must have id, status and transaction_id or reference")
+	}
+	return &WebhookEvent{
+		ID:            hook.ID,
+		TransactionID: hook.TransactionID,
+		Reference:     hook.Reference,
+		Status:        hook.Status,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package cashier
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/registry"
+)
+
+// Module подключает Кассу к платформе
+type Module struct {
+	ctrl *Controller
+}
+
+// NewModule создает модуль Кассы
+func NewModule(ctrl *Controller) *Module {
+	return &Module{ctrl: ctrl}
+}
+
+// Info возвращает описание модуля для каталога
+func (m *Module) Info() registry.Info {
+	return registry.Info{
+		Name:         "cashier",
+		Title:        "Касса",
+		Description:  "Онлайн-касса: платежи, возвраты и статистика",
+		MonthlyPrice: 12,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты Кассы
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	router.Get("/stats", rbac.Require(rbac.PaymentsRead), m.ctrl.GetCashierStats)
+	router.Get("/payments", rbac.Require(rbac.PaymentsRead), m.ctrl.GetPayments)
+	router.Post("/payments", rbac.Require(rbac.PaymentsWrite), m.ctrl.CreatePayment)
+	router.Put("/payments/:id", rbac.Require(rbac.PaymentsWrite), m.ctrl.UpdatePayment)
+	router.Post("/process", rbac.Require(rbac.PaymentsWrite), m.ctrl.ProcessPayment)
+	router.Post("/payments/:id/capture", rbac.Require(rbac.PaymentsWrite), m.ctrl.CapturePayment)
+	router.Post("/payments/:id/void", rbac.Require(rbac.PaymentsWrite), m.ctrl.VoidPayment)
+	router.Post("/payments/:id/sync", rbac.Require(rbac.PaymentsWrite), m.ctrl.SyncPayment)
+	router.Get("/payments/:id/refunds", rbac.Require(rbac.PaymentsRead), m.ctrl.GetPaymentRefunds)
+	router.Get("/payments/:id/receipts", rbac.Require(rbac.PaymentsRead), m.ctrl.GetPaymentReceipts)
+	router.Post("/refund/:id", rbac.Require(rbac.PaymentsRefund), m.ctrl.RefundPayment)
+
+	// Кассовые смены; /shifts/current регистрируется раньше /shifts/:id
+	router.Get("/shifts", rbac.Require(rbac.PaymentsRead), m.ctrl.GetShifts)
+	router.Get("/shifts/current", rbac.Require(rbac.PaymentsRead), m.ctrl.GetCurrentShift)
+	router.Get("/shifts/current/x-report", rbac.Require(rbac.PaymentsRead), m.ctrl.GetXReport)
+	router.Post("/shifts/open", rbac.Require(rbac.PaymentsWrite), m.ctrl.OpenShift)
+	router.Post("/shifts/cash-in", rbac.Require(rbac.PaymentsWrite), m.ctrl.CashIn)
+	router.Post("/shifts/cash-out", rbac.Require(rbac.PaymentsWrite), m.ctrl.CashOut)
+	router.Post("/shifts/close", rbac.Require(rbac.PaymentsWrite), m.ctrl.CloseShift)
+	router.Get("/shifts/:id/report", rbac.Require(rbac.PaymentsRead), m.ctrl.GetShiftReport)
+
+	// Фискальные чеки
+	router.Get("/receipts", rbac.Require(rbac.PaymentsRead), m.ctrl.GetReceipts)
+	router.Get("/receipts/:id", rbac.Require(rbac.PaymentsRead), m.ctrl.GetReceipt)
+	router.Post("/receipts/:id/register", rbac.Require(rbac.PaymentsWrite), m.ctrl.RegisterReceipt)
+	router.Post("/receipts/correction", rbac.Require(rbac.PaymentsRefund), m.ctrl.CreateCorrection)
+}
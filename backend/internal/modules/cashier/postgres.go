@@ -0,0 +1,627 @@
+package cashier
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/database"
+)
+
+// PostgresRepository реализует PaymentRepository, ShiftRepository и ReceiptRepository поверх PostgreSQL
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий платежей
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+// Сумма проведенных возвратов не хранится в платеже, а считается по payment_refunds
+const paymentColumns = `id, COALESCE(order_id, 0), customer_id, amount, currency, payment_method, status, provider,
+	tendered, change_given, overpayment,
+	(SELECT COALESCE(SUM(r.amount), 0) FROM payment_refunds r WHERE r.payment_id = payments.id AND r.status = 'completed'),
+	COALESCE(shift_id, 0), tax_breakdown, transaction_id, payment_date, created_at, updated_at`
+
+func scanPayment(row interface{ Scan(...interface{}) error }) (*Payment, error) {
+	var p Payment
+	var breakdown []byte
+	var paymentDate sql.NullTime
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&p.ID, &p.OrderID, &p.CustomerID, &p.Amount, &p.Currency, &p.PaymentMethod, &p.Status, &p.Provider,
+		&p.Tendered, &p.Change, &p.Overpayment, &p.RefundedAmount, &p.ShiftID, &breakdown, &p.TransactionID, &paymentDate, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(breakdown, &p.TaxBreakdown); err != nil {
+		return nil, err
+	}
+	p.PaymentDate = database.FormatNullTime(paymentDate)
+	p.CreatedAt = database.FormatTime(createdAt)
+	p.UpdatedAt = database.FormatTime(updatedAt)
+	return &p, nil
+}
+
+func (r *PostgresRepository) ListPayments(ctx context.Context, customerID int) ([]Payment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+func (r *PostgresRepository) GetPayment(ctx context.Context, customerID, id int) (*Payment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE customer_id = $1 AND id = $2`, customerID, id)
+	return scanPayment(row)
+}
+
+func (r *PostgresRepository) LockPayment(ctx context.Context, customerID, id int) (*Payment, error) {
+	row := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE customer_id = $1 AND id = $2 FOR UPDATE`, customerID, id)
+	return scanPayment(row)
+}
+
+func (r *PostgresRepository) CreatePayment(ctx context.Context, p *Payment) error {
+	breakdown, err := taxBreakdown(p.TaxBreakdown)
+	if err != nil {
+		return err
+	}
+	row := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO payments (customer_id, order_id, amount, payment_method, status, provider,
+		                       tendered, change_given, overpayment, shift_id, transaction_id, payment_date, currency,
+		                       tax_breakdown)
+		 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), $11, NULLIF($12, '')::timestamptz, $13, $14)
+		 RETURNING `+paymentColumns,
+		p.CustomerID, p.OrderID, p.Amount, p.PaymentMethod, p.Status, p.Provider,
+		p.Tendered, p.Change, p.Overpayment, p.ShiftID, p.TransactionID, p.PaymentDate, p.Currency, breakdown)
+	saved, err := scanPayment(row)
+	if err != nil {
+		return err
+	}
+	*p = *saved
+	return nil
+}
+
+func (r *PostgresRepository) UpdatePayment(ctx context.Context, p *Payment) error {
+	breakdown, err := taxBreakdown(p.TaxBreakdown)
+	if err != nil {
+		return err
+	}
+	row := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE payments SET order_id = NULLIF($3, 0), amount = $4, payment_method = $5, status = $6,
+		        provider = $7, tendered = $8, change_given = $9, overpayment = $10, transaction_id = $11,
+		        payment_date = NULLIF($12, '')::timestamptz, currency = $13, tax_breakdown = $14, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2
+		 RETURNING `+paymentColumns,
+		p.CustomerID, p.ID, p.OrderID, p.Amount, p.PaymentMethod, p.Status, p.Provider,
+		p.Tendered, p.Change, p.Overpayment, p.TransactionID, p.PaymentDate, p.Currency, breakdown)
+	saved, err := scanPayment(row)
+	if err != nil {
+		return err
+	}
+	*p = *saved
+	return nil
+}
+
+func (r *PostgresRepository) OrderPayments(ctx context.Context, customerID, orderID int) ([]Payment, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE customer_id = $1 AND order_id = $2 ORDER BY id`,
+		customerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+func (r *PostgresRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.InTx(ctx, r.db, fn)
+}
+
+func (r *PostgresRepository) SaveWebhook(ctx context.Context, hook *Webhook) error {
+	var receivedAt time.Time
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO payment_webhooks (provider, event_id, transaction_id, payment_id, customer_id, status, result, payload)
+		 VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, 0), NULLIF($5, 0), $6, $7, $8)
+		 ON CONFLICT (provider, event_id) DO NOTHING
+		 RETURNING id, received_at`,
+		hook.Provider, hook.EventID, hook.TransactionID, hook.PaymentID, hook.CustomerID, hook.Status, hook.Result, hook.Payload,
+	).Scan(&hook.ID, &receivedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateWebhook
+	}
+	if err != nil {
+		return err
+	}
+	hook.ReceivedAt = database.FormatTime(receivedAt)
+	return nil
+}
+
+func (r *PostgresRepository) ProcessWebhook(ctx context.Context, hook *Webhook, apply func(ctx context.Context, p *Payment) (bool, error)) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Сначала сохраняем уведомление: уникальный EventID отсекает повторы
+		// даже при параллельной доставке
+		if err := r.SaveWebhook(ctx, hook); err != nil {
+			return err
+		}
+
+		// Платеж блокируется до конца транзакции
+		p, err := scanPayment(q.QueryRowContext(ctx,
+			`SELECT `+paymentColumns+` FROM payments
+			 WHERE provider = $1
+			   AND ((transaction_id = $2 AND $2 <> '') OR (id = $3 AND transaction_id IN ('', $2)))
+			 ORDER BY transaction_id = $2 DESC LIMIT 1
+			 FOR UPDATE`,
+			hook.Provider, hook.TransactionID, hook.PaymentID))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			hook.PaymentID = 0
+			hook.Result = WebhookNoPayment
+		case err != nil:
+			return err
+		default:
+			hook.PaymentID = p.ID
+			hook.CustomerID = p.CustomerID
+			hook.Result = WebhookIgnored
+			applied, err := apply(ctx, p)
+			if err != nil {
+				return err
+			}
+			if applied {
+				hook.Result = WebhookProcessed
+			}
+		}
+
+		_, err = q.ExecContext(ctx,
+			`UPDATE payment_webhooks SET payment_id = NULLIF($2, 0), customer_id = NULLIF($3, 0), result = $4 WHERE id = $1`,
+			hook.ID, hook.PaymentID, hook.CustomerID, hook.Result)
+		return err
+	})
+}
+
+// Способ оплаты возврата берется из возвращаемого платежа
+const refundColumns = `id, payment_id, customer_id, COALESCE(shift_id, 0),
+	(SELECT p.payment_method FROM payments p WHERE p.id = payment_refunds.payment_id),
+	amount, currency, reason, status, transaction_id, COALESCE(created_by, 0), created_at, updated_at`
+
+func scanRefund(row interface{ Scan(...interface{}) error }) (*Refund, error) {
+	var refund Refund
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&refund.ID, &refund.PaymentID, &refund.CustomerID, &refund.ShiftID, &refund.PaymentMethod,
+		&refund.Amount, &refund.Currency, &refund.Reason,
+		&refund.Status, &refund.TransactionID, &refund.CreatedBy, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	refund.CreatedAt = database.FormatTime(createdAt)
+	refund.UpdatedAt = database.FormatTime(updatedAt)
+	return &refund, nil
+}
+
+func (r *PostgresRepository) CreateRefund(ctx context.Context, refund *Refund, check func(p *Payment, reserved money.Money) error) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Платеж блокируется до конца транзакции: параллельный возврат дождется этой записи
+		p, err := r.LockPayment(ctx, refund.CustomerID, refund.PaymentID)
+		if err != nil {
+			return err
+		}
+
+		// Незавершенные возвраты тоже занимают сумму платежа
+		var reserved money.Money
+		err = q.QueryRowContext(ctx,
+			`SELECT COALESCE(SUM(amount), 0) FROM payment_refunds WHERE payment_id = $1 AND status <> $2`,
+			p.ID, RefundFailed).Scan(&reserved)
+		if err != nil {
+			return err
+		}
+		if err := check(p, reserved); err != nil {
+			return err
+		}
+
+		saved, err := scanRefund(q.QueryRowContext(ctx,
+			`INSERT INTO payment_refunds (payment_id, customer_id, shift_id, amount, currency, reason, status,
+			                              transaction_id, created_by)
+			 VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, NULLIF($9, 0))
+			 RETURNING `+refundColumns,
+			refund.PaymentID, refund.CustomerID, refund.ShiftID, refund.Amount, p.Currency, refund.Reason, refund.Status,
+			refund.TransactionID, refund.CreatedBy))
+		if err != nil {
+			return err
+		}
+		*refund = *saved
+		return nil
+	})
+}
+
+func (r *PostgresRepository) UpdateRefund(ctx context.Context, refund *Refund) error {
+	saved, err := scanRefund(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE payment_refunds SET status = $3, transaction_id = $4, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2
+		 RETURNING `+refundColumns,
+		refund.CustomerID, refund.ID, refund.Status, refund.TransactionID))
+	if err != nil {
+		return err
+	}
+	*refund = *saved
+	return nil
+}
+
+func (r *PostgresRepository) PaymentRefunds(ctx context.Context, customerID, paymentID int) ([]Refund, error) {
+	if _, err := r.GetPayment(ctx, customerID, paymentID); err != nil {
+		return nil, err
+	}
+	return r.queryRefunds(ctx,
+		`SELECT `+refundColumns+` FROM payment_refunds WHERE customer_id = $1 AND payment_id = $2 ORDER BY id`,
+		customerID, paymentID)
+}
+
+func (r *PostgresRepository) ListRefunds(ctx context.Context, customerID int) ([]Refund, error) {
+	return r.queryRefunds(ctx,
+		`SELECT `+refundColumns+` FROM payment_refunds WHERE customer_id = $1 ORDER BY id`,
+		customerID)
+}
+
+// queryRefunds выполняет запрос возвратов с колонками refundColumns
+func (r *PostgresRepository) queryRefunds(ctx context.Context, query string, args ...interface{}) ([]Refund, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, rows.Err()
+}
+
+const shiftColumns = `id, customer_id, number, status, currency, opening_float, expected_cash, counted_cash, discrepancy,
+	COALESCE(opened_by, 0), COALESCE(closed_by, 0), opened_at, closed_at`
+
+func scanShift(row interface{ Scan(...interface{}) error }) (*Shift, error) {
+	var s Shift
+	var openedAt time.Time
+	var closedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.CustomerID, &s.Number, &s.Status, &s.Currency, &s.OpeningFloat, &s.ExpectedCash, &s.CountedCash,
+		&s.Discrepancy, &s.OpenedBy, &s.ClosedBy, &openedAt, &closedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShiftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.OpenedAt = database.FormatTime(openedAt)
+	s.ClosedAt = database.FormatNullTime(closedAt)
+	return &s, nil
+}
+
+func (r *PostgresRepository) ListShifts(ctx context.Context, customerID int) ([]Shift, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+shiftColumns+` FROM cash_shifts WHERE customer_id = $1 ORDER BY number DESC`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := []Shift{}
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, *s)
+	}
+	return shifts, rows.Err()
+}
+
+func (r *PostgresRepository) GetShift(ctx context.Context, customerID, id int) (*Shift, error) {
+	return scanShift(r.db.QueryRowContext(ctx,
+		`SELECT `+shiftColumns+` FROM cash_shifts WHERE customer_id = $1 AND id = $2`, customerID, id))
+}
+
+func (r *PostgresRepository) CurrentShift(ctx context.Context, customerID int) (*Shift, error) {
+	// FOR SHARE не мешает параллельным платежам, но задерживает закрытие смены
+	s, err := scanShift(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT `+shiftColumns+` FROM cash_shifts WHERE customer_id = $1 AND status = $2 FOR SHARE`,
+		customerID, ShiftOpen))
+	if errors.Is(err, ErrShiftNotFound) {
+		return nil, ErrNoOpenShift
+	}
+	return s, err
+}
+
+func (r *PostgresRepository) OpenShift(ctx context.Context, shift *Shift) error {
+	saved, err := scanShift(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO cash_shifts (customer_id, number, status, currency, opening_float, opened_by)
+		 SELECT $1, COALESCE(MAX(number), 0) + 1, $2, $3, $4, NULLIF($5, 0) FROM cash_shifts WHERE customer_id = $1
+		 RETURNING `+shiftColumns,
+		shift.CustomerID, ShiftOpen, shift.Currency, shift.OpeningFloat, shift.OpenedBy))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrShiftOpen
+	}
+	if err != nil {
+		return err
+	}
+	*shift = *saved
+	return nil
+}
+
+func (r *PostgresRepository) CloseShift(ctx context.Context, customerID int, total func(ctx context.Context, shift *Shift) error) (*Shift, error) {
+	var closed *Shift
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Блокировка ждет завершения платежей, уже отнесенных к смене
+		shift, err := scanShift(q.QueryRowContext(ctx,
+			`SELECT `+shiftColumns+` FROM cash_shifts WHERE customer_id = $1 AND status = $2 FOR UPDATE`,
+			customerID, ShiftOpen))
+		if errors.Is(err, ErrShiftNotFound) {
+			return ErrNoOpenShift
+		}
+		if err != nil {
+			return err
+		}
+		if err := total(ctx, shift); err != nil {
+			return err
+		}
+
+		closed, err = scanShift(q.QueryRowContext(ctx,
+			`UPDATE cash_shifts SET status = $3, expected_cash = $4, counted_cash = $5, discrepancy = $6,
+			        closed_by = NULLIF($7, 0), closed_at = now()
+			 WHERE customer_id = $1 AND id = $2
+			 RETURNING `+shiftColumns,
+			customerID, shift.ID, ShiftClosed, shift.ExpectedCash, shift.CountedCash, shift.Discrepancy, shift.ClosedBy))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+const cashOperationColumns = `id, shift_id, customer_id, type, amount, currency, reason, COALESCE(created_by, 0), created_at`
+
+func scanCashOperation(row interface{ Scan(...interface{}) error }) (*CashOperation, error) {
+	var op CashOperation
+	var createdAt time.Time
+	err := row.Scan(&op.ID, &op.ShiftID, &op.CustomerID, &op.Type, &op.Amount, &op.Currency, &op.Reason, &op.CreatedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	op.CreatedAt = database.FormatTime(createdAt)
+	return &op, nil
+}
+
+func (r *PostgresRepository) AddCashOperation(ctx context.Context, op *CashOperation) error {
+	saved, err := scanCashOperation(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO cash_operations (shift_id, customer_id, type, amount, currency, reason, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
+		 RETURNING `+cashOperationColumns,
+		op.ShiftID, op.CustomerID, op.Type, op.Amount, op.Currency, op.Reason, op.CreatedBy))
+	if err != nil {
+		return err
+	}
+	*op = *saved
+	return nil
+}
+
+func (r *PostgresRepository) ShiftOperations(ctx context.Context, customerID, shiftID int) ([]CashOperation, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT `+cashOperationColumns+` FROM cash_operations WHERE customer_id = $1 AND shift_id = $2 ORDER BY id`,
+		customerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	operations := []CashOperation{}
+	for rows.Next() {
+		op, err := scanCashOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, *op)
+	}
+	return operations, rows.Err()
+}
+
+func (r *PostgresRepository) ShiftPayments(ctx context.Context, customerID, shiftID int) ([]Payment, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE customer_id = $1 AND shift_id = $2 ORDER BY id`,
+		customerID, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+func (r *PostgresRepository) ShiftRefunds(ctx context.Context, customerID, shiftID int) ([]Refund, error) {
+	return r.queryRefunds(ctx,
+		`SELECT `+refundColumns+` FROM payment_refunds WHERE customer_id = $1 AND shift_id = $2 ORDER BY id`,
+		customerID, shiftID)
+}
+
+// taxBreakdown кодирует разбивку по налогам для колонки JSONB; пустая разбивка - пустой массив
+func taxBreakdown(lines []tax.Line) ([]byte, error) {
+	if lines == nil {
+		lines = []tax.Line{}
+	}
+	return json.Marshal(lines)
+}
+
+const receiptColumns = `id, customer_id, COALESCE(payment_id, 0), COALESCE(refund_id, 0), COALESCE(shift_id, 0),
+	kind, sign, taxation, items, tax_breakdown, total, currency, cash, electronic, correction, status, error,
+	document_number, fiscal_sign, drive_number, registered_at, created_at, updated_at`
+
+func scanReceipt(row interface{ Scan(...interface{}) error }) (*Receipt, error) {
+	var receipt Receipt
+	var items, breakdown, correction []byte
+	var registeredAt sql.NullTime
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&receipt.ID, &receipt.CustomerID, &receipt.PaymentID, &receipt.RefundID, &receipt.ShiftID,
+		&receipt.Kind, &receipt.Sign, &receipt.Taxation, &items, &breakdown, &receipt.Total, &receipt.Currency, &receipt.Cash, &receipt.Electronic,
+		&correction, &receipt.Status, &receipt.Error, &receipt.Fiscal.DocumentNumber, &receipt.Fiscal.Sign,
+		&receipt.Fiscal.DriveNumber, &registeredAt, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(items, &receipt.Items); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(breakdown, &receipt.TaxBreakdown); err != nil {
+		return nil, err
+	}
+	if correction != nil {
+		if err := json.Unmarshal(correction, &receipt.Correction); err != nil {
+			return nil, err
+		}
+	}
+	receipt.Fiscal.RegisteredAt = database.FormatNullTime(registeredAt)
+	receipt.CreatedAt = database.FormatTime(createdAt)
+	receipt.UpdatedAt = database.FormatTime(updatedAt)
+	return &receipt, nil
+}
+
+// queryReceipts выполняет запрос чеков с колонками receiptColumns
+func (r *PostgresRepository) queryReceipts(ctx context.Context, query string, args ...interface{}) ([]Receipt, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []Receipt{}
+	for rows.Next() {
+		receipt, err := scanReceipt(rows)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, *receipt)
+	}
+	return receipts, rows.Err()
+}
+
+func (r *PostgresRepository) ListReceipts(ctx context.Context, customerID int) ([]Receipt, error) {
+	return r.queryReceipts(ctx, `SELECT `+receiptColumns+` FROM receipts WHERE customer_id = $1 ORDER BY id`, customerID)
+}
+
+func (r *PostgresRepository) GetReceipt(ctx context.Context, customerID, id int) (*Receipt, error) {
+	return scanReceipt(r.db.QueryRowContext(ctx,
+		`SELECT `+receiptColumns+` FROM receipts WHERE customer_id = $1 AND id = $2`, customerID, id))
+}
+
+func (r *PostgresRepository) PaymentReceipts(ctx context.Context, customerID, paymentID int) ([]Receipt, error) {
+	if _, err := r.GetPayment(ctx, customerID, paymentID); err != nil {
+		return nil, err
+	}
+	return r.queryReceipts(ctx,
+		`SELECT `+receiptColumns+` FROM receipts WHERE customer_id = $1 AND payment_id = $2 ORDER BY id`,
+		customerID, paymentID)
+}
+
+func (r *PostgresRepository) CreateReceipt(ctx context.Context, receipt *Receipt) error {
+	items, err := json.Marshal(receipt.Items)
+	if err != nil {
+		return err
+	}
+	breakdown, err := taxBreakdown(receipt.TaxBreakdown)
+	if err != nil {
+		return err
+	}
+	var correction []byte
+	if receipt.Correction != nil {
+		if correction, err = json.Marshal(receipt.Correction); err != nil {
+			return err
+		}
+	}
+
+	saved, err := scanReceipt(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO receipts (customer_id, payment_id, refund_id, shift_id, kind, sign, taxation, items,
+		                       total, currency, cash, electronic, correction, status, error, tax_breakdown)
+		 VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		 RETURNING `+receiptColumns,
+		receipt.CustomerID, receipt.PaymentID, receipt.RefundID, receipt.ShiftID, receipt.Kind, receipt.Sign,
+		receipt.Taxation, items, receipt.Total, receipt.Currency, receipt.Cash, receipt.Electronic, correction, receipt.Status, receipt.Error,
+		breakdown))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrReceiptExists
+	}
+	if err != nil {
+		return err
+	}
+	*receipt = *saved
+	return nil
+}
+
+func (r *PostgresRepository) UpdateReceipt(ctx context.Context, receipt *Receipt) error {
+	saved, err := scanReceipt(database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE receipts SET status = $3, error = $4, document_number = $5, fiscal_sign = $6, drive_number = $7,
+		        registered_at = NULLIF($8, '')::timestamptz, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2
+		 RETURNING `+receiptColumns,
+		receipt.CustomerID, receipt.ID, receipt.Status, receipt.Error, receipt.Fiscal.DocumentNumber,
+		receipt.Fiscal.Sign, receipt.Fiscal.DriveNumber, receipt.Fiscal.RegisteredAt))
+	if err != nil {
+		return err
+	}
+	*receipt = *saved
+	return nil
+}
@@ -0,0 +1,66 @@
+package cashier
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Статусы платежа
+const (
+	StatusPending    = "pending"    // Ожидает ответа провайдера
+	StatusAuthorized = "authorized" // Средства заблокированы, но не списаны
+	StatusCompleted  = "completed"  // Средства списаны
+	StatusFailed     = "failed"     // Отклонен провайдером
+	StatusVoided     = "voided"     // Авторизация отменена
+	StatusRefunded   = "refunded"   // Средства возвращены
+)
+
+// MethodCash - оплата наличными, не требующая платежного провайдера
+const MethodCash = "cash"
+
+// ProviderTimeout - сколько касса ждет ответа провайдера на один запрос
+const ProviderTimeout = 30 * time.Second
+
+var (
+	// ErrProviderTimeout возвращается, если провайдер не ответил вовремя.
+	// Исход операции неизвестен: платеж остается в ожидании.
+	ErrProviderTimeout = errors.New("cashier: payment provider timed out")
+	// ErrUnknownTransaction возвращается провайдером, если транзакция ему неизвестна
+	ErrUnknownTransaction = errors.New("cashier: unknown provider transaction")
+	// ErrInvalidOperation возвращается провайдером, если операция недопустима в текущем статусе транзакции
+	ErrInvalidOperation = errors.New("cashier: operation not allowed for transaction status")
+)
+
+// AuthorizeRequest - запрос на авторизацию платежа у провайдера
+type AuthorizeRequest struct {
+	Reference string // Идентификатор платежа в кассе, передается провайдеру для сверки
+	Amount    money.Money
+	Method    string
+}
+
+// ProviderResult - ответ провайдера на операцию
+type ProviderResult struct {
+	TransactionID string
+	Status        string      // Статус платежа после операции: authorized, completed, failed, voided, refunded, pending
+	Message       string      // Причина отказа или пояснение провайдера
+	Refunded      money.Money // Сумма всех возвратов по транзакции; по ней сверяются возвраты без ответа провайдера
+}
+
+// PaymentProvider - платежный шлюз (эквайер). Все методы должны соблюдать дедлайн контекста.
+type PaymentProvider interface {
+	// Name возвращает имя провайдера, под которым он сохраняется в платежах
+	Name() string
+	// Authorize блокирует средства; отказ возвращается как результат со статусом failed
+	Authorize(ctx context.Context, req AuthorizeRequest) (*ProviderResult, error)
+	// Capture списывает авторизованные средства
+	Capture(ctx context.Context, transactionID string, amount money.Money) (*ProviderResult, error)
+	// Void отменяет авторизацию до списания
+	Void(ctx context.Context, transactionID string) (*ProviderResult, error)
+	// Refund возвращает списанные средства
+	Refund(ctx context.Context, transactionID string, amount money.Money) (*ProviderResult, error)
+	// Status запрашивает текущий статус транзакции и сумму возвратов по ней
+	Status(ctx context.Context, transactionID string) (*ProviderResult, error)
+}
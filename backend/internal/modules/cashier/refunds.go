@@ -0,0 +1,50 @@
+package cashier
+
+import (
+	"errors"
+	"fmt"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Статусы возврата
+const (
+	RefundPending   = "pending"   // Провайдер еще не подтвердил возврат; сумма уже зарезервирована
+	RefundCompleted = "completed" // Средства возвращены
+	RefundFailed    = "failed"    // Провайдер отклонил возврат
+)
+
+// ErrNotRefundable возвращается, если платеж не проведен и вернуть по нему нечего
+var ErrNotRefundable = errors.New("cashier: payment is not completed")
+
+// RefundLimitError возвращается, если возврат превышает сумму, еще доступную к возврату
+type RefundLimitError struct {
+	Refundable money.Money // Сумма платежа за вычетом проведенных и ожидающих возвратов
+}
+
+func (e *RefundLimitError) Error() string {
+	return fmt.Sprintf("cashier: refund exceeds refundable amount %s", e.Refundable.Fixed())
+}
+
+// Refund - возврат средств по платежу. Один платеж можно вернуть несколькими частями.
+type Refund struct {
+	ID            int         `json:"id"`
+	PaymentID     int         `json:"payment_id"`
+	CustomerID    int         `json:"customer_id"`    // ID компании
+	ShiftID       int         `json:"shift_id"`       // Кассовая смена, в которой проведен возврат; 0 - вне смены
+	PaymentMethod string      `json:"payment_method"` // Способ оплаты возвращаемого платежа
+	Amount        money.Money `json:"amount"`
+	Currency      string      `json:"currency"`       // Валюта возвращаемого платежа
+	Reason        string      `json:"reason"`         // Причина возврата
+	Status        string      `json:"status"`         // pending, completed, failed
+	TransactionID string      `json:"transaction_id"` // ID операции у платежного провайдера
+	CreatedBy     int         `json:"created_by"`     // ID пользователя; 0 - запрос по API-ключу
+	CreatedAt     string      `json:"created_at"`
+	UpdatedAt     string      `json:"updated_at"`
+}
+
+// RefundRequest - запрос на возврат средств по платежу
+type RefundRequest struct {
+	Amount money.Money `json:"amount"` // Сумма возврата; по умолчанию - весь остаток, доступный к возврату
+	Reason string      `json:"reason"`
+}
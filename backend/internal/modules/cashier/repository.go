@@ -0,0 +1,103 @@
+package cashier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"kit8-backend/internal/core/money"
+)
+
+// ErrNotFound возвращается, если платеж не найден или принадлежит другой компании
+var ErrNotFound = errors.New("cashier: payment not found")
+
+// OverpaymentError возвращается, если платеж превышает остаток к оплате заказа
+type OverpaymentError struct {
+	Outstanding money.Money // Остаток к оплате заказа без учета этого платежа
+}
+
+func (e *OverpaymentError) Error() string {
+	return fmt.Sprintf("cashier: payment exceeds outstanding balance %s", e.Outstanding.Fixed())
+}
+
+// CurrencyMismatchError возвращается, если валюта платежа или возврата не совпадает
+// с валютой заказа или, для наличных, с валютой открытой кассовой смены
+type CurrencyMismatchError struct {
+	Currency string // Валюта операции
+	Expected string // Валюта заказа или смены
+}
+
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("cashier: currency %s does not match %s", e.Currency, e.Expected)
+}
+
+// PaymentRepository хранит платежи. Все методы ограничены компанией customerID.
+type PaymentRepository interface {
+	ListPayments(ctx context.Context, customerID int) ([]Payment, error)
+	GetPayment(ctx context.Context, customerID, id int) (*Payment, error)
+	CreatePayment(ctx context.Context, payment *Payment) error
+	UpdatePayment(ctx context.Context, payment *Payment) error
+	// LockPayment возвращает платеж и блокирует его до конца транзакции из контекста
+	LockPayment(ctx context.Context, customerID, id int) (*Payment, error)
+	// OrderPayments возвращает все платежи заказа
+	OrderPayments(ctx context.Context, customerID, orderID int) ([]Payment, error)
+	// WithTx выполняет fn в транзакции: запросы репозиториев с переданным контекстом
+	// фиксируются вместе. Хранилище в памяти транзакций не поддерживает и просто вызывает fn.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// CreateRefund атомарно проверяет и сохраняет возврат по платежу refund.PaymentID.
+	// check получает платеж и сумму его незавершенных и проведенных возвратов и может
+	// изменить refund или отклонить возврат ошибкой. Если платеж не найден, возвращается ErrNotFound.
+	CreateRefund(ctx context.Context, refund *Refund, check func(p *Payment, reserved money.Money) error) error
+	// UpdateRefund сохраняет статус и ID операции провайдера для возврата
+	UpdateRefund(ctx context.Context, refund *Refund) error
+	// PaymentRefunds возвращает возвраты платежа
+	PaymentRefunds(ctx context.Context, customerID, paymentID int) ([]Refund, error)
+	// ListRefunds возвращает все возвраты компании
+	ListRefunds(ctx context.Context, customerID int) ([]Refund, error)
+
+	// SaveWebhook сохраняет уведомление, не применяя его к платежам (например, с неверной подписью)
+	SaveWebhook(ctx context.Context, hook *Webhook) error
+	// ProcessWebhook сохраняет уведомление и атомарно применяет его к платежу провайдера
+	// hook.Provider, найденному по hook.TransactionID или по ID платежа hook.PaymentID.
+	// apply сохраняет измененный платеж и возвращает true, если уведомление применено.
+	// Результат записывается в hook.Result. Уведомление с уже сохраненным EventID
+	// не применяется повторно: возвращается ErrDuplicateWebhook.
+	// В отличие от остальных методов, платеж ищется среди платежей всех компаний.
+	ProcessWebhook(ctx context.Context, hook *Webhook, apply func(ctx context.Context, p *Payment) (bool, error)) error
+}
+
+// ShiftRepository хранит кассовые смены и операции с наличными.
+// Все методы ограничены компанией customerID.
+type ShiftRepository interface {
+	ListShifts(ctx context.Context, customerID int) ([]Shift, error)
+	GetShift(ctx context.Context, customerID, id int) (*Shift, error)
+	// CurrentShift возвращает открытую смену компании или ErrNoOpenShift.
+	// В транзакции из контекста смена не может быть закрыта до ее завершения.
+	CurrentShift(ctx context.Context, customerID int) (*Shift, error)
+	// OpenShift открывает смену с очередным номером. Если у компании уже есть
+	// открытая смена, возвращается ErrShiftOpen.
+	OpenShift(ctx context.Context, shift *Shift) error
+	// CloseShift атомарно закрывает открытую смену компании: смена блокируется,
+	// после чего total заполняет итоги по ее платежам и операциям. Если открытой
+	// смены нет, возвращается ErrNoOpenShift.
+	CloseShift(ctx context.Context, customerID int, total func(ctx context.Context, shift *Shift) error) (*Shift, error)
+	AddCashOperation(ctx context.Context, op *CashOperation) error
+	ShiftOperations(ctx context.Context, customerID, shiftID int) ([]CashOperation, error)
+	// ShiftPayments и ShiftRefunds возвращают платежи и возвраты, проведенные в смене
+	ShiftPayments(ctx context.Context, customerID, shiftID int) ([]Payment, error)
+	ShiftRefunds(ctx context.Context, customerID, shiftID int) ([]Refund, error)
+}
+
+// ReceiptRepository хранит фискальные чеки. Все методы ограничены компанией customerID.
+type ReceiptRepository interface {
+	ListReceipts(ctx context.Context, customerID int) ([]Receipt, error)
+	GetReceipt(ctx context.Context, customerID, id int) (*Receipt, error)
+	PaymentReceipts(ctx context.Context, customerID, paymentID int) ([]Receipt, error)
+	// CreateReceipt сохраняет новый чек. Для платежа сохраняется не более одного
+	// кассового чека прихода, для возврата - не более одного чека возврата прихода;
+	// повторный чек отклоняется с ErrReceiptExists.
+	CreateReceipt(ctx context.Context, receipt *Receipt) error
+	// UpdateReceipt сохраняет статус регистрации и фискальные реквизиты чека
+	UpdateReceipt(ctx context.Context, receipt *Receipt) error
+}
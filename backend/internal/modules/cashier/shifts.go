@@ -0,0 +1,220 @@
+package cashier
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"kit8-backend/internal/core/money"
+)
+
+// Статусы кассовой смены
+const (
+	ShiftOpen   = "open"
+	ShiftClosed = "closed"
+)
+
+// Операции внесения и изъятия наличных
+const (
+	CashIn  = "cash_in"  // Внесение наличных в кассу
+	CashOut = "cash_out" // Изъятие наличных из кассы
+)
+
+// Типы отчетов по смене
+const (
+	ReportX = "X" // Промежуточный отчет без закрытия смены
+	ReportZ = "Z" // Отчет о закрытии смены
+)
+
+var (
+	// ErrShiftNotFound возвращается, если смена не найдена или принадлежит другой компании
+	ErrShiftNotFound = errors.New("cashier: shift not found")
+	// ErrNoOpenShift возвращается, если у компании нет открытой смены
+	ErrNoOpenShift = errors.New("cashier: no open shift")
+	// ErrShiftOpen возвращается при попытке открыть вторую смену
+	ErrShiftOpen = errors.New("cashier: shift is already open")
+)
+
+// Shift - кассовая смена. У компании может быть открыта только одна смена;
+// платежи, возвраты и операции с наличными, проведенные за время смены, относятся к ней.
+type Shift struct {
+	ID           int         `json:"id"`
+	CustomerID   int         `json:"customer_id"`   // ID компании
+	Number       int         `json:"number"`        // Порядковый номер смены в компании
+	Status       string      `json:"status"`        // open, closed
+	Currency     string      `json:"currency"`      // Базовая валюта компании на момент открытия
+	OpeningFloat money.Money `json:"opening_float"` // Размен в кассе на начало смены
+	ExpectedCash money.Money `json:"expected_cash"` // Наличные в кассе по учету на момент закрытия
+	CountedCash  money.Money `json:"counted_cash"`  // Пересчитанные при закрытии наличные
+	Discrepancy  money.Money `json:"discrepancy"`   // CountedCash - ExpectedCash: излишек (+) или недостача (-)
+	OpenedBy     int         `json:"opened_by"`     // ID пользователя; 0 - запрос по API-ключу
+	ClosedBy     int         `json:"closed_by"`
+	OpenedAt     string      `json:"opened_at"`
+	ClosedAt     string      `json:"closed_at"`
+}
+
+// CashOperation - внесение или изъятие наличных в течение смены
+type CashOperation struct {
+	ID         int         `json:"id"`
+	ShiftID    int         `json:"shift_id"`
+	CustomerID int         `json:"customer_id"`
+	Type       string      `json:"type"` // cash_in, cash_out
+	Amount     money.Money `json:"amount"`
+	Currency   string      `json:"currency"` // Валюта смены
+	Reason     string      `json:"reason"`
+	CreatedBy  int         `json:"created_by"`
+	CreatedAt  string      `json:"created_at"`
+}
+
+// MethodTotals - итоги смены по способу оплаты
+type MethodTotals struct {
+	PaymentMethod string      `json:"payment_method"`
+	Currency      string      `json:"currency"`
+	Payments      int         `json:"payments"`      // Количество проведенных платежей
+	Sales         money.Money `json:"sales"`         // Сумма проведенных платежей
+	Refunds       int         `json:"refunds"`       // Количество проведенных возвратов
+	RefundAmount  money.Money `json:"refund_amount"` // Сумма проведенных возвратов
+	Net           money.Money `json:"net"`           // Sales - RefundAmount
+}
+
+// ShiftReport - X-отчет (в течение смены) или Z-отчет (при закрытии смены)
+type ShiftReport struct {
+	Type         string         `json:"type"` // X или Z
+	Shift        Shift          `json:"shift"`
+	Methods      []MethodTotals `json:"methods"`
+	Sales        money.Money    `json:"sales"`   // Только в валюте смены; остальные валюты - в Methods
+	Refunds      money.Money    `json:"refunds"` // Только в валюте смены
+	CashIn       money.Money    `json:"cash_in"`
+	CashOut      money.Money    `json:"cash_out"`
+	ExpectedCash money.Money    `json:"expected_cash"`          // Размен + наличные платежи - наличные возвраты + внесения - изъятия
+	CountedCash  *money.Money   `json:"counted_cash,omitempty"` // Только в Z-отчете
+	Discrepancy  *money.Money   `json:"discrepancy,omitempty"`  // Только в Z-отчете
+	GeneratedAt  string         `json:"generated_at"`
+}
+
+// OpenShiftRequest - запрос на открытие смены
+type OpenShiftRequest struct {
+	OpeningFloat money.Money `json:"opening_float"`
+}
+
+// CloseShiftRequest - запрос на закрытие смены
+type CloseShiftRequest struct {
+	CountedCash *money.Money `json:"counted_cash"` // Обязательно: наличные, пересчитанные в кассе
+}
+
+// CashOperationRequest - запрос на внесение или изъятие наличных
+type CashOperationRequest struct {
+	Amount money.Money `json:"amount"`
+	Reason string      `json:"reason"`
+}
+
+// accepts проверяет, что операцию способом оплаты method в валюте code можно провести
+// в смене: наличные принимаются и выдаются только при открытой смене и только в ее валюте.
+// Пустая смена означает, что смена не открыта: без нее проводятся только безналичные операции.
+func (s *Shift) accepts(method, code string) error {
+	if method != MethodCash {
+		return nil
+	}
+	if s.ID == 0 {
+		return ErrNoOpenShift
+	}
+	if code == s.Currency {
+		return nil
+	}
+	return &CurrencyMismatchError{Currency: code, Expected: s.Currency}
+}
+
+// newShiftReport подводит итоги смены по ее платежам, возвратам и операциям с наличными.
+// В продажах учитываются проведенные платежи, в том числе позже возвращенные:
+// возвраты показываются отдельно по смене, в которой они проведены.
+// Итоги по способам оплаты ведутся отдельно по каждой валюте.
+func newShiftReport(kind string, shift Shift, payments []Payment, refunds []Refund, operations []CashOperation) *ShiftReport {
+	type key struct{ method, currency string }
+	type totals struct {
+		payments, refunds   int
+		sales, refundAmount money.Money
+	}
+	methods := map[key]*totals{}
+	method := func(name, code string) *totals {
+		k := key{name, code}
+		if methods[k] == nil {
+			methods[k] = &totals{}
+		}
+		return methods[k]
+	}
+
+	var sales, refunded, cashIn, cashOut money.Money
+	for _, p := range payments {
+		if p.Status != StatusCompleted && p.Status != StatusRefunded {
+			continue
+		}
+		t := method(p.PaymentMethod, p.Currency)
+		t.payments++
+		t.sales = t.sales.Add(p.Amount)
+		if p.Currency == shift.Currency {
+			sales = sales.Add(p.Amount)
+		}
+	}
+	for _, r := range refunds {
+		if r.Status != RefundCompleted {
+			continue
+		}
+		t := method(r.PaymentMethod, r.Currency)
+		t.refunds++
+		t.refundAmount = t.refundAmount.Add(r.Amount)
+		if r.Currency == shift.Currency {
+			refunded = refunded.Add(r.Amount)
+		}
+	}
+	for _, op := range operations {
+		switch op.Type {
+		case CashIn:
+			cashIn = cashIn.Add(op.Amount)
+		case CashOut:
+			cashOut = cashOut.Add(op.Amount)
+		}
+	}
+
+	report := &ShiftReport{
+		Type:        kind,
+		Shift:       shift,
+		Methods:     []MethodTotals{},
+		Sales:       sales,
+		Refunds:     refunded,
+		CashIn:      cashIn,
+		CashOut:     cashOut,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	expected := shift.OpeningFloat.Add(cashIn).Sub(cashOut)
+	for k, t := range methods {
+		report.Methods = append(report.Methods, MethodTotals{
+			PaymentMethod: k.method,
+			Currency:      k.currency,
+			Payments:      t.payments,
+			Sales:         t.sales,
+			Refunds:       t.refunds,
+			RefundAmount:  t.refundAmount,
+			Net:           t.sales.Sub(t.refundAmount),
+		})
+		if k.method == MethodCash && k.currency == shift.Currency {
+			expected = expected.Add(t.sales).Sub(t.refundAmount)
+		}
+	}
+	sort.Slice(report.Methods, func(i, j int) bool {
+		a, b := report.Methods[i], report.Methods[j]
+		if a.PaymentMethod != b.PaymentMethod {
+			return a.PaymentMethod < b.PaymentMethod
+		}
+		return a.Currency < b.Currency
+	})
+	report.ExpectedCash = expected
+
+	// По закрытой смене наличные по учету берутся зафиксированные при закрытии
+	if kind == ReportZ && shift.Status == ShiftClosed {
+		report.ExpectedCash = shift.ExpectedCash
+		counted, discrepancy := shift.CountedCash, shift.Discrepancy
+		report.CountedCash = &counted
+		report.Discrepancy = &discrepancy
+	}
+	return report
+}
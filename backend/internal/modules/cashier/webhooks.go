@@ -0,0 +1,93 @@
+package cashier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	// ErrWebhookSignature возвращается, если подпись уведомления не совпала
+	ErrWebhookSignature = errors.New("cashier: invalid webhook signature")
+	// ErrDuplicateWebhook возвращается, если уведомление с таким ID уже обработано
+	ErrDuplicateWebhook = errors.New("cashier: duplicate webhook event")
+)
+
+// Результаты обработки уведомления
+const (
+	WebhookProcessed = "processed" // Статус платежа обновлен
+	WebhookIgnored   = "ignored"   // Платеж уже в этом или более позднем статусе
+	WebhookNoPayment = "no_payment"
+	WebhookRejected  = "rejected" // Неверная подпись или формат
+)
+
+// WebhookEvent - уведомление провайдера об изменении статуса транзакции
+type WebhookEvent struct {
+	ID            string // ID события у провайдера, по нему отсекаются повторы
+	TransactionID string
+	Reference     string // AuthorizeRequest.Reference - ID платежа в кассе
+	Status        string // Новый статус платежа
+}
+
+// WebhookProvider - провайдер, присылающий уведомления о статусе платежей
+type WebhookProvider interface {
+	PaymentProvider
+	// ParseWebhook проверяет подпись уведомления и разбирает его.
+	// header возвращает значение заголовка запроса.
+	ParseWebhook(header func(key string) string, body []byte) (*WebhookEvent, error)
+}
+
+// Webhook - запись о полученном уведомлении. Сохраняется вместе с исходным телом запроса для аудита.
+type Webhook struct {
+	ID            int    `json:"id"`
+	Provider      string `json:"provider"`
+	EventID       string `json:"event_id"`
+	TransactionID string `json:"transaction_id"`
+	PaymentID     int    `json:"payment_id"`  // Из Reference до обработки, затем ID найденного платежа
+	CustomerID    int    `json:"customer_id"` // Компания найденного платежа
+	Status        string `json:"status"`
+	Result        string `json:"result"` // processed, ignored, no_payment, rejected
+	Payload       string `json:"payload"`
+	ReceivedAt    string `json:"received_at"`
+}
+
+// webhookTransitions - статусы, в которые уведомление может перевести платеж.
+// Уведомления могут приходить не по порядку: более раннее событие не откатывает статус.
+var webhookTransitions = map[string][]string{
+	StatusPending:    {StatusAuthorized, StatusCompleted, StatusFailed},
+	StatusAuthorized: {StatusCompleted, StatusVoided, StatusFailed},
+	StatusCompleted:  {StatusRefunded},
+}
+
+// webhookCanApply сообщает, может ли уведомление перевести платеж из from в to
+func webhookCanApply(from, to string) bool {
+	for _, s := range webhookTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhook возвращает подпись тела уведомления: HMAC-SHA256 в hex
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook проверяет подпись тела уведомления за постоянное время.
+// С пустым секретом любая подпись считается неверной.
+func VerifyWebhook(secret, body []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
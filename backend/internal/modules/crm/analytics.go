@@ -0,0 +1,208 @@
+package crm
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/report"
+	"kit8-backend/internal/core/tenant"
+)
+
+// StageChange - запись истории стадий сделки: попадание сделки на стадию
+type StageChange struct {
+	ID          int    `json:"id"`
+	DealID      int    `json:"deal_id"`
+	PipelineID  int    `json:"pipeline_id"`   // Воронка стадии to_stage; 0 - воронка удалена
+	FromStageID int    `json:"from_stage_id"` // 0 - сделка создана или стадия удалена
+	FromStage   string `json:"from_stage"`    // Название прежней стадии на момент перехода
+	ToStageID   int    `json:"to_stage_id"`   // 0 - стадия удалена
+	ToStage     string `json:"to_stage"`      // Название стадии на момент перехода
+	ToKind      string `json:"to_kind"`       // Вид стадии на момент перехода: open, won, lost
+	ChangedBy   int    `json:"changed_by"`    // ID пользователя; 0 - запрос по API-ключу
+	ChangedAt   string `json:"changed_at"`
+}
+
+// StageAnalytics - показатели стадии воронки за период
+type StageAnalytics struct {
+	StageID        int     `json:"stage_id"`
+	Stage          string  `json:"stage"`
+	Kind           string  `json:"kind"`
+	Entered        int     `json:"entered"`         // Сделки, попавшие на стадию в периоде
+	Advanced       int     `json:"advanced"`        // Из них позже перешли на следующие стадии, кроме проигрышных
+	ConversionRate float64 `json:"conversion_rate"` // advanced от entered, %
+	AverageSeconds int64   `json:"average_seconds"` // Среднее время на стадии; только для открытых стадий
+}
+
+// PeriodAnalytics - закрытые сделки за период группировки
+type PeriodAnalytics struct {
+	Period  string  `json:"period"` // Начало периода, YYYY-MM-DD
+	Won     int     `json:"won"`
+	Lost    int     `json:"lost"`
+	WinRate float64 `json:"win_rate"` // won от won + lost, %
+}
+
+// DealAnalytics - скорость движения сделок по воронке
+type DealAnalytics struct {
+	PipelineID int `json:"pipeline_id"`
+	report.Period
+	Stages  []StageAnalytics  `json:"stages"`
+	Won     int               `json:"won"`
+	Lost    int               `json:"lost"`
+	WinRate float64           `json:"win_rate"`
+	Periods []PeriodAnalytics `json:"periods"`
+}
+
+// GetDealAnalytics возвращает среднее время на стадиях, конверсию стадий и долю
+// выигранных сделок по периодам для воронки pipeline_id (по умолчанию - воронки по умолчанию)
+func (ctrl *Controller) GetDealAnalytics(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	var pipeline *Pipeline
+	if id := c.Query("pipeline_id"); id != "" {
+		pipelineID, err := strconv.Atoi(id)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid pipeline ID"})
+		}
+		pipeline, err = ctrl.pipelines.GetPipeline(c.UserContext(), customerID, pipelineID)
+		if errors.Is(err, ErrNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Pipeline not found"})
+		}
+	} else {
+		pipeline, err = ctrl.pipelines.DefaultPipeline(c.UserContext(), customerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	changes, err := ctrl.deals.StageChanges(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(analyze(pipeline, changes, period, time.Now()))
+}
+
+// analyze считает показатели воронки pipeline по истории стадий changes, упорядоченной
+// по сделке и времени. Пребывание на стадии и попадание на нее относятся к периоду по
+// моменту попадания; пребывание, которое еще длится, считается до now. Сделка закрыта в
+// момент последнего перехода, если он привел ее на выигрышную или проигрышную стадию воронки.
+func analyze(pipeline *Pipeline, changes []StageChange, period report.Range, now time.Time) *DealAnalytics {
+	result := &DealAnalytics{
+		PipelineID: pipeline.ID,
+		Period:     period.Period(),
+		Stages:     []StageAnalytics{},
+		Periods:    []PeriodAnalytics{},
+	}
+
+	index := make(map[int]int, len(pipeline.Stages))
+	for i, s := range pipeline.Stages {
+		index[s.ID] = i
+		result.Stages = append(result.Stages, StageAnalytics{StageID: s.ID, Stage: s.Name, Kind: s.Kind})
+	}
+	durations := make([]time.Duration, len(pipeline.Stages))
+	stays := make([]int, len(pipeline.Stages))
+
+	closed := make(map[string]*PeriodAnalytics)
+	var bounds report.Bounds
+	for start := 0; start < len(changes); {
+		end := start
+		for end < len(changes) && changes[end].DealID == changes[start].DealID {
+			end++
+		}
+		deal := changes[start:end]
+		start = end
+
+		entered := make(map[int]bool)
+		advanced := make(map[int]bool)
+		for i, change := range deal {
+			pos, ok := index[change.ToStageID]
+			if !ok || change.PipelineID != pipeline.ID {
+				continue
+			}
+			at, _ := report.Parse(change.ChangedAt)
+
+			// Переход засчитывается как продвижение всем стадиям воронки, пройденным раньше
+			if pipeline.Stages[pos].Kind != StageLost {
+				for prev := range entered {
+					if prev < pos {
+						advanced[prev] = true
+					}
+				}
+			}
+			if !period.Contains(at) {
+				continue
+			}
+			entered[pos] = true
+
+			if pipeline.Stages[pos].Kind == StageOpen {
+				until := now
+				if i+1 < len(deal) {
+					until, _ = report.Parse(deal[i+1].ChangedAt)
+				}
+				durations[pos] += until.Sub(at)
+				stays[pos]++
+			}
+		}
+		for pos := range entered {
+			result.Stages[pos].Entered++
+			if advanced[pos] {
+				result.Stages[pos].Advanced++
+			}
+		}
+
+		final := deal[len(deal)-1]
+		pos, ok := index[final.ToStageID]
+		if !ok || final.PipelineID != pipeline.ID || pipeline.Stages[pos].Kind == StageOpen {
+			continue
+		}
+		at, _ := report.Parse(final.ChangedAt)
+		if !period.Contains(at) {
+			continue
+		}
+		label := period.Label(at)
+		p, ok := closed[label]
+		if !ok {
+			p = &PeriodAnalytics{Period: label}
+			closed[label] = p
+		}
+		if pipeline.Stages[pos].Kind == StageWon {
+			p.Won++
+			result.Won++
+		} else {
+			p.Lost++
+			result.Lost++
+		}
+		bounds.Add(at)
+	}
+
+	for pos := range result.Stages {
+		s := &result.Stages[pos]
+		s.ConversionRate = report.Percent(s.Advanced, s.Entered)
+		if stays[pos] > 0 {
+			s.AverageSeconds = int64((durations[pos] / time.Duration(stays[pos])).Seconds())
+		}
+	}
+	result.WinRate = report.Percent(result.Won, result.Won+result.Lost)
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		p := PeriodAnalytics{Period: label}
+		if found, ok := closed[label]; ok {
+			p = *found
+		}
+		p.WinRate = report.Percent(p.Won, p.Won+p.Lost)
+		result.Periods = append(result.Periods, p)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package crm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/sales"
+	"kit8-backend/internal/core/stock"
+	"kit8-backend/internal/core/tenant"
+)
+
+// ConvertDeal создает по выигранной сделке заказ для ее контакта с товарами сделки
+// и связывает сделку с заказом. По сделке создается только один заказ.
+func (ctrl *Controller) ConvertDeal(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID сделки из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid deal ID"})
+	}
+
+	current, err := ctrl.deals.GetDeal(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case current.OrderID != 0:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":    "Deal is already converted to an order",
+			"order_id": current.OrderID,
+		})
+	case current.StageKind != StageWon:
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Only won deals can be converted to an order"})
+	case current.ContactID == 0:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Deal has no contact"})
+	case len(current.Items) == 0:
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Deal has no products"})
+	}
+
+	// Заказ создается под блокировкой сделки: параллельный запрос получит ErrDealConverted
+	deal, err := ctrl.deals.ConvertDeal(c.UserContext(), customerID, id, func(ctx context.Context, deal *Deal) (int, error) {
+		return ctrl.orders.CreateOrder(ctx, customerID, salesDeal(deal))
+	})
+	var insufficient *stock.InsufficientError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	case errors.Is(err, ErrDealConverted), errors.Is(err, sales.ErrDealConverted):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Deal is already converted to an order"})
+	case errors.Is(err, ErrDealNotWon):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Only won deals can be converted to an order"})
+	case errors.As(err, &insufficient):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Insufficient stock", "shortages": insufficient.Shortages})
+	case err != nil:
+		return err
+	}
+
+	// Возвращаем сделку со ссылкой на созданный заказ
+	return c.JSON(deal)
+}
+
+// salesDeal возвращает сделку в виде, в котором по ней создается заказ
+func salesDeal(deal *Deal) sales.Deal {
+	lines := make([]sales.Line, 0, len(deal.Items))
+	for _, item := range deal.Items {
+		lines = append(lines, sales.Line{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       item.Price,
+		})
+	}
+	return sales.Deal{
+		ID:        deal.ID,
+		ContactID: deal.ContactID,
+		Currency:  deal.Currency,
+		Lines:     lines,
+	}
+}
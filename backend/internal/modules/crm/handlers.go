@@ -1,10 +1,21 @@
 package crm
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/auth"
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/report"
+	"kit8-backend/internal/core/sales"
+	"kit8-backend/internal/core/tenant"
 )
 
 // Contact представляет контакт в CRM
@@ -15,50 +26,84 @@ type Contact struct {
 	Phone      string `json:"phone"`
 	Company    string `json:"company"`
 	CustomerID int    `json:"customer_id"` // ID компании
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
 }
 
 // Deal представляет сделку в CRM
 type Deal struct {
-	ID         int     `json:"id"`
-	Title      string  `json:"title"`
-	Value      float64 `json:"value"`
-	ContactID  int     `json:"contact_id"`
-	Stage      string  `json:"stage"`       // new, in-progress, won, lost
-	CustomerID int     `json:"customer_id"` // ID компании
-	CreatedAt  string  `json:"created_at"`
-	UpdatedAt  string  `json:"updated_at"`
+	ID         int         `json:"id"`
+	Title      string      `json:"title"`
+	Value      money.Money `json:"value"`
+	Currency   string      `json:"currency"` // Валюта суммы; по умолчанию - базовая валюта компании
+	ContactID  int         `json:"contact_id"`
+	PipelineID int         `json:"pipeline_id"` // Воронка сделки; по умолчанию - воронка компании по умолчанию
+	StageID    int         `json:"stage_id"`    // Стадия воронки; по умолчанию - первая стадия
+	Stage      string      `json:"stage"`       // Название стадии; можно передать вместо stage_id
+	StageKind  string      `json:"stage_kind"`  // open, won, lost; задается стадией
+	Items      []DealItem  `json:"items"`       // Товары сделки; при конвертации становятся позициями заказа
+	OrderID    int         `json:"order_id"`    // Заказ, созданный по сделке; 0 - сделка не конвертирована
+	CustomerID int         `json:"customer_id"` // ID компании
+	CreatedAt  string      `json:"created_at"`
+	UpdatedAt  string      `json:"updated_at"`
+}
+
+// DealItem - товар Склада, прикрепленный к сделке
+type DealItem struct {
+	ID          int         `json:"id"`
+	ProductID   int         `json:"product_id"` // 0 - товар удален со Склада
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"` // Цена единицы в валюте сделки
 }
 
-// DealStats представляет статистику по сделкам
+// DealStats представляет статистику по сделкам, созданным за период
 type DealStats struct {
-	TotalCount   int     `json:"total_count"`
-	WonCount     int     `json:"won_count"`
-	LostCount    int     `json:"lost_count"`
-	TotalValue   float64 `json:"total_value"`
-	AverageValue float64 `json:"average_value"`
+	report.Period
+	TotalCount   int              `json:"total_count"`
+	WonCount     int              `json:"won_count"`     // Из них сейчас на выигрышной стадии
+	LostCount    int              `json:"lost_count"`    // Из них сейчас на проигрышной стадии
+	TotalValue   money.Money      `json:"total_value"`   // В базовой валюте компании
+	AverageValue money.Money      `json:"average_value"` // В базовой валюте компании
+	Currency     string           `json:"currency"`      // Базовая валюта компании
+	Series       []DealStatsPoint `json:"series"`
+}
+
+// DealStatsPoint - сделки, созданные за период группировки
+type DealStatsPoint struct {
+	Period    string      `json:"period"` // Начало периода, YYYY-MM-DD
+	Count     int         `json:"count"`
+	WonCount  int         `json:"won_count"`
+	LostCount int         `json:"lost_count"`
+	Value     money.Money `json:"value"` // В базовой валюте компании
 }
 
 // Контроллер CRM
 type Controller struct {
-	// Здесь будут зависимости, например, сервисы и репозитории
-	// Для упрощения в этом примере будем использовать заглушку
+	contacts   ContactRepository
+	deals      DealRepository
+	pipelines  PipelineRepository
+	currencies currency.Currencies
+	orders     sales.Orders // Заказы, создаваемые по выигранным сделкам
 }
 
 // NewController создает новый контроллер CRM
-func NewController() *Controller {
-	return &Controller{}
+func NewController(contacts ContactRepository, deals DealRepository, pipelines PipelineRepository, currencies currency.Currencies,
+	orders sales.Orders) *Controller {
+	return &Controller{contacts: contacts, deals: deals, pipelines: pipelines, currencies: currencies, orders: orders}
 }
 
 // GetContacts возвращает список контактов
 func (ctrl *Controller) GetContacts(c *fiber.Ctx) error {
-	// Получаем ID компании из контекста (предполагается, что он был установлен в middleware)
-	customerID := c.Locals("customer_id").(int)
+	// Получаем ID компании из контекста (устанавливается в tenant middleware)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// и фильтрация по customerID
-	contacts := []Contact{
-		{ID: 1, Name: "Иван Петров", Email: "ivan@example.com", Phone: "+71234567890", Company: "ООО Ромашка", CustomerID: customerID},
-		{ID: 2, Name: "Мария Сидорова", Email: "maria@example.com", Phone: "+71234567891", Company: "ИП Сидоров", CustomerID: customerID},
+	contacts, err := ctrl.contacts.ListContacts(c.UserContext(), customerID)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(contacts)
@@ -67,7 +112,10 @@ func (ctrl *Controller) GetContacts(c *fiber.Ctx) error {
 // CreateContact создает новый контакт
 func (ctrl *Controller) CreateContact(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
 	// Парсим тело запроса
 	var contact Contact
@@ -78,9 +126,10 @@ func (ctrl *Controller) CreateContact(c *fiber.Ctx) error {
 	// Устанавливаем ID компании для нового контакта
 	contact.CustomerID = customerID
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для сохранения контакта в базе данных
-	// contact.ID = generateNextID() // генерация нового ID
+	// Сохраняем контакт, ID назначается хранилищем
+	if err := ctrl.contacts.CreateContact(c.UserContext(), &contact); err != nil {
+		return err
+	}
 
 	// Возвращаем созданный контакт
 	return c.JSON(contact)
@@ -89,7 +138,10 @@ func (ctrl *Controller) CreateContact(c *fiber.Ctx) error {
 // UpdateContact обновляет существующий контакт
 func (ctrl *Controller) UpdateContact(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
 	// Получаем ID контакта из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
@@ -103,30 +155,42 @@ func (ctrl *Controller) UpdateContact(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обновления контакта в базе данных с проверкой,
-	// принадлежит ли он текущей компании (customerID)
-
-	// Возвращаем обновленный контакт
+	// Обновляем контакт; чужие контакты для хранилища не существуют
 	updatedContact.ID = id
 	updatedContact.CustomerID = customerID
+	err = ctrl.contacts.UpdateContact(c.UserContext(), &updatedContact)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Возвращаем обновленный контакт
 	return c.JSON(updatedContact)
 }
 
 // DeleteContact удаляет контакт
 func (ctrl *Controller) DeleteContact(c *fiber.Ctx) error {
 	// Получаем ID контакта из параметров URL
-	_, err := strconv.Atoi(c.Params("id"))
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid contact ID"})
 	}
 
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для удаления контакта из базы данных с проверкой,
-	// принадлежит ли он текущей компании (customerID)
+	err = ctrl.contacts.DeleteContact(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return err
+	}
 
 	// Возвращаем успешный ответ
 	return c.SendStatus(http.StatusOK)
@@ -135,14 +199,14 @@ func (ctrl *Controller) DeleteContact(c *fiber.Ctx) error {
 // GetDeals возвращает список сделок
 func (ctrl *Controller) GetDeals(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// и фильтрация по customerID
-	deals := []Deal{
-		{ID: 1, Title: "Сделка 1", Value: 10000.0, ContactID: 1, Stage: "new", CustomerID: customerID, CreatedAt: "2023-01-01T00:00:00Z", UpdatedAt: "2023-01-01T00:00:00Z"},
-		{ID: 2, Title: "Сделка 2", Value: 25000.0, ContactID: 2, Stage: "in-progress", CustomerID: customerID, CreatedAt: "2023-01-02T00:00:00Z", UpdatedAt: "2023-01-02T00:00:00Z"},
-		{ID: 3, Title: "Сделка 3", Value: 15000.0, ContactID: 1, Stage: "won", CustomerID: customerID, CreatedAt: "2023-01-03T00:00:00Z", UpdatedAt: "2023-01-03T00:00:00Z"},
+	deals, err := ctrl.deals.ListDeals(c.UserContext(), customerID)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(deals)
@@ -151,7 +215,10 @@ func (ctrl *Controller) GetDeals(c *fiber.Ctx) error {
 // CreateDeal создает новую сделку
 func (ctrl *Controller) CreateDeal(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
 	// Парсим тело запроса
 	var deal Deal
@@ -159,13 +226,42 @@ func (ctrl *Controller) CreateDeal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Устанавливаем ID компании для новой сделки
+	// Устанавливаем ID компании для новой сделки; заказ создается конвертацией сделки
 	deal.CustomerID = customerID
-	deal.Stage = "new" // Устанавливаем начальный этап
+	deal.OrderID = 0
+	if reason := invalidItems(deal.Items); reason != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": reason})
+	}
+	if deal.Items == nil {
+		deal.Items = []DealItem{}
+	}
+	deal.Currency, err = currency.Resolve(c.UserContext(), ctrl.currencies, customerID, deal.Currency)
+	if errors.Is(err, currency.ErrInvalidCurrency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Без воронки и стадии сделка попадает на первую стадию воронки по умолчанию
+	if reason, err := ctrl.placeDeal(c.UserContext(), &deal, nil); err != nil || reason != "" {
+		return invalidStage(c, reason, err)
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для сохранения сделки в базе данных
-	// deal.ID = generateNextID() // генерация нового ID
+	// Сохраняем сделку, ID и даты назначаются хранилищем
+	err = ctrl.deals.CreateDeal(c.UserContext(), &deal, changedBy(c))
+	if errors.Is(err, ErrStageNotFound) {
+		return invalidStage(c, "Stage not found in the pipeline", nil)
+	}
+	if errors.Is(err, ErrProductNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Deal product not found"})
+	}
+	if errors.Is(err, ErrContactNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return err
+	}
 
 	// Возвращаем созданную сделку
 	return c.JSON(deal)
@@ -174,7 +270,10 @@ func (ctrl *Controller) CreateDeal(c *fiber.Ctx) error {
 // UpdateDeal обновляет существующую сделку
 func (ctrl *Controller) UpdateDeal(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
 	// Получаем ID сделки из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
@@ -188,72 +287,521 @@ func (ctrl *Controller) UpdateDeal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обновления сделки в базе данных с проверкой,
-	// принадлежит ли она текущей компании (customerID)
+	// Без валюты сделка остается в прежней валюте
+	if updatedDeal.Currency != "" && !currency.Valid(updatedDeal.Currency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
 
-	// Возвращаем обновленную сделку
+	// Без items товары сделки не меняются
+	if reason := invalidItems(updatedDeal.Items); reason != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": reason})
+	}
+
+	// Чужие сделки для хранилища не существуют
+	current, err := ctrl.deals.GetDeal(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Без воронки и стадии сделка остается на прежней стадии
 	updatedDeal.ID = id
 	updatedDeal.CustomerID = customerID
+	if reason, err := ctrl.placeDeal(c.UserContext(), &updatedDeal, current); err != nil || reason != "" {
+		return invalidStage(c, reason, err)
+	}
+
+	// Обновляем сделку
+	err = ctrl.deals.UpdateDeal(c.UserContext(), &updatedDeal, changedBy(c))
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	}
+	if errors.Is(err, ErrStageNotFound) {
+		return invalidStage(c, "Stage not found in the pipeline", nil)
+	}
+	if errors.Is(err, ErrProductNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Deal product not found"})
+	}
+	if errors.Is(err, ErrContactNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Возвращаем обновленную сделку
 	return c.JSON(updatedDeal)
 }
 
 // DeleteDeal удаляет сделку
 func (ctrl *Controller) DeleteDeal(c *fiber.Ctx) error {
 	// Получаем ID сделки из параметров URL
-	_, err := strconv.Atoi(c.Params("id"))
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid deal ID"})
 	}
 
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для удаления сделки из базы данных с проверкой,
-	// принадлежит ли она текущей компании (customerID)
+	err = ctrl.deals.DeleteDeal(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	}
+	if err != nil {
+		return err
+	}
 
 	// Возвращаем успешный ответ
 	return c.SendStatus(http.StatusOK)
 }
 
-// GetDealStats возвращает статистику по сделкам
+// GetDealHistory возвращает историю стадий сделки
+func (ctrl *Controller) GetDealHistory(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID сделки из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid deal ID"})
+	}
+
+	history, err := ctrl.deals.StageHistory(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deal not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(history)
+}
+
+// changedBy возвращает ID пользователя запроса; 0 - запрос по API-ключу
+func changedBy(c *fiber.Ctx) int {
+	if claims, ok := auth.CurrentClaims(c); ok {
+		return claims.UserID()
+	}
+	return 0
+}
+
+// placeDeal проверяет воронку и стадию сделки и заполняет их по умолчанию.
+// Воронка по умолчанию - воронка сохраненной сделки current, а для новой сделки - воронка
+// компании по умолчанию. Стадию можно указать по ID или названию; без нее сделка остается
+// на прежней стадии, а при переходе в другую воронку или создании - на первой стадии воронки.
+// Возвращает причину, по которой сделку нельзя сохранить; пусто - сделка корректна.
+func (ctrl *Controller) placeDeal(ctx context.Context, deal *Deal, current *Deal) (string, error) {
+	var pipeline *Pipeline
+	var err error
+	switch {
+	case deal.PipelineID != 0:
+		pipeline, err = ctrl.pipelines.GetPipeline(ctx, deal.CustomerID, deal.PipelineID)
+	case current != nil:
+		pipeline, err = ctrl.pipelines.GetPipeline(ctx, deal.CustomerID, current.PipelineID)
+	default:
+		pipeline, err = ctrl.pipelines.DefaultPipeline(ctx, deal.CustomerID)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return "Pipeline not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var stage Stage
+	found := true
+	switch {
+	case deal.StageID != 0:
+		stage, found = pipeline.Stage(deal.StageID)
+	case deal.Stage != "":
+		stage, found = pipeline.StageByName(deal.Stage)
+	case current != nil && current.PipelineID == pipeline.ID:
+		stage, found = pipeline.Stage(current.StageID)
+	default:
+		stage = pipeline.Stages[0]
+	}
+	if !found {
+		return "Stage not found in the pipeline", nil
+	}
+
+	deal.PipelineID, deal.StageID = pipeline.ID, stage.ID
+	deal.Stage, deal.StageKind = stage.Name, stage.Kind
+	return "", nil
+}
+
+// invalidItems проверяет товары сделки и возвращает причину, по которой их нельзя
+// сохранить; пусто - товары корректны
+func invalidItems(items []DealItem) string {
+	for i := range items {
+		item := &items[i]
+		item.ProductName = strings.TrimSpace(item.ProductName)
+		switch {
+		case item.ProductID <= 0:
+			return "Deal item product_id is required"
+		case item.Quantity <= 0:
+			return "Item quantity must be positive"
+		case item.Price.IsNegative():
+			return "Item price must not be negative"
+		}
+	}
+	return ""
+}
+
+// invalidStage отвечает 400 с причиной reason или возвращает ошибку err
+func invalidStage(c *fiber.Ctx, reason string, err error) error {
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": reason})
+}
+
+// GetDealStats возвращает статистику по сделкам, созданным за период from - to,
+// с рядом по группам group. Суммы сделок пересчитываются в базовую валюту компании
+// по курсу на дату создания сделки.
 func (ctrl *Controller) GetDealStats(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения статистики из базы данных с фильтрацией по customerID
-	stats := DealStats{
-		TotalCount:   10,
-		WonCount:     4,
-		LostCount:    2,
-		TotalValue:   125000.0,
-		AverageValue: 12500.0,
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	stats, err := ctrl.dealStats(c.UserContext(), customerID, period)
+	var noRate *currency.NoRateError
+	if errors.As(err, &noRate) {
+		return currency.NoRate(c, noRate)
+	}
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(stats)
 }
 
-// CRMStats представляет общую статистику CRM
+// dealStats подводит итоги по сделкам компании, созданным за период, в ее базовой валюте
+func (ctrl *Controller) dealStats(ctx context.Context, customerID int, period report.Range) (*DealStats, error) {
+	deals, err := ctrl.deals.ListDeals(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := ctrl.currencies.Converter(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DealStats{Period: period.Period(), Currency: conv.Base()}
+	points := make(map[string]*DealStatsPoint)
+	var bounds report.Bounds
+	for _, deal := range deals {
+		created := currency.At(deal.CreatedAt)
+		if !period.Contains(created) {
+			continue
+		}
+		value, err := conv.ToBase(deal.Value.In(deal.Currency), created)
+		if err != nil {
+			return nil, err
+		}
+
+		label := period.Label(created)
+		point, ok := points[label]
+		if !ok {
+			point = &DealStatsPoint{Period: label}
+			points[label] = point
+		}
+		bounds.Add(created)
+
+		stats.TotalCount++
+		point.Count++
+		switch deal.StageKind {
+		case StageWon:
+			stats.WonCount++
+			point.WonCount++
+		case StageLost:
+			stats.LostCount++
+			point.LostCount++
+		}
+		stats.TotalValue = stats.TotalValue.Add(value)
+		point.Value = point.Value.Add(value)
+	}
+	if stats.TotalCount > 0 {
+		stats.AverageValue = stats.TotalValue.Div(int64(stats.TotalCount))
+	}
+
+	stats.Series = []DealStatsPoint{}
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		point := DealStatsPoint{Period: label}
+		if found, ok := points[label]; ok {
+			point = *found
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	return stats, nil
+}
+
+// GetPipelines возвращает воронки сделок компании, воронку по умолчанию - первой
+func (ctrl *Controller) GetPipelines(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	pipelines, err := ctrl.pipelines.ListPipelines(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(pipelines)
+}
+
+// GetPipeline возвращает воронку со стадиями
+func (ctrl *Controller) GetPipeline(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID воронки из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid pipeline ID"})
+	}
+
+	pipeline, err := ctrl.pipelines.GetPipeline(c.UserContext(), customerID, id)
+	if err != nil {
+		return pipelineError(c, err)
+	}
+
+	return c.JSON(pipeline)
+}
+
+// CreatePipeline создает воронку; стадии идут в порядке списка
+func (ctrl *Controller) CreatePipeline(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Парсим тело запроса
+	var pipeline Pipeline
+	if err := c.BodyParser(&pipeline); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	pipeline.ID = 0
+	pipeline.CustomerID = customerID
+	if reason := pipeline.arrange(nil); reason != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": reason})
+	}
+
+	// Сохраняем воронку, ID воронки и стадий назначаются хранилищем
+	if err := ctrl.pipelines.CreatePipeline(c.UserContext(), &pipeline); err != nil {
+		return err
+	}
+
+	// Возвращаем созданную воронку
+	return c.JSON(pipeline)
+}
+
+// UpdatePipeline изменяет воронку: поля, которых нет в запросе, не меняются.
+// Список стадий заменяет прежний: стадии с ID переименовываются и переставляются,
+// без ID - добавляются, отсутствующие - удаляются, если на них нет сделок.
+func (ctrl *Controller) UpdatePipeline(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID воронки из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid pipeline ID"})
+	}
+
+	current, err := ctrl.pipelines.GetPipeline(c.UserContext(), customerID, id)
+	if err != nil {
+		return pipelineError(c, err)
+	}
+
+	// Стадии из запроса не смешиваются с сохраненными
+	pipeline := *current
+	pipeline.Stages = nil
+	if err := c.BodyParser(&pipeline); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if pipeline.Stages == nil {
+		pipeline.Stages = current.Stages
+	}
+
+	pipeline.ID = id
+	pipeline.CustomerID = customerID
+	if reason := pipeline.arrange(current); reason != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": reason})
+	}
+
+	if err := ctrl.pipelines.UpdatePipeline(c.UserContext(), &pipeline); err != nil {
+		return pipelineError(c, err)
+	}
+
+	// Возвращаем обновленную воронку
+	return c.JSON(pipeline)
+}
+
+// DeletePipeline удаляет воронку без сделок; воронку по умолчанию удалить нельзя
+func (ctrl *Controller) DeletePipeline(c *fiber.Ctx) error {
+	// Получаем ID воронки из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid pipeline ID"})
+	}
+
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := ctrl.pipelines.DeletePipeline(c.UserContext(), customerID, id); err != nil {
+		return pipelineError(c, err)
+	}
+
+	// Возвращаем успешный ответ
+	return c.SendStatus(http.StatusOK)
+}
+
+// pipelineError отвечает на ошибку хранилища воронок
+func pipelineError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Pipeline not found"})
+	case errors.Is(err, ErrStageInUse):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Stage has deals; move them to another stage first"})
+	case errors.Is(err, ErrPipelineInUse):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Pipeline has deals; move or delete them first"})
+	case errors.Is(err, ErrDefaultPipeline):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Make another pipeline the default first"})
+	}
+	return err
+}
+
+// CRMStats представляет общую статистику CRM за период
 type CRMStats struct {
-	Contacts int     `json:"contacts"`
-	Deals    int     `json:"deals"`
-	Value    float64 `json:"value"`
+	report.Period
+	Contacts int             `json:"contacts"` // Контакты, добавленные за период
+	Deals    int             `json:"deals"`    // Сделки, созданные за период
+	Value    money.Money     `json:"value"`    // Сумма этих сделок в базовой валюте компании
+	Currency string          `json:"currency"` // Базовая валюта компании
+	Series   []CRMStatsPoint `json:"series"`
 }
 
-// GetCRMStats возвращает общую статистику CRM
+// CRMStatsPoint - новые контакты и сделки за период группировки
+type CRMStatsPoint struct {
+	Period   string      `json:"period"` // Начало периода, YYYY-MM-DD
+	Contacts int         `json:"contacts"`
+	Deals    int         `json:"deals"`
+	Value    money.Money `json:"value"`
+}
+
+// GetCRMStats возвращает общую статистику CRM за период from - to с рядом по группам group
 func (ctrl *Controller) GetCRMStats(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
 
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения статистики из базы данных с фильтрацией по customerID
-	stats := CRMStats{
-		Contacts: 15,
-		Deals:    10,
-		Value:    12500.0,
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	stats, err := ctrl.crmStats(c.UserContext(), customerID, period)
+	var noRate *currency.NoRateError
+	if errors.As(err, &noRate) {
+		return currency.NoRate(c, noRate)
+	}
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(stats)
 }
+
+// crmStats подводит итоги по контактам и сделкам компании, созданным за период
+func (ctrl *Controller) crmStats(ctx context.Context, customerID int, period report.Range) (*CRMStats, error) {
+	contacts, err := ctrl.contacts.ListContacts(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	deals, err := ctrl.deals.ListDeals(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := ctrl.currencies.Converter(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CRMStats{Period: period.Period(), Currency: conv.Base()}
+	points := make(map[string]*CRMStatsPoint)
+	var bounds report.Bounds
+	point := func(at time.Time) *CRMStatsPoint {
+		label := period.Label(at)
+		p, ok := points[label]
+		if !ok {
+			p = &CRMStatsPoint{Period: label}
+			points[label] = p
+		}
+		bounds.Add(at)
+		return p
+	}
+
+	for _, contact := range contacts {
+		created := currency.At(contact.CreatedAt)
+		if period.Contains(created) {
+			stats.Contacts++
+			point(created).Contacts++
+		}
+	}
+	for _, deal := range deals {
+		created := currency.At(deal.CreatedAt)
+		if !period.Contains(created) {
+			continue
+		}
+		value, err := conv.ToBase(deal.Value.In(deal.Currency), created)
+		if err != nil {
+			return nil, err
+		}
+		p := point(created)
+		p.Deals++
+		p.Value = p.Value.Add(value)
+		stats.Deals++
+		stats.Value = stats.Value.Add(value)
+	}
+
+	stats.Series = []CRMStatsPoint{}
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		p := CRMStatsPoint{Period: label}
+		if found, ok := points[label]; ok {
+			p = *found
+		}
+		stats.Series = append(stats.Series, p)
+	}
+	return stats, nil
+}
@@ -0,0 +1,155 @@
+package crm
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/tenant"
+)
+
+// testApp собирает маршруты CRM на хранилище в памяти. Компания запроса
+// берется из заголовка X-Customer-ID, вызывающий - владелец компании.
+func testApp() *fiber.App {
+	repo := NewMemoryRepository()
+	ctrl := NewController(repo, repo, repo, currency.NewService(currency.NewMemoryStore()), nil)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		customerID, err := strconv.Atoi(c.Get("X-Customer-ID"))
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+		tenant.SetCustomerID(c, customerID)
+		rbac.SetRole(c, rbac.RoleOwner)
+		return c.Next()
+	})
+	NewModule(ctrl).RegisterRoutes(app)
+	return app
+}
+
+// call выполняет запрос от имени компании customerID и разбирает JSON ответа в out, если он не nil
+func call(t *testing.T, app *fiber.App, customerID int, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Customer-ID", strconv.Itoa(customerID))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestContactCRUD(t *testing.T) {
+	app := testApp()
+
+	var created Contact
+	if code := call(t, app, 1, http.MethodPost, "/contacts", fiber.Map{"name": "Ann", "email": "ann@example.com"}, &created); code != http.StatusOK {
+		t.Fatalf("create: status %d", code)
+	}
+	if created.ID == 0 || created.CustomerID != 1 {
+		t.Fatalf("create: unexpected contact %+v", created)
+	}
+	path := "/contacts/" + strconv.Itoa(created.ID)
+
+	var updated Contact
+	if code := call(t, app, 1, http.MethodPut, path, fiber.Map{"name": "Anna", "email": "anna@example.com"}, &updated); code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	if updated.Name != "Anna" || updated.CreatedAt != created.CreatedAt {
+		t.Fatalf("update: unexpected contact %+v", updated)
+	}
+
+	var contacts []Contact
+	call(t, app, 1, http.MethodGet, "/contacts", nil, &contacts)
+	if len(contacts) != 1 || contacts[0].Name != "Anna" {
+		t.Fatalf("list: unexpected contacts %+v", contacts)
+	}
+
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("delete again: status %d, want 404", code)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	app := testApp()
+
+	var contact Contact
+	call(t, app, 1, http.MethodPost, "/contacts", fiber.Map{"name": "Ann", "email": "ann@example.com"}, &contact)
+	var deal Deal
+	if code := call(t, app, 1, http.MethodPost, "/deals", fiber.Map{"title": "Deal", "value": 100, "contact_id": contact.ID}, &deal); code != http.StatusOK {
+		t.Fatalf("create deal: status %d", code)
+	}
+	contactPath := "/contacts/" + strconv.Itoa(contact.ID)
+	dealPath := "/deals/" + strconv.Itoa(deal.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"update contact", http.MethodPut, contactPath, fiber.Map{"name": "Stolen", "email": "x@example.com"}},
+		{"delete contact", http.MethodDelete, contactPath, nil},
+		{"update deal", http.MethodPut, dealPath, fiber.Map{"title": "Stolen", "value": 1}},
+		{"delete deal", http.MethodDelete, dealPath, nil},
+		{"deal history", http.MethodGet, dealPath + "/history", nil},
+		{"convert deal", http.MethodPost, dealPath + "/convert", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, app, 2, tt.method, tt.path, tt.body, nil); code != http.StatusNotFound {
+				t.Fatalf("status %d, want 404", code)
+			}
+		})
+	}
+
+	var contacts []Contact
+	call(t, app, 2, http.MethodGet, "/contacts", nil, &contacts)
+	var deals []Deal
+	call(t, app, 2, http.MethodGet, "/deals", nil, &deals)
+	if len(contacts) != 0 || len(deals) != 0 {
+		t.Fatalf("other company sees %d contacts and %d deals", len(contacts), len(deals))
+	}
+
+	// Сделка другой компании не может ссылаться на чужой контакт
+	if code := call(t, app, 2, http.MethodPost, "/deals", fiber.Map{"title": "Deal", "value": 1, "contact_id": contact.ID}, nil); code != http.StatusBadRequest {
+		t.Fatalf("deal with foreign contact: status %d, want 400", code)
+	}
+}
+
+func TestIDsSharedAcrossCompanies(t *testing.T) {
+	app := testApp()
+
+	var first, second Contact
+	call(t, app, 1, http.MethodPost, "/contacts", fiber.Map{"name": "A", "email": "a@example.com"}, &first)
+	call(t, app, 2, http.MethodPost, "/contacts", fiber.Map{"name": "B", "email": "b@example.com"}, &second)
+	if first.ID == 0 || second.ID != first.ID+1 {
+		t.Fatalf("IDs %d and %d are not assigned from one sequence", first.ID, second.ID)
+	}
+}
@@ -0,0 +1,518 @@
+package crm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository - потокобезопасная реализация ContactRepository, DealRepository
+// и PipelineRepository в памяти процесса. Как и в PostgreSQL, ID назначаются общей
+// последовательностью для всех компаний, а записи других компаний не видны.
+type MemoryRepository struct {
+	mu             sync.RWMutex
+	contacts       map[int]Contact
+	deals          map[int]Deal
+	pipelines      map[int]Pipeline
+	history        map[int][]StageChange // История стадий по ID сделки
+	nextContactID  int
+	nextDealID     int
+	nextItemID     int
+	nextPipelineID int
+	nextStageID    int
+	nextHistoryID  int
+}
+
+// NewMemoryRepository создает пустой репозиторий CRM в памяти
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		contacts:  make(map[int]Contact),
+		deals:     make(map[int]Deal),
+		pipelines: make(map[int]Pipeline),
+		history:   make(map[int][]StageChange),
+	}
+}
+
+func (r *MemoryRepository) ListContacts(ctx context.Context, customerID int) ([]Contact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contacts := []Contact{}
+	for _, contact := range r.contacts {
+		if contact.CustomerID == customerID {
+			contacts = append(contacts, contact)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].ID < contacts[j].ID })
+	return contacts, nil
+}
+
+func (r *MemoryRepository) GetContact(ctx context.Context, customerID, id int) (*Contact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contact, ok := r.contacts[id]
+	if !ok || contact.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	return &contact, nil
+}
+
+func (r *MemoryRepository) CreateContact(ctx context.Context, contact *Contact) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextContactID++
+	contact.ID = r.nextContactID
+	contact.CreatedAt = now
+	contact.UpdatedAt = now
+	r.contacts[contact.ID] = *contact
+	return nil
+}
+
+func (r *MemoryRepository) UpdateContact(ctx context.Context, contact *Contact) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.contacts[contact.ID]
+	if !ok || existing.CustomerID != contact.CustomerID {
+		return ErrNotFound
+	}
+	contact.CreatedAt = existing.CreatedAt
+	contact.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.contacts[contact.ID] = *contact
+	return nil
+}
+
+func (r *MemoryRepository) DeleteContact(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.contacts[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	delete(r.contacts, id)
+
+	// Как ON DELETE SET NULL в PostgreSQL: сделки остаются без контакта
+	for dealID, deal := range r.deals {
+		if deal.ContactID == id {
+			deal.ContactID = 0
+			r.deals[dealID] = deal
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ListDeals(ctx context.Context, customerID int) ([]Deal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deals := []Deal{}
+	for _, deal := range r.deals {
+		if deal.CustomerID == customerID {
+			deals = append(deals, r.withStage(deal))
+		}
+	}
+	sort.Slice(deals, func(i, j int) bool { return deals[i].ID < deals[j].ID })
+	return deals, nil
+}
+
+func (r *MemoryRepository) GetDeal(ctx context.Context, customerID, id int) (*Deal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deal, ok := r.deals[id]
+	if !ok || deal.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	deal = r.withStage(deal)
+	return &deal, nil
+}
+
+func (r *MemoryRepository) CreateDeal(ctx context.Context, deal *Deal, changedBy int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.hasStage(deal) {
+		return ErrStageNotFound
+	}
+	if !r.hasContact(deal) {
+		return ErrContactNotFound
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextDealID++
+	deal.ID = r.nextDealID
+	deal.OrderID = 0
+	deal.CreatedAt = now
+	deal.UpdatedAt = now
+	r.numberItems(deal)
+	r.deals[deal.ID] = *deal
+	*deal = r.withStage(*deal)
+	r.recordStage(Deal{}, *deal, changedBy, now)
+	return nil
+}
+
+func (r *MemoryRepository) UpdateDeal(ctx context.Context, deal *Deal, changedBy int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.deals[deal.ID]
+	if !ok || existing.CustomerID != deal.CustomerID {
+		return ErrNotFound
+	}
+	if !r.hasStage(deal) {
+		return ErrStageNotFound
+	}
+	if !r.hasContact(deal) {
+		return ErrContactNotFound
+	}
+	if deal.Currency == "" {
+		deal.Currency = existing.Currency
+	}
+	if deal.Items == nil {
+		deal.Items = existing.Items
+	} else {
+		r.numberItems(deal)
+	}
+	deal.OrderID = existing.OrderID
+	deal.CreatedAt = existing.CreatedAt
+	deal.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.deals[deal.ID] = *deal
+	*deal = r.withStage(*deal)
+	if existing.StageID != deal.StageID {
+		r.recordStage(r.withStage(existing), *deal, changedBy, deal.UpdatedAt)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteDeal(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.deals[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	delete(r.deals, id)
+	delete(r.history, id)
+	return nil
+}
+
+func (r *MemoryRepository) ConvertDeal(ctx context.Context, customerID, id int, create func(ctx context.Context, deal *Deal) (int, error)) (*Deal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.deals[id]
+	if !ok || existing.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	if existing.OrderID != 0 {
+		return nil, ErrDealConverted
+	}
+
+	// Сделка заблокирована, пока создается заказ: второй запрос увидит его ID,
+	// а перенос сделки на другую стадию дождется конвертации
+	deal := r.withStage(existing)
+	if deal.StageKind != StageWon {
+		return nil, ErrDealNotWon
+	}
+	orderID, err := create(ctx, &deal)
+	if err != nil {
+		return nil, err
+	}
+	existing.OrderID = orderID
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.deals[id] = existing
+
+	deal = r.withStage(existing)
+	return &deal, nil
+}
+
+// UnlinkOrder реализует sales.Deals: снимает со сделки ссылку на удаленный заказ
+func (r *MemoryRepository) UnlinkOrder(ctx context.Context, customerID, dealID, orderID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.deals[dealID]
+	if !ok || existing.CustomerID != customerID || existing.OrderID != orderID {
+		return nil
+	}
+	existing.OrderID = 0
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.deals[dealID] = existing
+	return nil
+}
+
+// ContactExists реализует sales.Contacts: проверяет клиента заказа
+func (r *MemoryRepository) ContactExists(ctx context.Context, customerID, id int) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contact, ok := r.contacts[id]
+	return ok && contact.CustomerID == customerID, nil
+}
+
+// hasContact сообщает, что сделка без контакта или ее контакт принадлежит компании сделки.
+// Вызывается под блокировкой.
+func (r *MemoryRepository) hasContact(deal *Deal) bool {
+	if deal.ContactID == 0 {
+		return true
+	}
+	contact, ok := r.contacts[deal.ContactID]
+	return ok && contact.CustomerID == deal.CustomerID
+}
+
+// numberItems назначает ID товарам сделки. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) numberItems(deal *Deal) {
+	deal.Items = append([]DealItem{}, deal.Items...)
+	for i := range deal.Items {
+		r.nextItemID++
+		deal.Items[i].ID = r.nextItemID
+	}
+}
+
+// recordStage записывает в историю переход сделки со стадии сделки from (пустой
+// при создании) на стадию сделки to. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) recordStage(from, to Deal, changedBy int, at string) {
+	r.nextHistoryID++
+	r.history[to.ID] = append(r.history[to.ID], StageChange{
+		ID:          r.nextHistoryID,
+		DealID:      to.ID,
+		PipelineID:  to.PipelineID,
+		FromStageID: from.StageID,
+		FromStage:   from.Stage,
+		ToStageID:   to.StageID,
+		ToStage:     to.Stage,
+		ToKind:      to.StageKind,
+		ChangedBy:   changedBy,
+		ChangedAt:   at,
+	})
+}
+
+func (r *MemoryRepository) StageHistory(ctx context.Context, customerID, id int) ([]StageChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deal, ok := r.deals[id]
+	if !ok || deal.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	return append([]StageChange{}, r.history[id]...), nil
+}
+
+func (r *MemoryRepository) StageChanges(ctx context.Context, customerID int) ([]StageChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []int{}
+	for id, deal := range r.deals {
+		if deal.CustomerID == customerID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	changes := []StageChange{}
+	for _, id := range ids {
+		changes = append(changes, r.history[id]...)
+	}
+	return changes, nil
+}
+
+// hasStage сообщает, есть ли стадия сделки в ее воронке. Вызывается под блокировкой.
+func (r *MemoryRepository) hasStage(deal *Deal) bool {
+	pipeline, ok := r.pipelines[deal.PipelineID]
+	if !ok || pipeline.CustomerID != deal.CustomerID {
+		return false
+	}
+	_, ok = pipeline.Stage(deal.StageID)
+	return ok
+}
+
+// withStage заполняет название и вид стадии сделки, как JOIN в PostgreSQL, и копирует
+// товары сделки, чтобы они не разделялись с хранилищем. Вызывается под блокировкой.
+func (r *MemoryRepository) withStage(deal Deal) Deal {
+	deal.Items = append([]DealItem{}, deal.Items...)
+	pipeline := r.pipelines[deal.PipelineID]
+	stage, _ := pipeline.Stage(deal.StageID)
+	deal.Stage, deal.StageKind = stage.Name, stage.Kind
+	return deal
+}
+
+// clonePipeline возвращает копию воронки, не разделяющую стадии с хранилищем
+func clonePipeline(p Pipeline) Pipeline {
+	p.Stages = append([]Stage{}, p.Stages...)
+	return p
+}
+
+// ensureDefault создает компании воронку по умолчанию, если ее нет, и возвращает ее ID.
+// Вызывается под блокировкой на запись.
+func (r *MemoryRepository) ensureDefault(customerID int) int {
+	for _, p := range r.pipelines {
+		if p.CustomerID == customerID && p.IsDefault {
+			return p.ID
+		}
+	}
+	p := NewDefaultPipeline(customerID)
+	r.savePipeline(&p, time.Now().UTC().Format(time.RFC3339))
+	return p.ID
+}
+
+// savePipeline назначает ID воронке и новым стадиям и сохраняет воронку.
+// Новая воронка по умолчанию снимает этот признак с остальных. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) savePipeline(p *Pipeline, now string) {
+	if p.ID == 0 {
+		r.nextPipelineID++
+		p.ID = r.nextPipelineID
+		p.CreatedAt = now
+	}
+	p.UpdatedAt = now
+	for i := range p.Stages {
+		if p.Stages[i].ID == 0 {
+			r.nextStageID++
+			p.Stages[i].ID = r.nextStageID
+		}
+		p.Stages[i].PipelineID = p.ID
+	}
+	if p.IsDefault {
+		for id, other := range r.pipelines {
+			if other.CustomerID == p.CustomerID && other.IsDefault && id != p.ID {
+				other.IsDefault = false
+				r.pipelines[id] = other
+			}
+		}
+	}
+	r.pipelines[p.ID] = clonePipeline(*p)
+}
+
+func (r *MemoryRepository) ListPipelines(ctx context.Context, customerID int) ([]Pipeline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ensureDefault(customerID)
+	pipelines := []Pipeline{}
+	for _, p := range r.pipelines {
+		if p.CustomerID == customerID {
+			pipelines = append(pipelines, clonePipeline(p))
+		}
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		if pipelines[i].IsDefault != pipelines[j].IsDefault {
+			return pipelines[i].IsDefault
+		}
+		return pipelines[i].ID < pipelines[j].ID
+	})
+	return pipelines, nil
+}
+
+func (r *MemoryRepository) GetPipeline(ctx context.Context, customerID, id int) (*Pipeline, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.pipelines[id]
+	if !ok || p.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	p = clonePipeline(p)
+	return &p, nil
+}
+
+func (r *MemoryRepository) DefaultPipeline(ctx context.Context, customerID int) (*Pipeline, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := clonePipeline(r.pipelines[r.ensureDefault(customerID)])
+	return &p, nil
+}
+
+func (r *MemoryRepository) CreatePipeline(ctx context.Context, pipeline *Pipeline) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Как и в PostgreSQL, воронка по умолчанию создается раньше первой воронки компании
+	r.ensureDefault(pipeline.CustomerID)
+	pipeline.ID = 0
+	r.savePipeline(pipeline, time.Now().UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (r *MemoryRepository) UpdatePipeline(ctx context.Context, pipeline *Pipeline) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.pipelines[pipeline.ID]
+	if !ok || existing.CustomerID != pipeline.CustomerID {
+		return ErrNotFound
+	}
+	if existing.IsDefault && !pipeline.IsDefault {
+		return ErrDefaultPipeline
+	}
+
+	// Удалить можно только стадии без сделок
+	kept := make(map[int]bool)
+	for _, s := range pipeline.Stages {
+		kept[s.ID] = true
+	}
+	for _, deal := range r.deals {
+		if deal.PipelineID == pipeline.ID && !kept[deal.StageID] {
+			return ErrStageInUse
+		}
+	}
+
+	removed := make(map[int]bool)
+	for _, s := range existing.Stages {
+		removed[s.ID] = !kept[s.ID]
+	}
+	r.forgetStages(0, removed)
+
+	pipeline.CreatedAt = existing.CreatedAt
+	r.savePipeline(pipeline, time.Now().UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (r *MemoryRepository) DeletePipeline(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.pipelines[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	if existing.IsDefault {
+		return ErrDefaultPipeline
+	}
+	for _, deal := range r.deals {
+		if deal.PipelineID == id {
+			return ErrPipelineInUse
+		}
+	}
+	delete(r.pipelines, id)
+
+	removed := make(map[int]bool)
+	for _, s := range existing.Stages {
+		removed[s.ID] = true
+	}
+	r.forgetStages(id, removed)
+	return nil
+}
+
+// forgetStages убирает из истории сделок ссылки на удаленные воронку pipelineID
+// и стадии removed, как ON DELETE SET NULL в PostgreSQL. Вызывается под блокировкой на запись.
+func (r *MemoryRepository) forgetStages(pipelineID int, removed map[int]bool) {
+	for _, changes := range r.history {
+		for i := range changes {
+			if pipelineID != 0 && changes[i].PipelineID == pipelineID {
+				changes[i].PipelineID = 0
+			}
+			if removed[changes[i].FromStageID] {
+				changes[i].FromStageID = 0
+			}
+			if removed[changes[i].ToStageID] {
+				changes[i].ToStageID = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package crm
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/registry"
+)
+
+// Module подключает CRM к платформе
+type Module struct {
+	ctrl *Controller
+}
+
+// NewModule создает модуль CRM
+func NewModule(ctrl *Controller) *Module {
+	return &Module{ctrl: ctrl}
+}
+
+// Info возвращает описание модуля для каталога
+func (m *Module) Info() registry.Info {
+	return registry.Info{
+		Name:         "crm",
+		Title:        "CRM",
+		Description:  "Контакты, сделки, воронки продаж и статистика",
+		MonthlyPrice: 7,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты CRM
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	router.Get("/contacts", rbac.Require(rbac.ContactsRead), m.ctrl.GetContacts)
+	router.Post("/contacts", rbac.Require(rbac.ContactsWrite), m.ctrl.CreateContact)
+	router.Put("/contacts/:id", rbac.Require(rbac.ContactsWrite), m.ctrl.UpdateContact)
+	router.Delete("/contacts/:id", rbac.Require(rbac.ContactsWrite), m.ctrl.DeleteContact)
+	router.Get("/deals", rbac.Require(rbac.DealsRead), m.ctrl.GetDeals)
+	router.Post("/deals", rbac.Require(rbac.DealsWrite), m.ctrl.CreateDeal)
+	router.Put("/deals/:id", rbac.Require(rbac.DealsWrite), m.ctrl.UpdateDeal)
+	router.Delete("/deals/:id", rbac.Require(rbac.DealsWrite), m.ctrl.DeleteDeal)
+	router.Get("/deals/stats", rbac.Require(rbac.DealsRead), m.ctrl.GetDealStats)
+	router.Get("/deals/analytics", rbac.Require(rbac.DealsRead), m.ctrl.GetDealAnalytics)
+	router.Get("/deals/:id/history", rbac.Require(rbac.DealsRead), m.ctrl.GetDealHistory)
+	router.Post("/deals/:id/convert", rbac.Require(rbac.DealsWrite, rbac.OrdersWrite), m.ctrl.ConvertDeal)
+	router.Get("/pipelines", rbac.Require(rbac.DealsRead), m.ctrl.GetPipelines)
+	router.Get("/pipelines/:id", rbac.Require(rbac.DealsRead), m.ctrl.GetPipeline)
+	router.Post("/pipelines", rbac.Require(rbac.PipelinesManage), m.ctrl.CreatePipeline)
+	router.Put("/pipelines/:id", rbac.Require(rbac.PipelinesManage), m.ctrl.UpdatePipeline)
+	router.Delete("/pipelines/:id", rbac.Require(rbac.PipelinesManage), m.ctrl.DeletePipeline)
+	router.Get("/stats", rbac.Require(rbac.ContactsRead, rbac.DealsRead), m.ctrl.GetCRMStats)
+}
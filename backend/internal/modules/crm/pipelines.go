@@ -0,0 +1,145 @@
+package crm
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Виды стадий воронки
+const (
+	StageOpen = "open" // Сделка в работе
+	StageWon  = "won"  // Сделка выиграна
+	StageLost = "lost" // Сделка проиграна
+)
+
+var (
+	// ErrStageNotFound возвращается, если стадии сделки нет в указанной воронке компании
+	ErrStageNotFound = errors.New("crm: stage not found in pipeline")
+	// ErrStageInUse возвращается при удалении стадии, на которой есть сделки
+	ErrStageInUse = errors.New("crm: pipeline stage has deals")
+	// ErrPipelineInUse возвращается при удалении воронки, в которой есть сделки
+	ErrPipelineInUse = errors.New("crm: pipeline has deals")
+	// ErrDefaultPipeline возвращается при удалении воронки по умолчанию или снятии с нее этого признака
+	ErrDefaultPipeline = errors.New("crm: default pipeline is required")
+)
+
+// Stage - стадия воронки сделок
+type Stage struct {
+	ID         int    `json:"id"`
+	PipelineID int    `json:"pipeline_id"`
+	Name       string `json:"name"`
+	Position   int    `json:"position"` // Порядок стадии в воронке, начиная с 1
+	Kind       string `json:"kind"`     // open, won, lost
+}
+
+// Pipeline - воронка сделок компании с упорядоченными стадиями
+type Pipeline struct {
+	ID         int     `json:"id"`
+	CustomerID int     `json:"customer_id"` // ID компании
+	Name       string  `json:"name"`
+	IsDefault  bool    `json:"is_default"` // Воронка новых сделок без pipeline_id; у компании ровно одна
+	Stages     []Stage `json:"stages"`     // В порядке position
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+// PipelineRepository хранит воронки сделок со стадиями.
+// Все методы ограничены компанией customerID.
+type PipelineRepository interface {
+	// ListPipelines возвращает воронки компании, воронка по умолчанию - первой.
+	// Компании без воронок создается воронка NewDefaultPipeline.
+	ListPipelines(ctx context.Context, customerID int) ([]Pipeline, error)
+	GetPipeline(ctx context.Context, customerID, id int) (*Pipeline, error)
+	// DefaultPipeline возвращает воронку по умолчанию, создавая ее при первом обращении
+	DefaultPipeline(ctx context.Context, customerID int) (*Pipeline, error)
+	// CreatePipeline сохраняет воронку со стадиями; новая воронка по умолчанию
+	// снимает этот признак с прежней
+	CreatePipeline(ctx context.Context, pipeline *Pipeline) error
+	// UpdatePipeline сохраняет название и стадии воронки: стадии с ID переименовываются
+	// и переставляются, без ID - добавляются, отсутствующие в списке - удаляются.
+	// ErrStageInUse, если на удаляемой стадии есть сделки; ErrDefaultPipeline при снятии
+	// признака воронки по умолчанию.
+	UpdatePipeline(ctx context.Context, pipeline *Pipeline) error
+	// DeletePipeline удаляет воронку; ErrPipelineInUse, если в ней есть сделки,
+	// ErrDefaultPipeline для воронки по умолчанию
+	DeletePipeline(ctx context.Context, customerID, id int) error
+}
+
+// NewDefaultPipeline возвращает воронку, которая создается для компании при первом обращении
+// к воронкам: стадии совпадают с прежними фиксированными этапами сделок
+func NewDefaultPipeline(customerID int) Pipeline {
+	return Pipeline{
+		CustomerID: customerID,
+		Name:       "Продажи",
+		IsDefault:  true,
+		Stages: []Stage{
+			{Name: "new", Position: 1, Kind: StageOpen},
+			{Name: "in-progress", Position: 2, Kind: StageOpen},
+			{Name: "won", Position: 3, Kind: StageWon},
+			{Name: "lost", Position: 4, Kind: StageLost},
+		},
+	}
+}
+
+// Stage возвращает стадию воронки по ID
+func (p *Pipeline) Stage(id int) (Stage, bool) {
+	for _, s := range p.Stages {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Stage{}, false
+}
+
+// StageByName возвращает стадию воронки по названию без учета регистра
+func (p *Pipeline) StageByName(name string) (Stage, bool) {
+	for _, s := range p.Stages {
+		if strings.EqualFold(s.Name, strings.TrimSpace(name)) {
+			return s, true
+		}
+	}
+	return Stage{}, false
+}
+
+// arrange проверяет воронку перед сохранением и нумерует стадии в порядке списка.
+// Стадии с ID должны принадлежать воронке current (nil для новой воронки).
+// Возвращает причину, по которой воронку нельзя сохранить; пусто - воронка корректна.
+func (p *Pipeline) arrange(current *Pipeline) string {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return "Pipeline name is required"
+	}
+	if len(p.Stages) == 0 {
+		return "Pipeline must have at least one stage"
+	}
+
+	names := make(map[string]bool)
+	for i := range p.Stages {
+		s := &p.Stages[i]
+		s.Name = strings.TrimSpace(s.Name)
+		if s.Kind == "" {
+			s.Kind = StageOpen
+		}
+		switch {
+		case s.Name == "":
+			return "Stage name is required"
+		case names[strings.ToLower(s.Name)]:
+			return "Stage names must be unique within a pipeline"
+		case s.Kind != StageOpen && s.Kind != StageWon && s.Kind != StageLost:
+			return "Stage kind must be open, won or lost"
+		}
+		if s.ID != 0 {
+			if current == nil {
+				return "New pipeline stages cannot have IDs"
+			}
+			if _, ok := current.Stage(s.ID); !ok {
+				return "Stage does not belong to the pipeline"
+			}
+		}
+		names[strings.ToLower(s.Name)] = true
+		s.PipelineID = p.ID
+		s.Position = i + 1
+	}
+	return ""
+}
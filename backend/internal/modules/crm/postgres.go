@@ -0,0 +1,728 @@
+package crm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/database"
+)
+
+// PostgresRepository реализует ContactRepository, DealRepository и PipelineRepository поверх PostgreSQL
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий CRM
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+const contactColumns = `id, name, email, phone, company, customer_id, created_at, updated_at`
+
+func scanContact(row interface{ Scan(...interface{}) error }) (*Contact, error) {
+	var contact Contact
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&contact.ID, &contact.Name, &contact.Email, &contact.Phone, &contact.Company, &contact.CustomerID,
+		&createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	contact.CreatedAt = database.FormatTime(createdAt)
+	contact.UpdatedAt = database.FormatTime(updatedAt)
+	return &contact, nil
+}
+
+func (r *PostgresRepository) ListContacts(ctx context.Context, customerID int) ([]Contact, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+contactColumns+` FROM contacts WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []Contact{}
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, *contact)
+	}
+	return contacts, rows.Err()
+}
+
+func (r *PostgresRepository) GetContact(ctx context.Context, customerID, id int) (*Contact, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+contactColumns+` FROM contacts WHERE customer_id = $1 AND id = $2`, customerID, id)
+	return scanContact(row)
+}
+
+func (r *PostgresRepository) CreateContact(ctx context.Context, contact *Contact) error {
+	var createdAt, updatedAt time.Time
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO contacts (customer_id, name, email, phone, company)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`,
+		contact.CustomerID, contact.Name, contact.Email, contact.Phone, contact.Company,
+	).Scan(&contact.ID, &createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+	contact.CreatedAt = database.FormatTime(createdAt)
+	contact.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+func (r *PostgresRepository) UpdateContact(ctx context.Context, contact *Contact) error {
+	var createdAt, updatedAt time.Time
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE contacts SET name = $3, email = $4, phone = $5, company = $6, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2 RETURNING created_at, updated_at`,
+		contact.CustomerID, contact.ID, contact.Name, contact.Email, contact.Phone, contact.Company,
+	).Scan(&createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	contact.CreatedAt = database.FormatTime(createdAt)
+	contact.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+func (r *PostgresRepository) DeleteContact(ctx context.Context, customerID, id int) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM contacts WHERE customer_id = $1 AND id = $2`, customerID, id)
+	return checkAffected(res, err)
+}
+
+// dealColumns выбирает сделку вместе с названием и видом ее стадии из dealsFrom
+const (
+	dealColumns = `d.id, d.title, d.value, d.currency, COALESCE(d.contact_id, 0), d.pipeline_id, d.stage_id,
+		s.name, s.kind, COALESCE(d.order_id, 0), d.customer_id, d.created_at, d.updated_at`
+	dealsFrom = `deals d JOIN pipeline_stages s ON s.id = d.stage_id`
+)
+
+func scanDeal(row interface{ Scan(...interface{}) error }) (*Deal, error) {
+	var deal Deal
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&deal.ID, &deal.Title, &deal.Value, &deal.Currency, &deal.ContactID, &deal.PipelineID, &deal.StageID,
+		&deal.Stage, &deal.StageKind, &deal.OrderID, &deal.CustomerID, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	deal.Items = []DealItem{}
+	deal.CreatedAt = database.FormatTime(createdAt)
+	deal.UpdatedAt = database.FormatTime(updatedAt)
+	return &deal, nil
+}
+
+func (r *PostgresRepository) ListDeals(ctx context.Context, customerID int) ([]Deal, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+dealColumns+` FROM `+dealsFrom+` WHERE d.customer_id = $1 ORDER BY d.id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deals := []Deal{}
+	for rows.Next() {
+		deal, err := scanDeal(rows)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, *deal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Загружаем товары всех сделок компании одним запросом
+	err = loadItems(ctx, r.db, deals,
+		`deal_id IN (SELECT id FROM deals WHERE customer_id = $1)`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
+
+func (r *PostgresRepository) GetDeal(ctx context.Context, customerID, id int) (*Deal, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+dealColumns+` FROM `+dealsFrom+` WHERE d.customer_id = $1 AND d.id = $2`, customerID, id)
+	deal, err := scanDeal(row)
+	if err != nil {
+		return nil, err
+	}
+	deals := []Deal{*deal}
+	if err := loadItems(ctx, r.db, deals, `deal_id = $1`, id); err != nil {
+		return nil, err
+	}
+	return &deals[0], nil
+}
+
+// loadItems заполняет товары сделок deals, выбирая их из deal_items по условию where
+func loadItems(ctx context.Context, q database.Querier, deals []Deal, where string, args ...interface{}) error {
+	index := make(map[int]int, len(deals))
+	for i := range deals {
+		index[deals[i].ID] = i
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT deal_id, id, COALESCE(product_id, 0), product_name, quantity, price FROM deal_items
+		 WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dealID int
+		var item DealItem
+		if err := rows.Scan(&dealID, &item.ID, &item.ProductID, &item.ProductName, &item.Quantity, &item.Price); err != nil {
+			return err
+		}
+		if i, ok := index[dealID]; ok {
+			deals[i].Items = append(deals[i].Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// saveItems заменяет товары сделки списком deal.Items, назначая им ID
+func saveItems(ctx context.Context, q database.Querier, deal *Deal) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM deal_items WHERE deal_id = $1`, deal.ID); err != nil {
+		return err
+	}
+	for i := range deal.Items {
+		item := &deal.Items[i]
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO deal_items (deal_id, product_id, product_name, quantity, price)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			deal.ID, item.ProductID, item.ProductName, item.Quantity, item.Price,
+		).Scan(&item.ID)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == "deal_items_product_id_fkey" {
+			return ErrProductNotFound
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateDeal(ctx context.Context, deal *Deal, changedBy int) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		if err := checkStage(ctx, q, deal); err != nil {
+			return err
+		}
+		if err := checkContact(ctx, q, deal); err != nil {
+			return err
+		}
+		var createdAt, updatedAt time.Time
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO deals (customer_id, title, value, currency, contact_id, pipeline_id, stage_id)
+			 VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7) RETURNING id, created_at, updated_at`,
+			deal.CustomerID, deal.Title, deal.Value, deal.Currency, deal.ContactID, deal.PipelineID, deal.StageID,
+		).Scan(&deal.ID, &createdAt, &updatedAt)
+		if err != nil {
+			return stageError(err)
+		}
+		deal.OrderID = 0
+		deal.CreatedAt = database.FormatTime(createdAt)
+		deal.UpdatedAt = database.FormatTime(updatedAt)
+		if err := saveItems(ctx, q, deal); err != nil {
+			return err
+		}
+		return recordStage(ctx, q, 0, "", deal, changedBy)
+	})
+}
+
+func (r *PostgresRepository) UpdateDeal(ctx context.Context, deal *Deal, changedBy int) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		if err := checkStage(ctx, q, deal); err != nil {
+			return err
+		}
+		if err := checkContact(ctx, q, deal); err != nil {
+			return err
+		}
+
+		// Сделка блокируется до конца транзакции, чтобы переход записался один раз
+		var fromStageID int
+		var fromStage string
+		err := q.QueryRowContext(ctx,
+			`SELECT d.stage_id, s.name FROM `+dealsFrom+` WHERE d.customer_id = $1 AND d.id = $2 FOR UPDATE OF d`,
+			deal.CustomerID, deal.ID).Scan(&fromStageID, &fromStage)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var createdAt, updatedAt time.Time
+		err = q.QueryRowContext(ctx,
+			`UPDATE deals SET title = $3, value = $4, currency = COALESCE(NULLIF($7, ''), currency),
+			                  contact_id = NULLIF($5, 0), pipeline_id = $6, stage_id = $8, updated_at = now()
+			 WHERE customer_id = $1 AND id = $2 RETURNING currency, COALESCE(order_id, 0), created_at, updated_at`,
+			deal.CustomerID, deal.ID, deal.Title, deal.Value, deal.ContactID, deal.PipelineID, deal.Currency, deal.StageID,
+		).Scan(&deal.Currency, &deal.OrderID, &createdAt, &updatedAt)
+		if err != nil {
+			return stageError(err)
+		}
+		deal.CreatedAt = database.FormatTime(createdAt)
+		deal.UpdatedAt = database.FormatTime(updatedAt)
+
+		// Без списка товаров сделка сохраняет прежние
+		if deal.Items != nil {
+			err = saveItems(ctx, q, deal)
+		} else {
+			deals := []Deal{*deal}
+			deals[0].Items = []DealItem{}
+			err = loadItems(ctx, q, deals, `deal_id = $1`, deal.ID)
+			deal.Items = deals[0].Items
+		}
+		if err != nil {
+			return err
+		}
+		if fromStageID == deal.StageID {
+			return nil
+		}
+		return recordStage(ctx, q, fromStageID, fromStage, deal, changedBy)
+	})
+}
+
+// recordStage записывает в историю переход сделки deal со стадии fromStageID
+// (0 при создании) на ее текущую стадию
+func recordStage(ctx context.Context, q database.Querier, fromStageID int, fromStage string, deal *Deal, changedBy int) error {
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO deal_stage_history
+		     (deal_id, pipeline_id, from_stage_id, from_stage, to_stage_id, to_stage, to_kind, changed_by, changed_at)
+		 VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, NULLIF($8, 0), $9)`,
+		deal.ID, deal.PipelineID, fromStageID, fromStage, deal.StageID, deal.Stage, deal.StageKind, changedBy, deal.UpdatedAt)
+	return err
+}
+
+const stageChangeColumns = `id, deal_id, COALESCE(pipeline_id, 0), COALESCE(from_stage_id, 0), from_stage,
+	COALESCE(to_stage_id, 0), to_stage, to_kind, COALESCE(changed_by, 0), changed_at`
+
+// queryStageChanges выполняет запрос истории стадий со столбцами stageChangeColumns
+func (r *PostgresRepository) queryStageChanges(ctx context.Context, query string, args ...interface{}) ([]StageChange, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []StageChange{}
+	for rows.Next() {
+		var ch StageChange
+		var changedAt time.Time
+		if err := rows.Scan(&ch.ID, &ch.DealID, &ch.PipelineID, &ch.FromStageID, &ch.FromStage,
+			&ch.ToStageID, &ch.ToStage, &ch.ToKind, &ch.ChangedBy, &changedAt); err != nil {
+			return nil, err
+		}
+		ch.ChangedAt = database.FormatTime(changedAt)
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+func (r *PostgresRepository) StageHistory(ctx context.Context, customerID, id int) ([]StageChange, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM deals WHERE customer_id = $1 AND id = $2)`, customerID, id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return r.queryStageChanges(ctx,
+		`SELECT `+stageChangeColumns+` FROM deal_stage_history WHERE deal_id = $1 ORDER BY changed_at, id`, id)
+}
+
+func (r *PostgresRepository) StageChanges(ctx context.Context, customerID int) ([]StageChange, error) {
+	return r.queryStageChanges(ctx,
+		`SELECT `+stageChangeColumns+` FROM deal_stage_history
+		 WHERE deal_id IN (SELECT id FROM deals WHERE customer_id = $1)
+		 ORDER BY deal_id, changed_at, id`, customerID)
+}
+
+func (r *PostgresRepository) DeleteDeal(ctx context.Context, customerID, id int) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM deals WHERE customer_id = $1 AND id = $2`, customerID, id)
+	return checkAffected(res, err)
+}
+
+func (r *PostgresRepository) ConvertDeal(ctx context.Context, customerID, id int, create func(ctx context.Context, deal *Deal) (int, error)) (*Deal, error) {
+	var deal *Deal
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Сделка блокируется до конца транзакции: параллельная конвертация дождется ее
+		// и увидит созданный заказ
+		var err error
+		deal, err = scanDeal(q.QueryRowContext(ctx,
+			`SELECT `+dealColumns+` FROM `+dealsFrom+` WHERE d.customer_id = $1 AND d.id = $2 FOR UPDATE OF d`,
+			customerID, id))
+		if err != nil {
+			return err
+		}
+		if deal.OrderID != 0 {
+			return ErrDealConverted
+		}
+		if deal.StageKind != StageWon {
+			return ErrDealNotWon
+		}
+		deals := []Deal{*deal}
+		if err := loadItems(ctx, q, deals, `deal_id = $1`, id); err != nil {
+			return err
+		}
+		*deal = deals[0]
+
+		orderID, err := create(ctx, deal)
+		if err != nil {
+			return err
+		}
+		var updatedAt time.Time
+		err = q.QueryRowContext(ctx,
+			`UPDATE deals SET order_id = $3, updated_at = now() WHERE customer_id = $1 AND id = $2 RETURNING updated_at`,
+			customerID, id, orderID).Scan(&updatedAt)
+		if err != nil {
+			return err
+		}
+		deal.OrderID = orderID
+		deal.UpdatedAt = database.FormatTime(updatedAt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deal, nil
+}
+
+// checkStage проверяет, что стадия сделки есть в ее воронке, и заполняет название и вид стадии
+func checkStage(ctx context.Context, q database.Querier, deal *Deal) error {
+	err := q.QueryRowContext(ctx,
+		`SELECT s.name, s.kind FROM pipeline_stages s JOIN pipelines p ON p.id = s.pipeline_id
+		 WHERE p.customer_id = $1 AND p.id = $2 AND s.id = $3`,
+		deal.CustomerID, deal.PipelineID, deal.StageID).Scan(&deal.Stage, &deal.StageKind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrStageNotFound
+	}
+	return err
+}
+
+// checkContact проверяет, что контакт сделки принадлежит ее компании: внешний ключ
+// на contacts не ограничен компанией
+func checkContact(ctx context.Context, q database.Querier, deal *Deal) error {
+	if deal.ContactID == 0 {
+		return nil
+	}
+	var exists bool
+	err := q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM contacts WHERE customer_id = $1 AND id = $2)`,
+		deal.CustomerID, deal.ContactID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// stageError превращает нарушение внешнего ключа на воронку или стадию, удаленную
+// одновременно с сохранением сделки, в ErrStageNotFound
+func stageError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" &&
+		(pqErr.Constraint == "deals_stage_fkey" || pqErr.Constraint == "deals_pipeline_id_fkey") {
+		return ErrStageNotFound
+	}
+	return err
+}
+
+const pipelineColumns = `id, customer_id, name, is_default, created_at, updated_at`
+
+func scanPipeline(row interface{ Scan(...interface{}) error }) (*Pipeline, error) {
+	var p Pipeline
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&p.ID, &p.CustomerID, &p.Name, &p.IsDefault, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Stages = []Stage{}
+	p.CreatedAt = database.FormatTime(createdAt)
+	p.UpdatedAt = database.FormatTime(updatedAt)
+	return &p, nil
+}
+
+// loadStages заполняет стадии воронок pipelines компании customerID
+func loadStages(ctx context.Context, q database.Querier, customerID int, pipelines []Pipeline) error {
+	index := make(map[int]int, len(pipelines))
+	for i := range pipelines {
+		index[pipelines[i].ID] = i
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, pipeline_id, name, position, kind FROM pipeline_stages
+		 WHERE pipeline_id IN (SELECT id FROM pipelines WHERE customer_id = $1)
+		 ORDER BY pipeline_id, position`, customerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Stage
+		if err := rows.Scan(&s.ID, &s.PipelineID, &s.Name, &s.Position, &s.Kind); err != nil {
+			return err
+		}
+		if i, ok := index[s.PipelineID]; ok {
+			pipelines[i].Stages = append(pipelines[i].Stages, s)
+		}
+	}
+	return rows.Err()
+}
+
+func (r *PostgresRepository) ListPipelines(ctx context.Context, customerID int) ([]Pipeline, error) {
+	if _, err := r.DefaultPipeline(ctx, customerID); err != nil {
+		return nil, err
+	}
+	q := database.Conn(ctx, r.db)
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+pipelineColumns+` FROM pipelines WHERE customer_id = $1 ORDER BY is_default DESC, id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pipelines := []Pipeline{}
+	for rows.Next() {
+		p, err := scanPipeline(rows)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadStages(ctx, q, customerID, pipelines); err != nil {
+		return nil, err
+	}
+	return pipelines, nil
+}
+
+func (r *PostgresRepository) GetPipeline(ctx context.Context, customerID, id int) (*Pipeline, error) {
+	q := database.Conn(ctx, r.db)
+	p, err := scanPipeline(q.QueryRowContext(ctx,
+		`SELECT `+pipelineColumns+` FROM pipelines WHERE customer_id = $1 AND id = $2`, customerID, id))
+	if err != nil {
+		return nil, err
+	}
+	pipelines := []Pipeline{*p}
+	if err := loadStages(ctx, q, customerID, pipelines); err != nil {
+		return nil, err
+	}
+	return &pipelines[0], nil
+}
+
+func (r *PostgresRepository) DefaultPipeline(ctx context.Context, customerID int) (*Pipeline, error) {
+	var id int
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id FROM pipelines WHERE customer_id = $1 AND is_default`, customerID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		p := NewDefaultPipeline(customerID)
+		err = r.insertPipeline(ctx, &p)
+		// Воронку по умолчанию одновременно создал другой запрос
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return r.DefaultPipeline(ctx, customerID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetPipeline(ctx, customerID, id)
+}
+
+func (r *PostgresRepository) CreatePipeline(ctx context.Context, pipeline *Pipeline) error {
+	// Воронка по умолчанию создается раньше первой воронки компании
+	if _, err := r.DefaultPipeline(ctx, pipeline.CustomerID); err != nil {
+		return err
+	}
+	return r.insertPipeline(ctx, pipeline)
+}
+
+// insertPipeline сохраняет новую воронку со стадиями в одной транзакции
+func (r *PostgresRepository) insertPipeline(ctx context.Context, pipeline *Pipeline) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		if pipeline.IsDefault {
+			if _, err := q.ExecContext(ctx,
+				`UPDATE pipelines SET is_default = false, updated_at = now() WHERE customer_id = $1 AND is_default`,
+				pipeline.CustomerID); err != nil {
+				return err
+			}
+		}
+		var createdAt, updatedAt time.Time
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO pipelines (customer_id, name, is_default) VALUES ($1, $2, $3)
+			 RETURNING id, created_at, updated_at`,
+			pipeline.CustomerID, pipeline.Name, pipeline.IsDefault,
+		).Scan(&pipeline.ID, &createdAt, &updatedAt)
+		if err != nil {
+			return err
+		}
+		pipeline.CreatedAt = database.FormatTime(createdAt)
+		pipeline.UpdatedAt = database.FormatTime(updatedAt)
+		for i := range pipeline.Stages {
+			pipeline.Stages[i].ID = 0
+			if err := saveStage(ctx, q, pipeline.ID, &pipeline.Stages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveStage обновляет стадию воронки или добавляет новую, если у нее нет ID
+func saveStage(ctx context.Context, q database.Querier, pipelineID int, s *Stage) error {
+	s.PipelineID = pipelineID
+	if s.ID == 0 {
+		return q.QueryRowContext(ctx,
+			`INSERT INTO pipeline_stages (pipeline_id, name, position, kind) VALUES ($1, $2, $3, $4) RETURNING id`,
+			pipelineID, s.Name, s.Position, s.Kind).Scan(&s.ID)
+	}
+	res, err := q.ExecContext(ctx,
+		`UPDATE pipeline_stages SET name = $3, position = $4, kind = $5 WHERE pipeline_id = $1 AND id = $2`,
+		pipelineID, s.ID, s.Name, s.Position, s.Kind)
+	return checkAffected(res, err)
+}
+
+func (r *PostgresRepository) UpdatePipeline(ctx context.Context, pipeline *Pipeline) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Воронка блокируется до конца транзакции
+		var wasDefault bool
+		err := q.QueryRowContext(ctx,
+			`SELECT is_default FROM pipelines WHERE customer_id = $1 AND id = $2 FOR UPDATE`,
+			pipeline.CustomerID, pipeline.ID).Scan(&wasDefault)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if wasDefault && !pipeline.IsDefault {
+			return ErrDefaultPipeline
+		}
+		if pipeline.IsDefault && !wasDefault {
+			if _, err := q.ExecContext(ctx,
+				`UPDATE pipelines SET is_default = false, updated_at = now() WHERE customer_id = $1 AND is_default`,
+				pipeline.CustomerID); err != nil {
+				return err
+			}
+		}
+
+		var createdAt, updatedAt time.Time
+		err = q.QueryRowContext(ctx,
+			`UPDATE pipelines SET name = $3, is_default = $4, updated_at = now()
+			 WHERE customer_id = $1 AND id = $2 RETURNING created_at, updated_at`,
+			pipeline.CustomerID, pipeline.ID, pipeline.Name, pipeline.IsDefault,
+		).Scan(&createdAt, &updatedAt)
+		if err != nil {
+			return err
+		}
+		pipeline.CreatedAt = database.FormatTime(createdAt)
+		pipeline.UpdatedAt = database.FormatTime(updatedAt)
+
+		// Сначала удаляются стадии, которых нет в списке: сделки на них не дают удалить стадию
+		kept := []int{}
+		for _, s := range pipeline.Stages {
+			if s.ID != 0 {
+				kept = append(kept, s.ID)
+			}
+		}
+		_, err = q.ExecContext(ctx,
+			`DELETE FROM pipeline_stages WHERE pipeline_id = $1 AND NOT (id = ANY($2))`,
+			pipeline.ID, pq.Array(kept))
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrStageInUse
+		}
+		if err != nil {
+			return err
+		}
+		for i := range pipeline.Stages {
+			if err := saveStage(ctx, q, pipeline.ID, &pipeline.Stages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PostgresRepository) DeletePipeline(ctx context.Context, customerID, id int) error {
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		var isDefault bool
+		err := q.QueryRowContext(ctx,
+			`SELECT is_default FROM pipelines WHERE customer_id = $1 AND id = $2 FOR UPDATE`,
+			customerID, id).Scan(&isDefault)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if isDefault {
+			return ErrDefaultPipeline
+		}
+
+		// Стадии удаляются каскадом; сделки воронки не дают удалить ни ее, ни ее стадии
+		_, err = q.ExecContext(ctx, `DELETE FROM pipelines WHERE customer_id = $1 AND id = $2`, customerID, id)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrPipelineInUse
+		}
+		return err
+	})
+}
+
+// checkAffected превращает изменение нуля строк в ErrNotFound
+func checkAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
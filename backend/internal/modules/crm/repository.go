@@ -0,0 +1,55 @@
+package crm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound возвращается, если запись не найдена или принадлежит другой компании
+var ErrNotFound = errors.New("crm: record not found")
+
+// ErrDealConverted возвращается при повторной конвертации сделки в заказ
+var ErrDealConverted = errors.New("crm: deal already converted to an order")
+
+// ErrDealNotWon возвращается при конвертации в заказ сделки, которая не на выигрышной стадии
+var ErrDealNotWon = errors.New("crm: deal is not won")
+
+// ErrContactNotFound возвращается, если контакта сделки нет среди контактов ее компании
+var ErrContactNotFound = errors.New("crm: deal contact not found")
+
+// ErrProductNotFound возвращается, если товара сделки нет на Складе
+var ErrProductNotFound = errors.New("crm: deal product not found")
+
+// ContactRepository хранит контакты. Все методы ограничены компанией customerID.
+type ContactRepository interface {
+	ListContacts(ctx context.Context, customerID int) ([]Contact, error)
+	GetContact(ctx context.Context, customerID, id int) (*Contact, error)
+	CreateContact(ctx context.Context, contact *Contact) error
+	UpdateContact(ctx context.Context, contact *Contact) error
+	DeleteContact(ctx context.Context, customerID, id int) error
+}
+
+// DealRepository хранит сделки и историю их стадий. Все методы ограничены компанией customerID.
+// Название и вид стадии сделки хранилище берет из ее воронки.
+type DealRepository interface {
+	ListDeals(ctx context.Context, customerID int) ([]Deal, error)
+	GetDeal(ctx context.Context, customerID, id int) (*Deal, error)
+	// CreateDeal сохраняет сделку с товарами и первую запись истории стадий от имени
+	// пользователя changedBy; ErrStageNotFound, если стадии нет в воронке компании
+	CreateDeal(ctx context.Context, deal *Deal, changedBy int) error
+	// UpdateDeal обновляет сделку и, если стадия изменилась, атомарно записывает переход
+	// в историю; ErrStageNotFound, если стадии нет в воронке компании. Товары сделки
+	// заменяются списком deal.Items, а при nil не меняются. Заказ сделки не меняется.
+	UpdateDeal(ctx context.Context, deal *Deal, changedBy int) error
+	DeleteDeal(ctx context.Context, customerID, id int) error
+	// ConvertDeal блокирует сделку, создает по ней заказ функцией create и сохраняет в сделке
+	// ID заказа; ErrDealConverted, если заказ по сделке уже создан, и ErrDealNotWon, если
+	// сделка не на выигрышной стадии. Ошибка create отменяет
+	// конвертацию. Реализация на PostgreSQL передает create контекст со своей транзакцией
+	// (database.WithTx), чтобы заказ фиксировался вместе со ссылкой на него.
+	ConvertDeal(ctx context.Context, customerID, id int, create func(ctx context.Context, deal *Deal) (int, error)) (*Deal, error)
+	// StageHistory возвращает переходы сделки по стадиям в хронологическом порядке
+	StageHistory(ctx context.Context, customerID, id int) ([]StageChange, error)
+	// StageChanges возвращает переходы всех сделок компании, упорядоченные по сделке и времени
+	StageChanges(ctx context.Context, customerID int) ([]StageChange, error)
+}
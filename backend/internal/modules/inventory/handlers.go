@@ -1,10 +1,20 @@
 package inventory
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/report"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
 )
 
 // Product представляет товар на складе
@@ -12,8 +22,11 @@ type Product struct {
 	ID          int     `json:"id"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Price       money.Money `json:"price"`
+	Currency    string  `json:"currency"`    // Валюта цены; по умолчанию - базовая валюта компании
+	TaxCategory string  `json:"tax_category"` // vat20, vat10, vat0, none; по умолчанию - категория компании
 	Quantity    int     `json:"quantity"`
+	Reserved    int     `json:"reserved"`    // Зарезервировано под подтвержденные заказы
 	SKU         string  `json:"sku"`         // Артикул
 	Category    string `json:"category"`
 	ImageURL    string  `json:"image_url"`
@@ -22,59 +35,98 @@ type Product struct {
 	UpdatedAt   string  `json:"updated_at"`
 }
 
-// InventoryStats представляет статистику по складу
+// lowStockLevel - доступный остаток, при котором товар считается заканчивающимся
+const lowStockLevel = 5
+
+// InventoryStats представляет статистику по складу: текущие остатки и товары, добавленные за период
 type InventoryStats struct {
+	report.Period
 	TotalProducts   int     `json:"total_products"`
-	TotalValue      float64 `json:"total_value"`
+	TotalValue      money.Money `json:"total_value"`       // Стоимость остатков в базовой валюте компании по текущему курсу
 	LowStockCount   int     `json:"low_stock_count"`   // Товары с низким остатком
 	OutOfStockCount int     `json:"out_of_stock_count"` // Товары отсутствующие на складе
+	NewProducts     int     `json:"new_products"`       // Товары, добавленные за период
+	Currency        string  `json:"currency"`           // Базовая валюта компании
+	Series          []InventoryStatsPoint `json:"series"`
+}
+
+// InventoryStatsPoint - товары, добавленные за период группировки
+type InventoryStatsPoint struct {
+	Period      string `json:"period"` // Начало периода, YYYY-MM-DD
+	NewProducts int    `json:"new_products"`
 }
 
 // Контроллер Склада
 type Controller struct {
-	// Здесь будут зависимости, например, сервисы и репозитории
-	// Для упрощения в этом примере будем использовать заглушку
+	products   ProductRepository
+	currencies currency.Currencies
+	taxes      tax.Rules
 }
 
 // NewController создает новый контроллер Склада
-func NewController() *Controller {
-	return &Controller{}
+func NewController(products ProductRepository, currencies currency.Currencies, taxes tax.Rules) *Controller {
+	return &Controller{products: products, currencies: currencies, taxes: taxes}
 }
 
 // GetProducts возвращает список товаров
 func (ctrl *Controller) GetProducts(c *fiber.Ctx) error {
-	// Получаем ID компании из контекста (предполагается, что он был установлен в middleware)
-	customerID := c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// и фильтрация по customerID
-	products := []Product{
-		{ID: 1, Name: "Ноутбук", Description: "Ультрабук", Price: 50000.0, Quantity: 10, SKU: "NB-01", Category: "Электроника", ImageURL: "", CustomerID: customerID, CreatedAt: "2023-01-01T00:00:00Z", UpdatedAt: "2023-01-01T00:00:00Z"},
-		{ID: 2, Name: "Мышь", Description: "Беспроводная мышь", Price: 1500.0, Quantity: 50, SKU: "MS-001", Category: "Аксессуары", ImageURL: "", CustomerID: customerID, CreatedAt: "2023-01-02T00:00:00Z", UpdatedAt: "2023-01-02T00:00:00Z"},
-		{ID: 3, Name: "Клавиатура", Description: "Механическая клавиатура", Price: 4500.0, Quantity: 0, SKU: "KB-001", Category: "Аксессуары", ImageURL: "", CustomerID: customerID, CreatedAt: "2023-01-03T00:00:00Z", UpdatedAt: "2023-01-03T00:00:00Z"},
-	}
-	
+	// Получаем ID компании из контекста (устанавливается в tenant middleware)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	products, err := ctrl.products.ListProducts(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(products)
 }
 
 // CreateProduct создает новый товар
 func (ctrl *Controller) CreateProduct(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Парсим тело запроса
 	var product Product
 	if err := c.BodyParser(&product); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// Устанавливаем ID компании для нового товара
+
+	// Начальный остаток может задать только роль с правом на движение товара
+	if product.Quantity != 0 && !rbac.Can(c, rbac.StockWrite) {
+		return rbac.ErrForbidden
+	}
+
+	// Устанавливаем ID компании для нового товара; резерв появляется только при подтверждении заказов
 	product.CustomerID = customerID
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для сохранения товара в базе данных
-	// product.ID = generateNextID() // генерация нового ID
-	
+	product.Reserved = 0
+	product.Currency, err = currency.Resolve(c.UserContext(), ctrl.currencies, customerID, product.Currency)
+	if errors.Is(err, currency.ErrInvalidCurrency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
+	if err != nil {
+		return err
+	}
+	settings, err := ctrl.taxes.Settings(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	product.TaxCategory, err = settings.Category(product.TaxCategory)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tax category"})
+	}
+
+	// Сохраняем товар, ID и даты назначаются хранилищем
+	if err := ctrl.products.CreateProduct(c.UserContext(), &product); err != nil {
+		return err
+	}
+
 	// Возвращаем созданный товар
 	return c.JSON(product)
 }
@@ -82,45 +134,96 @@ func (ctrl *Controller) CreateProduct(c *fiber.Ctx) error {
 // UpdateProduct обновляет существующий товар
 func (ctrl *Controller) UpdateProduct(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID товара из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID"})
 	}
-	
+
 	// Парсим тело запроса
 	var updatedProduct Product
 	if err := c.BodyParser(&updatedProduct); err != nil {
-	return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обновления товара в базе данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
-	// Возвращаем обновленный товар
+
+	// Чужие товары для хранилища не существуют
+	current, err := ctrl.products.GetProduct(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Остаток меняет только роль с правом на движение товара
+	if updatedProduct.Quantity != current.Quantity && !rbac.Can(c, rbac.StockWrite) {
+		return rbac.ErrForbidden
+	}
+
+	// Без валюты цена остается в прежней валюте
+	if updatedProduct.Currency == "" {
+		updatedProduct.Currency = current.Currency
+	}
+	if !currency.Valid(updatedProduct.Currency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
+	if updatedProduct.TaxCategory == "" {
+		updatedProduct.TaxCategory = current.TaxCategory
+	}
+	if !tax.Valid(updatedProduct.TaxCategory) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tax category"})
+	}
+
+	// Обновляем товар
 	updatedProduct.ID = id
 	updatedProduct.CustomerID = customerID
+	err = ctrl.products.UpdateProduct(c.UserContext(), &updatedProduct)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	// Зарезервированный под заказы товар должен оставаться на складе
+	if errors.Is(err, ErrBelowReserved) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Quantity cannot be less than reserved stock"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Возвращаем обновленный товар
 	return c.JSON(updatedProduct)
 }
 
-// DeleteProduct удаляет товар
+// DeleteProduct удаляет товар, не зарезервированный под заказы
 func (ctrl *Controller) DeleteProduct(c *fiber.Ctx) error {
 	// Получаем ID товара из параметров URL
-	_, err := strconv.Atoi(c.Params("id"))
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID"})
 	}
 
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для удаления товара из базы данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	err = ctrl.products.DeleteProduct(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	// Зарезервированный товар удаляется после отмены или отгрузки заказов
+	if errors.Is(err, ErrReserved) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Product is reserved by orders"})
+	}
+	if err != nil {
+		return err
+	}
+
 	// Возвращаем успешный ответ
 	return c.SendStatus(http.StatusOK)
 }
@@ -128,40 +231,106 @@ func (ctrl *Controller) DeleteProduct(c *fiber.Ctx) error {
 // GetProduct возвращает информацию о конкретном товаре
 func (ctrl *Controller) GetProduct(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID товара из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID"})
 	}
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения товара из базы данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
-	product := Product{
-		ID: id, Name: "Пример товара", Description: "Описание товара", Price: 1000.0, 
-	Quantity: 5, SKU: "EX-001", Category: "Категория", ImageURL: "", 
-	CustomerID: customerID, CreatedAt: "2023-01-01T00:00:00Z", UpdatedAt: "2023-01-01T00:00:00Z",
-	}
-	
+
+	product, err := ctrl.products.GetProduct(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(product)
 }
 
-// GetInventoryStats возвращает статистику по складу
+// GetInventoryStats возвращает статистику по складу: текущие остатки и товары,
+// добавленные за период from - to, с рядом по группам group
 func (ctrl *Controller) GetInventoryStats(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения статистики из базы данных с фильтрацией по customerID
-	stats := InventoryStats{
-		TotalProducts:   100,
-		TotalValue:      1500000.0,
-		LowStockCount:   15,
-		OutOfStockCount: 5,
-	}
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	stats, err := ctrl.inventoryStats(c.UserContext(), customerID, period)
+	var noRate *currency.NoRateError
+	if errors.As(err, &noRate) {
+		return currency.NoRate(c, noRate)
+	}
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(stats)
-}
\ No newline at end of file
+}
+
+// inventoryStats подводит итоги по товарам компании. Остатки считаются по доступному
+// количеству (без резерва), стоимость - по полному количеству на складе.
+func (ctrl *Controller) inventoryStats(ctx context.Context, customerID int, period report.Range) (*InventoryStats, error) {
+	products, err := ctrl.products.ListProducts(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := ctrl.currencies.Converter(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stats := &InventoryStats{Period: period.Period(), TotalProducts: len(products), Currency: conv.Base()}
+	points := make(map[string]*InventoryStatsPoint)
+	var bounds report.Bounds
+	for _, product := range products {
+		value, err := conv.ToBase(product.Price.In(product.Currency).Mul(int64(product.Quantity)), now)
+		if err != nil {
+			return nil, err
+		}
+		stats.TotalValue = stats.TotalValue.Add(value)
+
+		switch available := product.Quantity - product.Reserved; {
+		case available <= 0:
+			stats.OutOfStockCount++
+		case available <= lowStockLevel:
+			stats.LowStockCount++
+		}
+
+		created := currency.At(product.CreatedAt)
+		if !period.Contains(created) {
+			continue
+		}
+		label := period.Label(created)
+		point, ok := points[label]
+		if !ok {
+			point = &InventoryStatsPoint{Period: label}
+			points[label] = point
+		}
+		bounds.Add(created)
+		stats.NewProducts++
+		point.NewProducts++
+	}
+
+	stats.Series = []InventoryStatsPoint{}
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		point := InventoryStatsPoint{Period: label}
+		if found, ok := points[label]; ok {
+			point = *found
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	return stats, nil
+}
@@ -0,0 +1,173 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/stock"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
+)
+
+// testApp собирает маршруты Склада на хранилищах в памяти. Компания запроса
+// берется из заголовка X-Customer-ID, вызывающий - владелец компании.
+func testApp() (*fiber.App, *MemoryRepository) {
+	repo := NewMemoryRepository()
+	ctrl := NewController(repo, currency.NewService(currency.NewMemoryStore()),
+		tax.NewService(tax.NewMemoryStore()))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		customerID, err := strconv.Atoi(c.Get("X-Customer-ID"))
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+		tenant.SetCustomerID(c, customerID)
+		rbac.SetRole(c, rbac.RoleOwner)
+		return c.Next()
+	})
+	NewModule(ctrl).RegisterRoutes(app)
+	return app, repo
+}
+
+// call выполняет запрос от имени компании customerID и разбирает JSON ответа в out, если он не nil
+func call(t *testing.T, app *fiber.App, customerID int, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Customer-ID", strconv.Itoa(customerID))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestProductCRUD(t *testing.T) {
+	app, _ := testApp()
+
+	var created Product
+	if code := call(t, app, 1, http.MethodPost, "/products", fiber.Map{"name": "Widget", "price": 10, "quantity": 5}, &created); code != http.StatusOK {
+		t.Fatalf("create: status %d", code)
+	}
+	if created.ID == 0 || created.CustomerID != 1 || created.Quantity != 5 {
+		t.Fatalf("create: unexpected product %+v", created)
+	}
+	path := "/products/" + strconv.Itoa(created.ID)
+
+	var got Product
+	if code := call(t, app, 1, http.MethodGet, path, nil, &got); code != http.StatusOK || got.Name != "Widget" {
+		t.Fatalf("get: status %d, product %+v", code, got)
+	}
+
+	var updated Product
+	if code := call(t, app, 1, http.MethodPut, path, fiber.Map{"name": "Gadget", "price": 12, "quantity": 7}, &updated); code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	if updated.Name != "Gadget" || updated.Quantity != 7 || updated.CreatedAt != created.CreatedAt {
+		t.Fatalf("update: unexpected product %+v", updated)
+	}
+
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code := call(t, app, 1, http.MethodGet, path, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("get after delete: status %d, want 404", code)
+	}
+}
+
+func TestProductTenantIsolation(t *testing.T) {
+	app, _ := testApp()
+
+	var own Product
+	call(t, app, 1, http.MethodPost, "/products", fiber.Map{"name": "Widget", "price": 10, "quantity": 5}, &own)
+	path := "/products/" + strconv.Itoa(own.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		body   interface{}
+	}{
+		{"get", http.MethodGet, nil},
+		{"update", http.MethodPut, fiber.Map{"name": "Stolen", "price": 1, "quantity": 5}},
+		{"delete", http.MethodDelete, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, app, 2, tt.method, path, tt.body, nil); code != http.StatusNotFound {
+				t.Fatalf("status %d, want 404", code)
+			}
+		})
+	}
+
+	var others []Product
+	call(t, app, 2, http.MethodGet, "/products", nil, &others)
+	if len(others) != 0 {
+		t.Fatalf("other company sees %d products", len(others))
+	}
+
+	var got Product
+	if call(t, app, 1, http.MethodGet, path, nil, &got); got.Name != "Widget" {
+		t.Fatalf("product changed by other company: %+v", got)
+	}
+}
+
+func TestReservedProduct(t *testing.T) {
+	app, repo := testApp()
+
+	var product Product
+	call(t, app, 1, http.MethodPost, "/products", fiber.Map{"name": "Widget", "price": 10, "quantity": 5}, &product)
+	path := "/products/" + strconv.Itoa(product.ID)
+	lines := []stock.Line{{ProductID: product.ID, Quantity: 3}}
+	if err := repo.Reserve(context.Background(), 1, lines); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := call(t, app, 1, http.MethodPut, path, fiber.Map{"name": "Widget", "price": 10, "quantity": 2}, nil); code != http.StatusConflict {
+		t.Fatalf("update below reserved: status %d, want 409", code)
+	}
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusConflict {
+		t.Fatalf("delete reserved: status %d, want 409", code)
+	}
+
+	if err := repo.Release(context.Background(), 1, lines); err != nil {
+		t.Fatal(err)
+	}
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete after release: status %d", code)
+	}
+}
+
+func TestProductIDsSharedAcrossCompanies(t *testing.T) {
+	app, _ := testApp()
+
+	var first, second Product
+	call(t, app, 1, http.MethodPost, "/products", fiber.Map{"name": "A", "price": 1}, &first)
+	call(t, app, 2, http.MethodPost, "/products", fiber.Map{"name": "B", "price": 1}, &second)
+	if first.ID == 0 || second.ID != first.ID+1 {
+		t.Fatalf("IDs %d and %d are not assigned from one sequence", first.ID, second.ID)
+	}
+}
@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kit8-backend/internal/core/stock"
+)
+
+// MemoryRepository - потокобезопасная реализация ProductRepository в памяти процесса.
+// Как и в PostgreSQL, ID назначаются общей последовательностью для всех компаний,
+// а товары других компаний не видны.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	products map[int]Product
+	nextID   int
+}
+
+// NewMemoryRepository создает пустой репозиторий склада в памяти
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{products: make(map[int]Product)}
+}
+
+func (r *MemoryRepository) ListProducts(ctx context.Context, customerID int) ([]Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := []Product{}
+	for _, p := range r.products {
+		if p.CustomerID == customerID {
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products, nil
+}
+
+func (r *MemoryRepository) GetProduct(ctx context.Context, customerID, id int) (*Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.products[id]
+	if !ok || p.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+func (r *MemoryRepository) CreateProduct(ctx context.Context, p *Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextID++
+	p.ID = r.nextID
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	r.products[p.ID] = *p
+	return nil
+}
+
+func (r *MemoryRepository) UpdateProduct(ctx context.Context, p *Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.products[p.ID]
+	if !ok || existing.CustomerID != p.CustomerID {
+		return ErrNotFound
+	}
+	if p.Quantity < existing.Reserved {
+		return ErrBelowReserved
+	}
+	p.Reserved = existing.Reserved
+	p.CreatedAt = existing.CreatedAt
+	p.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.products[p.ID] = *p
+	return nil
+}
+
+func (r *MemoryRepository) DeleteProduct(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.products[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	if existing.Reserved > 0 {
+		return ErrReserved
+	}
+	delete(r.products, id)
+	return nil
+}
+
+// shortages проверяет строки по available - доступному количеству товара. Вызывается под блокировкой.
+func (r *MemoryRepository) shortages(customerID int, lines []stock.Line, available func(Product) int) []stock.Shortage {
+	var shortages []stock.Shortage
+	for _, l := range lines {
+		n := 0
+		if p, ok := r.products[l.ProductID]; ok && p.CustomerID == customerID {
+			n = available(p)
+		}
+		if n < l.Quantity {
+			shortages = append(shortages, stock.Shortage{ProductID: l.ProductID, Requested: l.Quantity, Available: max(n, 0)})
+		}
+	}
+	return shortages
+}
+
+// apply меняет товары по строкам. Вызывается под блокировкой после проверки строк.
+func (r *MemoryRepository) apply(customerID int, lines []stock.Line, change func(p *Product, qty int)) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, l := range lines {
+		p, ok := r.products[l.ProductID]
+		if !ok || p.CustomerID != customerID {
+			continue
+		}
+		change(&p, l.Quantity)
+		p.UpdatedAt = now
+		r.products[l.ProductID] = p
+	}
+}
+
+func available(p Product) int { return p.Quantity - p.Reserved }
+
+func (r *MemoryRepository) TaxCategories(ctx context.Context, customerID int, productIDs []int) (map[int]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := make(map[int]string, len(productIDs))
+	for _, id := range productIDs {
+		if p, ok := r.products[id]; ok && p.CustomerID == customerID {
+			categories[id] = p.TaxCategory
+		}
+	}
+	return categories, nil
+}
+
+func (r *MemoryRepository) Shortages(ctx context.Context, customerID int, lines []stock.Line) ([]stock.Shortage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.shortages(customerID, stock.Merge(lines), available), nil
+}
+
+func (r *MemoryRepository) Reserve(ctx context.Context, customerID int, lines []stock.Line) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lines = stock.Merge(lines)
+	if s := r.shortages(customerID, lines, available); len(s) > 0 {
+		return &stock.InsufficientError{Shortages: s}
+	}
+	r.apply(customerID, lines, func(p *Product, qty int) { p.Reserved += qty })
+	return nil
+}
+
+func (r *MemoryRepository) Release(ctx context.Context, customerID int, lines []stock.Line) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apply(customerID, stock.Merge(lines), func(p *Product, qty int) { p.Reserved = max(p.Reserved-qty, 0) })
+	return nil
+}
+
+func (r *MemoryRepository) Deduct(ctx context.Context, customerID int, lines []stock.Line) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Списывается товар, находящийся на складе, включая резерв под этот заказ
+	lines = stock.Merge(lines)
+	if s := r.shortages(customerID, lines, func(p Product) int { return p.Quantity }); len(s) > 0 {
+		return &stock.InsufficientError{Shortages: s}
+	}
+	r.apply(customerID, lines, func(p *Product, qty int) {
+		p.Quantity -= qty
+		p.Reserved = max(p.Reserved-qty, 0)
+	})
+	return nil
+}
@@ -0,0 +1,38 @@
+package inventory
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/registry"
+)
+
+// Module подключает Склад к платформе
+type Module struct {
+	ctrl *Controller
+}
+
+// NewModule создает модуль Склада
+func NewModule(ctrl *Controller) *Module {
+	return &Module{ctrl: ctrl}
+}
+
+// Info возвращает описание модуля для каталога
+func (m *Module) Info() registry.Info {
+	return registry.Info{
+		Name:         "inventory",
+		Title:        "Склад",
+		Description:  "Учет товаров, категории, артикулы и остатки",
+		MonthlyPrice: 9,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты Склада
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	router.Get("/products", rbac.Require(rbac.ProductsRead), m.ctrl.GetProducts)
+	router.Post("/products", rbac.Require(rbac.ProductsWrite), m.ctrl.CreateProduct)
+	router.Put("/products/:id", rbac.Require(rbac.ProductsWrite), m.ctrl.UpdateProduct)
+	router.Delete("/products/:id", rbac.Require(rbac.ProductsWrite), m.ctrl.DeleteProduct)
+	router.Get("/products/:id", rbac.Require(rbac.ProductsRead), m.ctrl.GetProduct)
+	router.Get("/stats", rbac.Require(rbac.ProductsRead), m.ctrl.GetInventoryStats)
+}
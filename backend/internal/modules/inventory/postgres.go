@@ -0,0 +1,274 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/core/stock"
+	"kit8-backend/internal/database"
+)
+
+// PostgresRepository реализует ProductRepository поверх PostgreSQL
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий склада
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+const productColumns = `id, name, description, price, currency, tax_category, quantity, reserved, sku, category, image_url, customer_id, created_at, updated_at`
+
+func scanProduct(row interface{ Scan(...interface{}) error }) (*Product, error) {
+	var p Product
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Currency, &p.TaxCategory, &p.Quantity, &p.Reserved, &p.SKU, &p.Category, &p.ImageURL,
+		&p.CustomerID, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.CreatedAt = database.FormatTime(createdAt)
+	p.UpdatedAt = database.FormatTime(updatedAt)
+	return &p, nil
+}
+
+func (r *PostgresRepository) ListProducts(ctx context.Context, customerID int) ([]Product, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+productColumns+` FROM products WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *p)
+	}
+	return products, rows.Err()
+}
+
+func (r *PostgresRepository) GetProduct(ctx context.Context, customerID, id int) (*Product, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+productColumns+` FROM products WHERE customer_id = $1 AND id = $2`, customerID, id)
+	return scanProduct(row)
+}
+
+func (r *PostgresRepository) CreateProduct(ctx context.Context, p *Product) error {
+	var createdAt, updatedAt time.Time
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO products (customer_id, name, description, price, currency, tax_category, quantity, sku, category, image_url)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at`,
+		p.CustomerID, p.Name, p.Description, p.Price, p.Currency, p.TaxCategory, p.Quantity, p.SKU, p.Category, p.ImageURL,
+	).Scan(&p.ID, &createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+	p.CreatedAt = database.FormatTime(createdAt)
+	p.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+func (r *PostgresRepository) UpdateProduct(ctx context.Context, p *Product) error {
+	var createdAt, updatedAt time.Time
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE products SET name = $3, description = $4, price = $5, quantity = $6, sku = $7,
+		        category = $8, image_url = $9, currency = $10, tax_category = $11, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2 AND reserved <= $6 RETURNING reserved, created_at, updated_at`,
+		p.CustomerID, p.ID, p.Name, p.Description, p.Price, p.Quantity, p.SKU, p.Category, p.ImageURL, p.Currency,
+		p.TaxCategory,
+	).Scan(&p.Reserved, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Товар не обновлен: либо его нет, либо резерв больше нового остатка
+		var exists bool
+		err := r.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM products WHERE customer_id = $1 AND id = $2)`, p.CustomerID, p.ID,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrBelowReserved
+		}
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	p.CreatedAt = database.FormatTime(createdAt)
+	p.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+func (r *PostgresRepository) DeleteProduct(ctx context.Context, customerID, id int) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM products WHERE customer_id = $1 AND id = $2 AND reserved = 0`, customerID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// Товар не удален: либо его нет, либо он зарезервирован
+	var exists bool
+	err = r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM products WHERE customer_id = $1 AND id = $2)`, customerID, id,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrReserved
+	}
+	return ErrNotFound
+}
+
+// stockLevels возвращает количество и резерв товаров из строк. С lock строки товаров
+// блокируются до конца транзакции, чтобы проверка и изменение остатков были атомарны.
+func stockLevels(ctx context.Context, q database.Querier, customerID int, lines []stock.Line, lock bool) (map[int][2]int, error) {
+	ids := make([]int64, len(lines))
+	for i, l := range lines {
+		ids[i] = int64(l.ProductID)
+	}
+
+	query := `SELECT id, quantity, reserved FROM products WHERE customer_id = $1 AND id = ANY($2) ORDER BY id`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	rows, err := q.QueryContext(ctx, query, customerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := make(map[int][2]int)
+	for rows.Next() {
+		var id, quantity, reserved int
+		if err := rows.Scan(&id, &quantity, &reserved); err != nil {
+			return nil, err
+		}
+		levels[id] = [2]int{quantity, reserved}
+	}
+	return levels, rows.Err()
+}
+
+// shortages сравнивает строки с доступным количеством товара; available получает количество и резерв
+func shortages(lines []stock.Line, levels map[int][2]int, available func(quantity, reserved int) int) []stock.Shortage {
+	var result []stock.Shortage
+	for _, l := range lines {
+		n := 0
+		if level, ok := levels[l.ProductID]; ok {
+			n = available(level[0], level[1])
+		}
+		if n < l.Quantity {
+			result = append(result, stock.Shortage{ProductID: l.ProductID, Requested: l.Quantity, Available: max(n, 0)})
+		}
+	}
+	return result
+}
+
+func unreserved(quantity, reserved int) int { return quantity - reserved }
+
+func onHand(quantity, _ int) int { return quantity }
+
+// update применяет запрос к каждой строке; $1 - компания, $2 - товар, $3 - количество
+func update(ctx context.Context, q database.Querier, query string, customerID int, lines []stock.Line) error {
+	for _, l := range lines {
+		if _, err := q.ExecContext(ctx, query, customerID, l.ProductID, l.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) TaxCategories(ctx context.Context, customerID int, productIDs []int) (map[int]string, error) {
+	rows, err := database.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, tax_category FROM products WHERE customer_id = $1 AND id = ANY($2)`,
+		customerID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[int]string, len(productIDs))
+	for rows.Next() {
+		var id int
+		var category string
+		if err := rows.Scan(&id, &category); err != nil {
+			return nil, err
+		}
+		categories[id] = category
+	}
+	return categories, rows.Err()
+}
+
+func (r *PostgresRepository) Shortages(ctx context.Context, customerID int, lines []stock.Line) ([]stock.Shortage, error) {
+	lines = stock.Merge(lines)
+	levels, err := stockLevels(ctx, database.Conn(ctx, r.db), customerID, lines, false)
+	if err != nil {
+		return nil, err
+	}
+	return shortages(lines, levels, unreserved), nil
+}
+
+func (r *PostgresRepository) Reserve(ctx context.Context, customerID int, lines []stock.Line) error {
+	lines = stock.Merge(lines)
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		levels, err := stockLevels(ctx, q, customerID, lines, true)
+		if err != nil {
+			return err
+		}
+		if s := shortages(lines, levels, unreserved); len(s) > 0 {
+			return &stock.InsufficientError{Shortages: s}
+		}
+		return update(ctx, q,
+			`UPDATE products SET reserved = reserved + $3, updated_at = now() WHERE customer_id = $1 AND id = $2`,
+			customerID, lines)
+	})
+}
+
+func (r *PostgresRepository) Release(ctx context.Context, customerID int, lines []stock.Line) error {
+	lines = stock.Merge(lines)
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		return update(ctx, q,
+			`UPDATE products SET reserved = GREATEST(reserved - $3, 0), updated_at = now() WHERE customer_id = $1 AND id = $2`,
+			customerID, lines)
+	})
+}
+
+func (r *PostgresRepository) Deduct(ctx context.Context, customerID int, lines []stock.Line) error {
+	lines = stock.Merge(lines)
+	return database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+		// Списывается товар, находящийся на складе, включая резерв под этот заказ
+		levels, err := stockLevels(ctx, q, customerID, lines, true)
+		if err != nil {
+			return err
+		}
+		if s := shortages(lines, levels, onHand); len(s) > 0 {
+			return &stock.InsufficientError{Shortages: s}
+		}
+		return update(ctx, q,
+			`UPDATE products SET quantity = quantity - $3, reserved = GREATEST(reserved - $3, 0), updated_at = now()
+			 WHERE customer_id = $1 AND id = $2`,
+			customerID, lines)
+	})
+}
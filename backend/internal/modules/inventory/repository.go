@@ -0,0 +1,34 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/stock"
+	"kit8-backend/internal/core/tax"
+)
+
+// ErrNotFound возвращается, если товар не найден или принадлежит другой компании
+var ErrNotFound = errors.New("inventory: product not found")
+
+// ErrBelowReserved возвращается, если новый остаток товара меньше зарезервированного под заказы
+var ErrBelowReserved = errors.New("inventory: quantity is less than reserved stock")
+
+// ErrReserved возвращается при удалении товара, зарезервированного под заказы
+var ErrReserved = errors.New("inventory: product is reserved by orders")
+
+// ProductRepository хранит товары и выполняет над ними складские операции.
+// Все методы ограничены компанией customerID.
+type ProductRepository interface {
+	stock.Stock
+	tax.Catalog
+
+	ListProducts(ctx context.Context, customerID int) ([]Product, error)
+	GetProduct(ctx context.Context, customerID, id int) (*Product, error)
+	CreateProduct(ctx context.Context, product *Product) error
+	// UpdateProduct обновляет товар; резерв меняется только складскими операциями.
+	// Остаток проверяется по резерву атомарно с обновлением: ErrBelowReserved, если он меньше.
+	UpdateProduct(ctx context.Context, product *Product) error
+	// DeleteProduct удаляет товар без резерва; ErrReserved, если товар зарезервирован под заказы
+	DeleteProduct(ctx context.Context, customerID, id int) error
+}
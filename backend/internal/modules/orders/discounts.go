@@ -0,0 +1,240 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+)
+
+// Виды скидок в разбивке заказа
+const (
+	DiscountLine   = "line"   // Скидки на позиции
+	DiscountOrder  = "order"  // Скидка на заказ
+	DiscountCoupon = "coupon" // Скидка по купону
+)
+
+// Причины, по которым купон нельзя применить к заказу
+const (
+	CouponInactive   = "inactive"         // Купон отключен
+	CouponNotStarted = "not_started"      // Срок действия еще не начался
+	CouponExpired    = "expired"          // Срок действия истек
+	CouponCurrency   = "currency"         // Фиксированная скидка или минимальная сумма в другой валюте
+	CouponMinAmount  = "min_order_amount" // Сумма заказа меньше минимальной
+	CouponExhausted  = "usage_limit"      // Купон использован максимальное число раз
+)
+
+var (
+	// ErrCouponNotFound возвращается, если купон не найден или принадлежит другой компании
+	ErrCouponNotFound = errors.New("orders: coupon not found")
+	// ErrCouponExists возвращается, если у компании уже есть купон с таким кодом
+	ErrCouponExists = errors.New("orders: coupon code already exists")
+	// ErrInvalidDiscount возвращается, если скидка не задает ни процент, ни сумму или задает оба
+	ErrInvalidDiscount = errors.New("orders: invalid discount")
+)
+
+// CouponError возвращается, если купон нельзя применить к заказу
+type CouponError struct {
+	Code           string
+	Reason         string      // inactive, not_started, expired, currency, min_order_amount, usage_limit
+	MinOrderAmount money.Money // Для min_order_amount: минимальная сумма заказа
+}
+
+func (e *CouponError) Error() string {
+	return fmt.Sprintf("orders: coupon %s cannot be applied: %s", e.Code, e.Reason)
+}
+
+// Discount - скидка на позицию или заказ: процент или фиксированная сумма в валюте заказа
+type Discount struct {
+	Percent int64       `json:"percent"` // Процент скидки, от 1 до 100
+	Amount  money.Money `json:"amount"`  // Фиксированная скидка; не больше суммы, к которой применяется
+}
+
+// validate проверяет, что скидка задает либо процент, либо положительную сумму
+func (d *Discount) validate() error {
+	switch {
+	case d == nil:
+		return nil
+	case d.Percent < 0 || d.Percent > 100 || d.Amount.IsNegative():
+		return ErrInvalidDiscount
+	case d.Percent > 0 && !d.Amount.IsZero():
+		return ErrInvalidDiscount
+	case d.Percent == 0 && !d.Amount.IsPositive():
+		return ErrInvalidDiscount
+	}
+	return nil
+}
+
+// of возвращает размер скидки от суммы base
+func (d Discount) of(base money.Money) money.Money {
+	if d.Percent > 0 {
+		return base.MulRat(d.Percent, 100)
+	}
+	return money.Min(d.Amount.In(base.Currency()), base)
+}
+
+// AppliedDiscount - строка разбивки скидок заказа
+type AppliedDiscount struct {
+	Kind    string      `json:"kind"`           // line, order, coupon
+	Code    string      `json:"code,omitempty"` // Код купона
+	Percent int64       `json:"percent"`        // Процент скидки на заказ или купона; 0 - фиксированная скидка
+	Amount  money.Money `json:"amount"`         // Сумма скидки
+}
+
+// Coupon - купон (промокод) компании на скидку по заказу
+type Coupon struct {
+	ID         int    `json:"id"`
+	CustomerID int    `json:"customer_id"` // ID компании
+	Code       string `json:"code"`        // Уникален в компании; хранится в верхнем регистре
+	Discount
+	Currency       string      `json:"currency"`         // Валюта фиксированной скидки и минимальной суммы; по умолчанию - базовая
+	MinOrderAmount money.Money `json:"min_order_amount"` // Минимальная сумма заказа до скидки по купону
+	ValidFrom      string      `json:"valid_from"`       // Первый день действия (YYYY-MM-DD); пусто - без ограничения
+	ValidUntil     string      `json:"valid_until"`      // Последний день действия включительно; пусто - бессрочно
+	UsageLimit     int         `json:"usage_limit"`      // Сколько заказов может использовать купон; 0 - без ограничения
+	UsedCount      int         `json:"used_count"`       // Заказы с купоном, кроме отмененных; вычисляется хранилищем
+	Active         bool        `json:"active"`
+	CreatedAt      string      `json:"created_at"`
+	UpdatedAt      string      `json:"updated_at"`
+}
+
+// CouponCode приводит код купона к виду, в котором он хранится
+func CouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// invalid возвращает причину, по которой купон нельзя сохранить; пусто - купон корректен
+func (cp *Coupon) invalid() string {
+	switch {
+	case cp.Code == "":
+		return "Coupon code is required"
+	case cp.Discount.validate() != nil:
+		return "Coupon discount must set percent from 1 to 100 or a positive amount"
+	case cp.MinOrderAmount.IsNegative():
+		return "Minimum order amount cannot be negative"
+	case cp.UsageLimit < 0:
+		return "Usage limit cannot be negative"
+	}
+	for _, date := range []string{cp.ValidFrom, cp.ValidUntil} {
+		if _, err := time.Parse(currency.DateLayout, date); date != "" && err != nil {
+			return "Validity dates must be in YYYY-MM-DD format"
+		}
+	}
+	if cp.ValidFrom != "" && cp.ValidUntil != "" && cp.ValidUntil < cp.ValidFrom {
+		return "Coupon validity ends before it starts"
+	}
+	return ""
+}
+
+// check проверяет, что купон можно применить в день today (YYYY-MM-DD) к заказу в валюте
+// code на сумму amount. Лимит использований проверяет хранилище при создании заказа.
+func (cp *Coupon) check(code string, amount money.Money, today string) error {
+	fail := func(reason string) error {
+		return &CouponError{Code: cp.Code, Reason: reason, MinOrderAmount: cp.MinOrderAmount.In(cp.Currency)}
+	}
+	switch {
+	case !cp.Active:
+		return fail(CouponInactive)
+	case cp.ValidFrom != "" && today < cp.ValidFrom:
+		return fail(CouponNotStarted)
+	case cp.ValidUntil != "" && today > cp.ValidUntil:
+		return fail(CouponExpired)
+	case (cp.Amount.IsPositive() || cp.MinOrderAmount.IsPositive()) && code != cp.Currency:
+		return fail(CouponCurrency)
+	case amount.Cmp(cp.MinOrderAmount.In(code)) < 0:
+		return fail(CouponMinAmount)
+	}
+	return nil
+}
+
+// CouponRepository хранит купоны компаний.
+// Все методы ограничены компанией customerID.
+type CouponRepository interface {
+	ListCoupons(ctx context.Context, customerID int) ([]Coupon, error)
+	GetCoupon(ctx context.Context, customerID, id int) (*Coupon, error)
+	// CouponByCode ищет купон по коду, приведенному CouponCode
+	CouponByCode(ctx context.Context, customerID int, code string) (*Coupon, error)
+	// CreateCoupon сохраняет купон; ErrCouponExists, если код уже занят
+	CreateCoupon(ctx context.Context, coupon *Coupon) error
+	// UpdateCoupon обновляет купон; ErrCouponExists, если код уже занят другим купоном
+	UpdateCoupon(ctx context.Context, coupon *Coupon) error
+	// DeleteCoupon удаляет купон; заказы сохраняют код купона и скидку
+	DeleteCoupon(ctx context.Context, customerID, id int) error
+}
+
+// applyDiscounts рассчитывает скидки заказа по ценам позиций до налога: сначала скидки
+// позиций, затем скидку на заказ и купон от суммы позиций после предыдущих скидок.
+// Скидка на заказ и купон распределяются по позициям пропорционально их суммам, чтобы
+// налог каждой позиции считался с ее суммы после скидки.
+func (o *Order) applyDiscounts(coupon *Coupon, today string) error {
+	o.Discounts = []AppliedDiscount{}
+	bases := make([]money.Money, len(o.Items))
+	var lines money.Money
+	for i := range o.Items {
+		item := &o.Items[i]
+		bases[i] = item.Price.Mul(int64(item.Quantity))
+		item.DiscountAmount = money.Money{}
+		if item.Discount != nil {
+			item.DiscountAmount = item.Discount.of(bases[i])
+			bases[i] = bases[i].Sub(item.DiscountAmount)
+			lines = lines.Add(item.DiscountAmount)
+		}
+	}
+	if lines.IsPositive() {
+		o.Discounts = append(o.Discounts, AppliedDiscount{Kind: DiscountLine, Amount: lines})
+	}
+
+	if o.Discount != nil {
+		amount := o.spread(bases, o.Discount.of(money.Sum(bases...)))
+		o.Discounts = append(o.Discounts, AppliedDiscount{Kind: DiscountOrder, Percent: o.Discount.Percent, Amount: amount})
+	}
+
+	if coupon != nil {
+		total := money.Sum(bases...)
+		if err := coupon.check(o.Currency, total, today); err != nil {
+			return err
+		}
+		amount := o.spread(bases, coupon.Discount.of(total))
+		o.CouponID, o.CouponCode = coupon.ID, coupon.Code
+		o.Discounts = append(o.Discounts, AppliedDiscount{
+			Kind: DiscountCoupon, Code: coupon.Code, Percent: coupon.Percent, Amount: amount,
+		})
+	}
+	return nil
+}
+
+// spread распределяет скидку amount по позициям пропорционально их суммам bases после
+// предыдущих скидок и уменьшает bases. Остаток от округления достается самой крупной позиции,
+// но не больше ее суммы. Возвращает распределенную скидку.
+func (o *Order) spread(bases []money.Money, amount money.Money) money.Money {
+	total := money.Sum(bases...)
+	if !total.IsPositive() {
+		return money.Money{}
+	}
+	largest := 0
+	for i := range bases {
+		if bases[i].Cmp(bases[largest]) > 0 {
+			largest = i
+		}
+	}
+
+	rest := amount
+	for i := range bases {
+		if i == largest {
+			continue
+		}
+		share := bases[i].MulRat(amount.Minor(), total.Minor())
+		o.Items[i].DiscountAmount = o.Items[i].DiscountAmount.Add(share)
+		bases[i] = bases[i].Sub(share)
+		rest = rest.Sub(share)
+	}
+	short := rest.Sub(money.Min(rest, bases[largest]))
+	rest = rest.Sub(short)
+	o.Items[largest].DiscountAmount = o.Items[largest].DiscountAmount.Add(rest)
+	bases[largest] = bases[largest].Sub(rest)
+	return amount.Sub(short)
+}
@@ -1,10 +1,23 @@
 package orders
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/auth"
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/report"
+	"kit8-backend/internal/core/settlement"
+	"kit8-backend/internal/core/stock"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
 )
 
 // OrderItem представляет товар в заказе
@@ -13,8 +26,13 @@ type OrderItem struct {
 	ProductID int    `json:"product_id"`
 	ProductName string `json:"product_name"`
 	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
-	Total    float64 `json:"total"` // Quantity * Price
+	Price    money.Money `json:"price"`        // С налогом или без - по режиму цен заказа
+	Discount *Discount   `json:"discount,omitempty"` // Скидка на позицию
+	DiscountAmount money.Money `json:"discount_amount"` // Все скидки позиции, включая ее долю скидки на заказ и купона
+	TaxCategory string   `json:"tax_category"` // По умолчанию - категория товара, затем категория компании
+	Net      money.Money `json:"net"`          // Сумма позиции без налога
+	Tax      money.Money `json:"tax"`
+	Total    money.Money `json:"total"` // Сумма позиции с налогом
 }
 
 // Order представляет заказ
@@ -22,141 +40,351 @@ type Order struct {
 	ID           int          `json:"id"`
 	CustomerID   int          `json:"customer_id"` // ID компании
 	ContactID    int          `json:"contact_id"`  // ID клиента из CRM
+	DealID       int          `json:"deal_id"`     // Сделка CRM, по которой создан заказ; 0 - заказ создан вручную
 	Items        []OrderItem `json:"items"`
-	TotalAmount  float64      `json:"total_amount"`
+	Discount     *Discount    `json:"discount,omitempty"` // Скидка на заказ
+	CouponCode   string       `json:"coupon_code"`        // Код купона, примененного при создании
+	CouponID     int          `json:"coupon_id"`          // 0 - без купона или купон удален
+	Subtotal     money.Money  `json:"subtotal"`           // Сумма позиций по ценам заказа до скидок
+	DiscountAmount money.Money `json:"discount_amount"`   // Сумма всех скидок
+	Discounts    []AppliedDiscount `json:"discounts"`     // Разбивка скидок по видам
+	TotalAmount  money.Money  `json:"total_amount"` // С налогом
+	NetAmount    money.Money  `json:"net_amount"`   // Без налога
+	TaxAmount    money.Money  `json:"tax_amount"`
+	TaxBreakdown []tax.Line   `json:"tax_breakdown"` // Суммы по налоговым категориям
+	PricesIncludeTax bool     `json:"prices_include_tax"` // Режим цен компании на момент создания заказа
+	Currency     string       `json:"currency"`    // Валюта заказа; по умолчанию - базовая валюта компании
 	Status       string       `json:"status"`      // new, confirmed, in-progress, shipped, delivered, cancelled
-	PaymentStatus string      `json:"payment_status"` // unpaid, paid, refunded, pending
+	PaymentStatus string      `json:"payment_status"` // unpaid, pending, partially_paid, paid, refunded
+	PaidAmount   money.Money  `json:"paid_amount"`    // Получено по платежам Кассы за вычетом возвратов
+	Outstanding  money.Money  `json:"outstanding"`    // Остаток к оплате
 	ShippingAddress string   `json:"shipping_address"`
 	Notes        string       `json:"notes"`
 	CreatedAt    string       `json:"created_at"`
 	UpdatedAt    string       `json:"updated_at"`
 }
 
-// OrderStats представляет статистику по заказам
+// OrderStats представляет статистику по заказам, созданным за период
 type OrderStats struct {
+	report.Period
 	TotalOrders     int     `json:"total_orders"`
-	TotalRevenue    float64 `json:"total_revenue"`
+	TotalRevenue    money.Money `json:"total_revenue"`
 	PendingOrders   int     `json:"pending_orders"`
 	ProcessingOrders int    `json:"processing_orders"`
 	CompletedOrders int     `json:"completed_orders"`
+	CancelledOrders int     `json:"cancelled_orders"`
+	Currency        string  `json:"currency"` // Базовая валюта компании, в которой считается выручка
+	Series          []OrderStatsPoint `json:"series"`
+}
+
+// OrderStatsPoint - заказы, созданные за период группировки
+type OrderStatsPoint struct {
+	Period    string      `json:"period"` // Начало периода, YYYY-MM-DD
+	Orders    int         `json:"orders"`
+	Cancelled int         `json:"cancelled"`
+	Revenue   money.Money `json:"revenue"` // Неотмененные заказы в базовой валюте компании
+}
+
+// LineError описывает ошибку по позиции заказа
+type LineError struct {
+	Line        int    `json:"line"` // Номер позиции в items, начиная с 0
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+	Error       string `json:"error"`
 }
 
 // Контроллер Заказов
 type Controller struct {
-	// Здесь будут зависимости, например, сервисы и репозитории
-	// Для упрощения в этом примере будем использовать заглушку
+	orders     OrderRepository
+	coupons    CouponRepository
+	stock      stock.Stock         // Остатки товаров Склада
+	catalog    tax.Catalog         // Налоговые категории товаров Склада
+	currencies currency.Currencies // Базовая валюта и курсы компании
+	taxes      tax.Rules           // Налоговые настройки компании
 }
 
 // NewController создает новый контроллер Заказов
-func NewController() *Controller {
-	return &Controller{}
+func NewController(orders OrderRepository, coupons CouponRepository, products stock.Stock, catalog tax.Catalog,
+	currencies currency.Currencies, taxes tax.Rules) *Controller {
+	return &Controller{orders: orders, coupons: coupons, stock: products, catalog: catalog, currencies: currencies, taxes: taxes}
 }
 
 // GetOrders возвращает список заказов
 func (ctrl *Controller) GetOrders(c *fiber.Ctx) error {
-	// Получаем ID компании из контекста (предполагается, что он был установлен в middleware)
-	customerID := c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// и фильтрация по customerID
-	orders := []Order{
-		{
-			ID: 1, CustomerID: customerID, ContactID: 1, 
-			Items: []OrderItem{
-				{ID: 1, ProductID: 1, ProductName: "Ноутбук", Quantity: 1, Price: 50000.0, Total: 50000.0},
-			},
-			TotalAmount: 50000.0, Status: "confirmed", PaymentStatus: "paid", 
-			ShippingAddress: "г. Москва, ул. Примерная, д. 1", 
-			Notes: "", CreatedAt: "2023-01-01T00:00:00Z", UpdatedAt: "2023-01-01T00:00:00Z",
-		},
-		{
-			ID: 2, CustomerID: customerID, ContactID: 2, 
-			Items: []OrderItem{
-				{ID: 2, ProductID: 2, ProductName: "Мышь", Quantity: 2, Price: 1500.0, Total: 3000.0},
-			},
-			TotalAmount: 3000.0, Status: "new", PaymentStatus: "unpaid", 
-			ShippingAddress: "г. Санкт-Петербург, ул. Образцовая, д. 5", 
-			Notes: "Доставить после 18:00", CreatedAt: "2023-01-02T00:00:00Z", UpdatedAt: "2023-01-02T00:00:00Z",
-		},
-	}
-	
+	// Получаем ID компании из контекста (устанавливается в tenant middleware)
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	orders, err := ctrl.orders.ListOrders(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(orders)
 }
 
 // CreateOrder создает новый заказ
 func (ctrl *Controller) CreateOrder(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Парсим тело запроса
 	var order Order
 	if err := c.BodyParser(&order); err != nil {
-	return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
+
 	// Устанавливаем ID компании для нового заказа
 	order.CustomerID = customerID
-	order.Status = "new" // Устанавливаем начальный статус
-	order.PaymentStatus = "unpaid" // Устанавливаем начальный статус оплаты
-	
-	// Вычисляем общую сумму заказа
-	total := 0.0
-	for i := range order.Items {
-		order.Items[i].Total = float64(order.Items[i].Quantity) * order.Items[i].Price
-		total += order.Items[i].Total
+	order.DealID = 0               // Заказ по сделке создается конвертацией сделки в CRM
+	order.Status = StatusNew       // Устанавливаем начальный статус
+	order.PaymentStatus = settlement.StatusUnpaid // Статус оплаты меняется платежами Кассы
+	order.PaidAmount = money.Money{}
+	order.Currency, err = currency.Resolve(c.UserContext(), ctrl.currencies, customerID, order.Currency)
+	if errors.Is(err, currency.ErrInvalidCurrency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
 	}
-	order.TotalAmount = total
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для сохранения заказа в базе данных
-	// order.ID = generateNextID() // генерация нового ID
-	
+	if err != nil {
+		return err
+	}
+
+	// Проверяем, что товара на складе хватает на каждую позицию
+	for _, item := range order.Items {
+		if item.Quantity <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Item quantity must be positive"})
+		}
+		if item.Discount.validate() != nil {
+			return invalidDiscount(c)
+		}
+	}
+	if order.Discount.validate() != nil {
+		return invalidDiscount(c)
+	}
+	shortages, err := ctrl.stock.Shortages(c.UserContext(), customerID, stockLines(order.Items))
+	if err != nil {
+		return err
+	}
+	if len(shortages) > 0 {
+		return insufficientStock(c, order.Items, shortages)
+	}
+
+	// Применяем скидки и купон до налога, затем вычисляем налог и сумму заказа
+	var coupon *Coupon
+	order.CouponID = 0
+	order.CouponCode = CouponCode(order.CouponCode)
+	if order.CouponCode != "" {
+		coupon, err = ctrl.coupons.CouponByCode(c.UserContext(), customerID, order.CouponCode)
+		if errors.Is(err, ErrCouponNotFound) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown coupon code"})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := ctrl.price(c.UserContext(), &order, coupon); err != nil {
+		if errors.Is(err, tax.ErrInvalidCategory) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tax category"})
+		}
+		return orderError(c, err)
+	}
+
+	// Сохраняем заказ вместе с позициями; лимит использований купона проверяется атомарно
+	err = ctrl.orders.CreateOrder(c.UserContext(), &order)
+	if errors.Is(err, ErrContactNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return orderError(c, err)
+	}
+
 	// Возвращаем созданный заказ
 	return c.JSON(order)
 }
 
-// UpdateOrder обновляет существующий заказ
+// price применяет к заказу скидки и купон coupon (nil - без купона), затем вычисляет
+// налог позиций и общую сумму заказа
+func (ctrl *Controller) price(ctx context.Context, order *Order, coupon *Coupon) error {
+	if err := order.applyDiscounts(coupon, time.Now().UTC().Format(currency.DateLayout)); err != nil {
+		return err
+	}
+	if err := ctrl.applyTax(ctx, order); err != nil {
+		return err
+	}
+	var total money.Money
+	for _, item := range order.Items {
+		total = total.Add(item.Total)
+	}
+	order.TotalAmount = total
+	order.summarize()
+	return nil
+}
+
+// applyTax рассчитывает налог позиций заказа в режиме цен компании. Категория позиции
+// берется из запроса, иначе из товара Склада, иначе из настроек компании.
+func (ctrl *Controller) applyTax(ctx context.Context, order *Order) error {
+	settings, err := ctrl.taxes.Settings(ctx, order.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	var productIDs []int
+	for _, item := range order.Items {
+		if item.TaxCategory == "" && item.ProductID != 0 {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	categories := map[int]string{}
+	if len(productIDs) > 0 {
+		if categories, err = ctrl.catalog.TaxCategories(ctx, order.CustomerID, productIDs); err != nil {
+			return err
+		}
+	}
+
+	order.PricesIncludeTax = settings.PricesIncludeTax
+	for i := range order.Items {
+		item := &order.Items[i]
+		code := item.TaxCategory
+		if code == "" {
+			code = categories[item.ProductID]
+		}
+		if item.TaxCategory, err = settings.Category(code); err != nil {
+			return err
+		}
+		line := tax.Calculate(item.Price, int64(item.Quantity), item.TaxCategory, order.PricesIncludeTax)
+		if item.DiscountAmount.IsPositive() {
+			// Налог позиции со скидкой считается с суммы позиции после скидки
+			amount := item.Price.Mul(int64(item.Quantity)).Sub(item.DiscountAmount)
+			line = tax.Calculate(amount, 1, item.TaxCategory, order.PricesIncludeTax)
+		}
+		item.Net, item.Tax, item.Total = line.Net, line.Tax, line.Total
+	}
+	return nil
+}
+
+// invalidDiscount отвечает на скидку, которая не задает ни процент, ни сумму или задает оба
+func invalidDiscount(c *fiber.Ctx) error {
+	return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+		"error": "Discount must set percent from 1 to 100 or a positive amount",
+	})
+}
+
+// orderError отвечает на ошибку создания заказа: купон, который нельзя применить, - 409
+func orderError(c *fiber.Ctx, err error) error {
+	var couponErr *CouponError
+	if !errors.As(err, &couponErr) {
+		return err
+	}
+	body := fiber.Map{"error": "Coupon cannot be applied", "code": couponErr.Code, "reason": couponErr.Reason}
+	if couponErr.Reason == CouponMinAmount {
+		body["min_order_amount"] = couponErr.MinOrderAmount
+	}
+	return c.Status(http.StatusConflict).JSON(body)
+}
+
+// UpdateOrder обновляет существующий заказ.
+// Позиции заказа, скидки, сумма, налог и валюта после создания не меняются.
 func (ctrl *Controller) UpdateOrder(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID заказа из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
 	}
-	
+
 	// Парсим тело запроса
 	var updatedOrder Order
 	if err := c.BodyParser(&updatedOrder); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для обновления заказа в базе данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
-	// Возвращаем обновленный заказ
+
+	// Чужие заказы для хранилища не существуют
+	current, err := ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Статус меняется только через переходы: confirm, start, ship, deliver, cancel
+	if updatedOrder.Status != "" && updatedOrder.Status != current.Status {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Use status transition endpoints to change order status"})
+	}
+
+	// Статус оплаты пересчитывается по платежам Кассы
+	if updatedOrder.PaymentStatus != "" && updatedOrder.PaymentStatus != current.PaymentStatus {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Payment status is updated by cashier payments"})
+	}
+
+	// Обновляем заказ
 	updatedOrder.ID = id
 	updatedOrder.CustomerID = customerID
-	return c.JSON(updatedOrder)
+	err = ctrl.orders.UpdateOrder(c.UserContext(), &updatedOrder)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if errors.Is(err, ErrContactNotFound) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Contact not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Возвращаем обновленный заказ вместе с сохраненными позициями
+	order, err := ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(order)
 }
 
 // DeleteOrder удаляет заказ
 func (ctrl *Controller) DeleteOrder(c *fiber.Ctx) error {
 	// Получаем ID заказа из параметров URL
-	_, err := strconv.Atoi(c.Params("id"))
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
 	}
 
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для удаления заказа из базы данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	order, err := ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Под подтвержденный заказ зарезервирован товар: резерв снимается отменой заказа
+	if order.Status == StatusConfirmed || order.Status == StatusInProgress {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Cancel the order before deleting it"})
+	}
+
+	err = ctrl.orders.DeleteOrder(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
 	// Возвращаем успешный ответ
 	return c.SendStatus(http.StatusOK)
 }
@@ -164,45 +392,416 @@ func (ctrl *Controller) DeleteOrder(c *fiber.Ctx) error {
 // GetOrder возвращает информацию о конкретном заказе
 func (ctrl *Controller) GetOrder(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	customerID := c.Locals("customer_id").(int)
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
 	// Получаем ID заказа из параметров URL
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
 	}
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения заказа из базы данных с проверкой, 
-	// принадлежит ли он текущей компании (customerID)
-	
-	order := Order{
-		ID: id, CustomerID: customerID, ContactID: 1, 
-		Items: []OrderItem{
-			{ID: 1, ProductID: 1, ProductName: "Ноутбук", Quantity: 1, Price: 50000.0, Total: 50000.0},
-		},
-		TotalAmount: 50000.0, Status: "confirmed", PaymentStatus: "paid", 
-		ShippingAddress: "г. Москва, ул. Примерная, д. 1", 
-		Notes: "", CreatedAt: "2023-01-01T00:00:00Z", UpdatedAt: "2023-01-01T00:00:00Z",
-	}
-	
+
+	order, err := ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(order)
+}
+
+// StatusChangeRequest - необязательное тело запроса на смену статуса заказа
+type StatusChangeRequest struct {
+	Comment string `json:"comment"` // Например, причина отмены
+}
+
+// ConfirmOrder подтверждает новый заказ
+func (ctrl *Controller) ConfirmOrder(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, StatusConfirmed)
+}
+
+// StartOrder передает подтвержденный заказ в работу
+func (ctrl *Controller) StartOrder(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, StatusInProgress)
+}
+
+// ShipOrder отмечает заказ отгруженным
+func (ctrl *Controller) ShipOrder(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, StatusShipped)
+}
+
+// DeliverOrder отмечает заказ доставленным
+func (ctrl *Controller) DeliverOrder(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, StatusDelivered)
+}
+
+// CancelOrder отменяет заказ, который еще не отгружен
+func (ctrl *Controller) CancelOrder(c *fiber.Ctx) error {
+	return ctrl.changeStatus(c, StatusCancelled)
+}
+
+// changeStatus переводит заказ в статус to, если переход допустим, и возвращает обновленный заказ
+func (ctrl *Controller) changeStatus(c *fiber.Ctx, to string) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID заказа из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+
+	// Тело запроса необязательно
+	var req StatusChangeRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	order, err := ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	if !CanTransition(order.Status, to) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": fmt.Sprintf("Cannot change order status from %s to %s", order.Status, to),
+		})
+	}
+
+	change := StatusChange{OrderID: id, FromStatus: order.Status, ToStatus: to, Comment: req.Comment}
+	if claims, ok := auth.CurrentClaims(c); ok {
+		change.ChangedBy = claims.UserID()
+	}
+
+	// Складская операция выполняется атомарно со сменой статуса
+	var apply func(ctx context.Context) error
+	if op := ctrl.stockOperation(order.Status, to); op != nil {
+		lines := stockLines(order.Items)
+		apply = func(ctx context.Context) error { return op(ctx, customerID, lines) }
+	}
+
+	err = ctrl.orders.ChangeStatus(c.UserContext(), customerID, &change, apply)
+	var insufficient *stock.InsufficientError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	case errors.Is(err, ErrStatusChanged):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Order status was changed by another request"})
+	case errors.As(err, &insufficient):
+		return insufficientStock(c, order.Items, insufficient.Shortages)
+	case err != nil:
+		return err
+	}
+
+	// Возвращаем заказ в новом статусе
+	order, err = ctrl.orders.GetOrder(c.UserContext(), customerID, id)
+	if err != nil {
+		return err
+	}
 	return c.JSON(order)
 }
 
-// GetOrderStats возвращает статистику по заказам
+// stockOperation возвращает складскую операцию перехода from -> to: подтверждение резервирует товар,
+// отгрузка списывает его со склада, отмена подтвержденного заказа снимает резерв
+func (ctrl *Controller) stockOperation(from, to string) func(ctx context.Context, customerID int, lines []stock.Line) error {
+	switch {
+	case to == StatusConfirmed:
+		return ctrl.stock.Reserve
+	case to == StatusShipped:
+		return ctrl.stock.Deduct
+	case to == StatusCancelled && from != StatusNew:
+		return ctrl.stock.Release
+	}
+	return nil
+}
+
+// stockLines возвращает складские строки позиций заказа; позиции без товара не учитываются
+func stockLines(items []OrderItem) []stock.Line {
+	lines := make([]stock.Line, 0, len(items))
+	for _, item := range items {
+		if item.ProductID != 0 {
+			lines = append(lines, stock.Line{ProductID: item.ProductID, Quantity: item.Quantity})
+		}
+	}
+	return lines
+}
+
+// insufficientStock отвечает 409 с ошибкой по каждой позиции, товара которой не хватает
+func insufficientStock(c *fiber.Ctx, items []OrderItem, shortages []stock.Shortage) error {
+	available := make(map[int]int, len(shortages))
+	for _, s := range shortages {
+		available[s.ProductID] = s.Available
+	}
+
+	lines := []LineError{}
+	for i, item := range items {
+		n, ok := available[item.ProductID]
+		if !ok || item.ProductID == 0 {
+			continue
+		}
+		lines = append(lines, LineError{
+			Line:        i,
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Requested:   item.Quantity,
+			Available:   n,
+			Error:       "Insufficient stock",
+		})
+	}
+
+	return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Insufficient stock", "lines": lines})
+}
+
+// GetOrderHistory возвращает историю статусов заказа
+func (ctrl *Controller) GetOrderHistory(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	// Получаем ID заказа из параметров URL
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
+
+	history, err := ctrl.orders.StatusHistory(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(history)
+}
+
+// GetOrderStats возвращает статистику по заказам, созданным за период from - to,
+// с рядом по группам group
 func (ctrl *Controller) GetOrderStats(c *fiber.Ctx) error {
 	// Получаем ID компании из контекста
-	_ = c.Locals("customer_id").(int)
-	
-	// В реальном приложении здесь будет вызов сервисного слоя
-	// для получения статистики из базы данных с фильтрацией по customerID
-	stats := OrderStats{
-		TotalOrders:     50,
-		TotalRevenue:    125000.0,
-		PendingOrders:   5,
-		ProcessingOrders: 8,
-		CompletedOrders: 35,
-	}
-	
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	period, err := report.FromQuery(c)
+	if err != nil {
+		return report.Invalid(c, err)
+	}
+
+	stats, err := ctrl.orderStats(c.UserContext(), customerID, period)
+	var noRate *currency.NoRateError
+	if errors.As(err, &noRate) {
+		return currency.NoRate(c, noRate)
+	}
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(stats)
-}
\ No newline at end of file
+}
+
+// orderStats подводит итоги по заказам компании, созданным за период; выручка - сумма
+// неотмененных заказов в базовой валюте по курсу на дату заказа
+func (ctrl *Controller) orderStats(ctx context.Context, customerID int, period report.Range) (*OrderStats, error) {
+	orders, err := ctrl.orders.ListOrders(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := ctrl.currencies.Converter(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &OrderStats{Period: period.Period(), Currency: conv.Base()}
+	points := make(map[string]*OrderStatsPoint)
+	var bounds report.Bounds
+	for _, order := range orders {
+		created := currency.At(order.CreatedAt)
+		if !period.Contains(created) {
+			continue
+		}
+		label := period.Label(created)
+		point, ok := points[label]
+		if !ok {
+			point = &OrderStatsPoint{Period: label}
+			points[label] = point
+		}
+		bounds.Add(created)
+
+		stats.TotalOrders++
+		point.Orders++
+		switch order.Status {
+		case StatusNew:
+			stats.PendingOrders++
+		case StatusConfirmed, StatusInProgress, StatusShipped:
+			stats.ProcessingOrders++
+		case StatusDelivered:
+			stats.CompletedOrders++
+		case StatusCancelled:
+			stats.CancelledOrders++
+			point.Cancelled++
+			continue
+		}
+		revenue, err := conv.ToBase(order.TotalAmount.In(order.Currency), created)
+		if err != nil {
+			return nil, err
+		}
+		stats.TotalRevenue = stats.TotalRevenue.Add(revenue)
+		point.Revenue = point.Revenue.Add(revenue)
+	}
+
+	stats.Series = []OrderStatsPoint{}
+	for _, label := range period.Buckets(bounds.First, bounds.Last) {
+		point := OrderStatsPoint{Period: label}
+		if found, ok := points[label]; ok {
+			point = *found
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	return stats, nil
+}
+
+// GetCoupons возвращает купоны компании
+func (ctrl *Controller) GetCoupons(c *fiber.Ctx) error {
+	// Получаем ID компании из контекста
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	coupons, err := ctrl.coupons.ListCoupons(c.UserContext(), customerID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(coupons)
+}
+
+// GetCoupon возвращает купон по ID
+func (ctrl *Controller) GetCoupon(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid coupon ID"})
+	}
+
+	coupon, err := ctrl.coupons.GetCoupon(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrCouponNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Coupon not found"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(coupon)
+}
+
+// CreateCoupon создает купон. Новый купон активен, если не передано "active": false.
+func (ctrl *Controller) CreateCoupon(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	coupon := Coupon{Active: true}
+	if err := c.BodyParser(&coupon); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	coupon.ID = 0
+	coupon.CustomerID = customerID
+	return ctrl.saveCoupon(c, &coupon, ctrl.coupons.CreateCoupon, http.StatusCreated)
+}
+
+// UpdateCoupon обновляет купон. Поля, которых нет в запросе, не меняются.
+func (ctrl *Controller) UpdateCoupon(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid coupon ID"})
+	}
+
+	coupon, err := ctrl.coupons.GetCoupon(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrCouponNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Coupon not found"})
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.BodyParser(coupon); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	coupon.ID = id
+	coupon.CustomerID = customerID
+	return ctrl.saveCoupon(c, coupon, ctrl.coupons.UpdateCoupon, http.StatusOK)
+}
+
+// saveCoupon проверяет купон и сохраняет его функцией save
+func (ctrl *Controller) saveCoupon(c *fiber.Ctx, coupon *Coupon, save func(ctx context.Context, coupon *Coupon) error, status int) error {
+	coupon.Code = CouponCode(coupon.Code)
+	if problem := coupon.invalid(); problem != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": problem})
+	}
+
+	var err error
+	coupon.Currency, err = currency.Resolve(c.UserContext(), ctrl.currencies, coupon.CustomerID, coupon.Currency)
+	if errors.Is(err, currency.ErrInvalidCurrency) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Unsupported currency"})
+	}
+	if err != nil {
+		return err
+	}
+
+	err = save(c.UserContext(), coupon)
+	switch {
+	case errors.Is(err, ErrCouponExists):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Coupon code already exists"})
+	case errors.Is(err, ErrCouponNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Coupon not found"})
+	case err != nil:
+		return err
+	}
+	return c.Status(status).JSON(coupon)
+}
+
+// DeleteCoupon удаляет купон. Заказы, созданные с купоном, сохраняют его код и скидку.
+func (ctrl *Controller) DeleteCoupon(c *fiber.Ctx) error {
+	customerID, err := tenant.CustomerID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid coupon ID"})
+	}
+
+	err = ctrl.coupons.DeleteCoupon(c.UserContext(), customerID, id)
+	if errors.Is(err, ErrCouponNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Coupon not found"})
+	}
+	if err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusOK)
+}
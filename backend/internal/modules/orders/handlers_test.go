@@ -0,0 +1,217 @@
+package orders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/tax"
+	"kit8-backend/internal/core/tenant"
+	"kit8-backend/internal/modules/crm"
+	"kit8-backend/internal/modules/inventory"
+)
+
+// testApp собирает маршруты Заказов на хранилищах в памяти со Складом и CRM в памяти.
+// Компания запроса берется из заголовка X-Customer-ID, вызывающий - владелец компании.
+func testApp() (*fiber.App, *inventory.MemoryRepository, *crm.MemoryRepository) {
+	products := inventory.NewMemoryRepository()
+	contacts := crm.NewMemoryRepository()
+	repo := NewMemoryRepository(contacts)
+	ctrl := NewController(repo, repo, products, products, currency.NewService(currency.NewMemoryStore()),
+		tax.NewService(tax.NewMemoryStore()))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		customerID, err := strconv.Atoi(c.Get("X-Customer-ID"))
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+		tenant.SetCustomerID(c, customerID)
+		rbac.SetRole(c, rbac.RoleOwner)
+		return c.Next()
+	})
+	NewModule(ctrl).RegisterRoutes(app)
+	return app, products, contacts
+}
+
+// call выполняет запрос от имени компании customerID и разбирает JSON ответа в out, если он не nil
+func call(t *testing.T, app *fiber.App, customerID int, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Customer-ID", strconv.Itoa(customerID))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// orderBody возвращает тело запроса заказа с одной позицией без товара Склада
+func orderBody(name string, quantity int) fiber.Map {
+	return fiber.Map{"items": []fiber.Map{{"product_name": name, "quantity": quantity, "price": 10}}}
+}
+
+func TestOrderCRUD(t *testing.T) {
+	app, _, _ := testApp()
+
+	var created Order
+	if code := call(t, app, 1, http.MethodPost, "/orders", orderBody("Widget", 2), &created); code != http.StatusOK {
+		t.Fatalf("create: status %d", code)
+	}
+	if created.ID == 0 || created.CustomerID != 1 || created.Status != StatusNew || len(created.Items) != 1 {
+		t.Fatalf("create: unexpected order %+v", created)
+	}
+	if created.Subtotal.Minor() != 2000 {
+		t.Fatalf("create: subtotal %s, want 20.00", created.Subtotal.Fixed())
+	}
+	path := "/orders/" + strconv.Itoa(created.ID)
+
+	var got Order
+	if code := call(t, app, 1, http.MethodGet, path, nil, &got); code != http.StatusOK || got.ID != created.ID {
+		t.Fatalf("get: status %d, order %+v", code, got)
+	}
+
+	// Позиции и сумма после создания не меняются
+	var updated Order
+	body := orderBody("Widget", 3)
+	body["notes"] = "Call before delivery"
+	body["shipping_address"] = "Main st. 1"
+	if code := call(t, app, 1, http.MethodPut, path, body, &updated); code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	if updated.Notes != "Call before delivery" || updated.ShippingAddress != "Main st. 1" ||
+		updated.Subtotal.Minor() != 2000 || updated.CreatedAt != created.CreatedAt {
+		t.Fatalf("update: unexpected order %+v", updated)
+	}
+
+	if code := call(t, app, 1, http.MethodDelete, path, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code := call(t, app, 1, http.MethodGet, path, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("get after delete: status %d, want 404", code)
+	}
+}
+
+func TestOrderTenantIsolation(t *testing.T) {
+	app, _, _ := testApp()
+
+	var own Order
+	call(t, app, 1, http.MethodPost, "/orders", orderBody("Widget", 1), &own)
+	path := "/orders/" + strconv.Itoa(own.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"get", http.MethodGet, path, nil},
+		{"update", http.MethodPut, path, fiber.Map{"notes": "Stolen"}},
+		{"history", http.MethodGet, path + "/history", nil},
+		{"confirm", http.MethodPost, path + "/confirm", nil},
+		{"cancel", http.MethodPost, path + "/cancel", nil},
+		{"delete", http.MethodDelete, path, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, app, 2, tt.method, tt.path, tt.body, nil); code != http.StatusNotFound {
+				t.Fatalf("status %d, want 404", code)
+			}
+		})
+	}
+
+	var others []Order
+	call(t, app, 2, http.MethodGet, "/orders", nil, &others)
+	if len(others) != 0 {
+		t.Fatalf("other company sees %d orders", len(others))
+	}
+
+	var got Order
+	if call(t, app, 1, http.MethodGet, path, nil, &got); got.Status != StatusNew || got.Notes != "" {
+		t.Fatalf("order changed by other company: %+v", got)
+	}
+}
+
+func TestOrderForeignProduct(t *testing.T) {
+	app, products, _ := testApp()
+
+	// Товар другой компании для заказа не существует
+	product := inventory.Product{CustomerID: 2, Name: "Widget", Quantity: 5}
+	if err := products.CreateProduct(context.Background(), &product); err != nil {
+		t.Fatal(err)
+	}
+	body := fiber.Map{"items": []fiber.Map{{"product_id": product.ID, "quantity": 1, "price": 10}}}
+	if code := call(t, app, 1, http.MethodPost, "/orders", body, nil); code < 400 || code >= 500 {
+		t.Fatalf("order with foreign product: status %d, want 4xx", code)
+	}
+}
+
+func TestOrderForeignContact(t *testing.T) {
+	app, _, contacts := testApp()
+
+	own := crm.Contact{CustomerID: 1, Name: "Ann"}
+	foreign := crm.Contact{CustomerID: 2, Name: "Bob"}
+	for _, contact := range []*crm.Contact{&own, &foreign} {
+		if err := contacts.CreateContact(context.Background(), contact); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := orderBody("Widget", 1)
+	body["contact_id"] = foreign.ID
+	if code := call(t, app, 1, http.MethodPost, "/orders", body, nil); code != http.StatusBadRequest {
+		t.Fatalf("order with foreign contact: status %d, want 400", code)
+	}
+
+	var order Order
+	body["contact_id"] = own.ID
+	if code := call(t, app, 1, http.MethodPost, "/orders", body, &order); code != http.StatusOK || order.ContactID != own.ID {
+		t.Fatalf("order with own contact: status %d, order %+v", code, order)
+	}
+
+	path := "/orders/" + strconv.Itoa(order.ID)
+	if code := call(t, app, 1, http.MethodPut, path, fiber.Map{"contact_id": foreign.ID}, nil); code != http.StatusBadRequest {
+		t.Fatalf("update to foreign contact: status %d, want 400", code)
+	}
+	var got Order
+	if call(t, app, 1, http.MethodGet, path, nil, &got); got.ContactID != own.ID {
+		t.Fatalf("contact changed to %d", got.ContactID)
+	}
+}
+
+func TestOrderIDsSharedAcrossCompanies(t *testing.T) {
+	app, _, _ := testApp()
+
+	var first, second Order
+	call(t, app, 1, http.MethodPost, "/orders", orderBody("A", 1), &first)
+	call(t, app, 2, http.MethodPost, "/orders", orderBody("B", 1), &second)
+	if first.ID == 0 || second.ID != first.ID+1 {
+		t.Fatalf("IDs %d and %d are not assigned from one sequence", first.ID, second.ID)
+	}
+	if first.Items[0].ID == 0 || second.Items[0].ID != first.Items[0].ID+1 {
+		t.Fatalf("item IDs %d and %d are not assigned from one sequence", first.Items[0].ID, second.Items[0].ID)
+	}
+}
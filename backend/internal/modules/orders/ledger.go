@@ -0,0 +1,102 @@
+package orders
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/settlement"
+	"kit8-backend/internal/core/tax"
+)
+
+// Ledger реализует settlement.Orders: пересчитывает статус оплаты заказов по платежам Кассы
+type Ledger struct {
+	orders OrderRepository
+}
+
+// NewLedger создает учет оплаты заказов
+func NewLedger(orders OrderRepository) *Ledger {
+	return &Ledger{orders: orders}
+}
+
+// OrderTotal возвращает сумму заказа, блокируя его в транзакции из контекста
+func (l *Ledger) OrderTotal(ctx context.Context, customerID, orderID int) (money.Money, error) {
+	total, err := l.orders.OrderTotal(ctx, customerID, orderID)
+	if errors.Is(err, ErrNotFound) {
+		return money.Money{}, settlement.ErrOrderNotFound
+	}
+	return total, err
+}
+
+// ApplyPayments пересчитывает статус оплаты заказа по итогам его платежей
+func (l *Ledger) ApplyPayments(ctx context.Context, customerID, orderID int, summary settlement.Summary) error {
+	order, err := l.orders.GetOrder(ctx, customerID, orderID)
+	if errors.Is(err, ErrNotFound) {
+		return settlement.ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = l.orders.SetPayment(ctx, customerID, orderID, summary.Status(order.TotalAmount), summary.Paid)
+	if errors.Is(err, ErrNotFound) {
+		return settlement.ErrOrderNotFound
+	}
+	return err
+}
+
+// OrderItems возвращает позиции заказа для кассового чека
+func (l *Ledger) OrderItems(ctx context.Context, customerID, orderID int) ([]settlement.Item, error) {
+	order, err := l.orders.GetOrder(ctx, customerID, orderID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, settlement.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]settlement.Item, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, settlement.Item{
+			Name:        item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       item.Total.Div(int64(item.Quantity)), // Цена с налогом; у позиции со скидкой итог может не делиться на количество
+			Total:       item.Total,
+			TaxCategory: item.TaxCategory,
+			Tax:         item.Tax,
+		})
+	}
+	return items, nil
+}
+
+// summarize заполняет итоги заказа по его позициям: сумму до скидок и скидки, сумму без налога
+// позиций, налог, сумму заказа без налога и разбивку по налоговым категориям
+func (o *Order) summarize() {
+	lines := make([]tax.Line, 0, len(o.Items))
+	o.Subtotal, o.DiscountAmount = money.Money{}, money.Money{}
+	for i := range o.Items {
+		item := &o.Items[i]
+		item.Net = item.Total.Sub(item.Tax)
+		o.Subtotal = o.Subtotal.Add(item.Price.Mul(int64(item.Quantity)))
+		o.DiscountAmount = o.DiscountAmount.Add(item.DiscountAmount)
+		lines = append(lines, tax.Line{
+			Category: item.TaxCategory,
+			Rate:     tax.Rate(item.TaxCategory),
+			Net:      item.Net,
+			Tax:      item.Tax,
+			Total:    item.Total,
+		})
+	}
+	o.TaxBreakdown = tax.Summarize(lines)
+	o.TaxAmount = tax.Sum(lines).Tax
+	o.NetAmount = o.TotalAmount.Sub(o.TaxAmount)
+	if o.Discounts == nil {
+		o.Discounts = []AppliedDiscount{}
+	}
+	o.Outstanding = outstanding(o.TotalAmount, o.PaidAmount)
+}
+
+// outstanding возвращает остаток к оплате заказа; переплата остаток не делает отрицательным
+func outstanding(total, paid money.Money) money.Money {
+	return money.Max(total.Sub(paid), money.Money{})
+}
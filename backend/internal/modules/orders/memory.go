@@ -0,0 +1,368 @@
+package orders
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/core/sales"
+)
+
+// MemoryRepository - потокобезопасная реализация OrderRepository и CouponRepository в памяти процесса.
+// Как и в PostgreSQL, ID заказов и позиций назначаются общими последовательностями
+// для всех компаний, а заказы других компаний не видны.
+type MemoryRepository struct {
+	mu            sync.RWMutex
+	orders        map[int]Order
+	history       map[int][]StatusChange // История статусов по ID заказа
+	coupons       map[int]Coupon
+	nextID        int
+	nextItemID    int
+	nextHistoryID int
+	nextCouponID  int
+	crm           sales.CRM // Контакты клиентов заказов и сделки, с которых снимается ссылка на удаленный заказ
+}
+
+// NewMemoryRepository создает пустой репозиторий заказов в памяти. Клиент заказа проверяется
+// по контактам crm, а при удалении заказа ссылка на него снимается со сделки в crm;
+// nil - заказы не связаны с CRM и не могут ссылаться на контакты.
+func NewMemoryRepository(crm sales.CRM) *MemoryRepository {
+	return &MemoryRepository{
+		orders:  make(map[int]Order),
+		history: make(map[int][]StatusChange),
+		coupons: make(map[int]Coupon),
+		crm:     crm,
+	}
+}
+
+// checkContact проверяет, что клиент заказа - контакт CRM его компании. Вызывается
+// без блокировки: конвертация сделки блокирует сделку раньше заказов.
+func (r *MemoryRepository) checkContact(ctx context.Context, o *Order) error {
+	if o.ContactID == 0 {
+		return nil
+	}
+	if r.crm == nil {
+		return ErrContactNotFound
+	}
+	exists, err := r.crm.ContactExists(ctx, o.CustomerID, o.ContactID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+// clone возвращает копию заказа, не разделяющую позиции и скидки с хранилищем
+func clone(o Order) Order {
+	o.Items = append([]OrderItem{}, o.Items...)
+	o.Discounts = append([]AppliedDiscount{}, o.Discounts...)
+	o.summarize()
+	return o
+}
+
+func (r *MemoryRepository) ListOrders(ctx context.Context, customerID int) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := []Order{}
+	for _, o := range r.orders {
+		if o.CustomerID == customerID {
+			orders = append(orders, clone(o))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+func (r *MemoryRepository) GetOrder(ctx context.Context, customerID, id int) (*Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orders[id]
+	if !ok || o.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	o = clone(o)
+	return &o, nil
+}
+
+func (r *MemoryRepository) CreateOrder(ctx context.Context, o *Order) error {
+	// Заказ по сделке создается под блокировкой сделки в CRM: ее контакт уже проверен CRM
+	if o.DealID == 0 {
+		if err := r.checkContact(ctx, o); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if o.DealID != 0 {
+		for _, existing := range r.orders {
+			if existing.DealID == o.DealID {
+				return ErrDealOrdered
+			}
+		}
+	}
+	if o.CouponID != 0 {
+		coupon, ok := r.coupons[o.CouponID]
+		if !ok || coupon.CustomerID != o.CustomerID {
+			return ErrCouponNotFound
+		}
+		if coupon.UsageLimit > 0 && r.usedCount(coupon.ID) >= coupon.UsageLimit {
+			return &CouponError{Code: coupon.Code, Reason: CouponExhausted}
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextID++
+	o.ID = r.nextID
+	o.CreatedAt = now
+	o.UpdatedAt = now
+	for i := range o.Items {
+		r.nextItemID++
+		o.Items[i].ID = r.nextItemID
+	}
+	r.orders[o.ID] = clone(*o)
+	return nil
+}
+
+func (r *MemoryRepository) UpdateOrder(ctx context.Context, o *Order) error {
+	if err := r.checkContact(ctx, o); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[o.ID]
+	if !ok || existing.CustomerID != o.CustomerID {
+		return ErrNotFound
+	}
+
+	// Позиции, сумма, валюта, статус и статус оплаты заказа не меняются, как и в PostgreSQL
+	existing.ContactID = o.ContactID
+	existing.ShippingAddress = o.ShippingAddress
+	existing.Notes = o.Notes
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.orders[o.ID] = existing
+
+	o.TotalAmount = existing.TotalAmount
+	o.Currency = existing.Currency
+	o.PricesIncludeTax = existing.PricesIncludeTax
+	o.Status = existing.Status
+	o.PaymentStatus = existing.PaymentStatus
+	o.PaidAmount = existing.PaidAmount
+	o.Outstanding = outstanding(existing.TotalAmount, existing.PaidAmount)
+	o.CreatedAt = existing.CreatedAt
+	o.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *MemoryRepository) DeleteOrder(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	existing, ok := r.orders[id]
+	if !ok || existing.CustomerID != customerID {
+		r.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(r.orders, id)
+	delete(r.history, id)
+	r.mu.Unlock()
+
+	// Ссылка снимается после снятия блокировки: конвертация сделки блокирует
+	// сделку раньше заказов
+	if existing.DealID == 0 || r.crm == nil {
+		return nil
+	}
+	return r.crm.UnlinkOrder(ctx, customerID, existing.DealID, id)
+}
+
+func (r *MemoryRepository) OrderTotal(ctx context.Context, customerID, id int) (money.Money, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orders[id]
+	if !ok || o.CustomerID != customerID {
+		return money.Money{}, ErrNotFound
+	}
+	return o.TotalAmount.In(o.Currency), nil
+}
+
+func (r *MemoryRepository) SetPayment(ctx context.Context, customerID, id int, status string, paid money.Money) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	existing.PaymentStatus = status
+	existing.PaidAmount = paid
+	existing.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.orders[id] = existing
+	return nil
+}
+
+func (r *MemoryRepository) ChangeStatus(ctx context.Context, customerID int, change *StatusChange, apply func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[change.OrderID]
+	if !ok || existing.CustomerID != customerID {
+		return ErrNotFound
+	}
+	if existing.Status != change.FromStatus {
+		return ErrStatusChanged
+	}
+
+	// Заказ заблокирован, пока выполняется apply: если она завершится ошибкой,
+	// статус не изменится
+	if apply != nil {
+		if err := apply(ctx); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	existing.Status = change.ToStatus
+	existing.UpdatedAt = now
+	r.orders[change.OrderID] = existing
+
+	r.nextHistoryID++
+	change.ID = r.nextHistoryID
+	change.ChangedAt = now
+	r.history[change.OrderID] = append(r.history[change.OrderID], *change)
+	return nil
+}
+
+func (r *MemoryRepository) StatusHistory(ctx context.Context, customerID, id int) ([]StatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orders[id]
+	if !ok || o.CustomerID != customerID {
+		return nil, ErrNotFound
+	}
+	return append([]StatusChange{}, r.history[id]...), nil
+}
+
+// usedCount возвращает число заказов с купоном, кроме отмененных. Вызывается под блокировкой.
+func (r *MemoryRepository) usedCount(couponID int) int {
+	n := 0
+	for _, o := range r.orders {
+		if o.CouponID == couponID && o.Status != StatusCancelled {
+			n++
+		}
+	}
+	return n
+}
+
+// codeTaken сообщает, занят ли код другим купоном компании. Вызывается под блокировкой.
+func (r *MemoryRepository) codeTaken(coupon *Coupon) bool {
+	for _, existing := range r.coupons {
+		if existing.CustomerID == coupon.CustomerID && existing.Code == coupon.Code && existing.ID != coupon.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryRepository) ListCoupons(ctx context.Context, customerID int) ([]Coupon, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coupons := []Coupon{}
+	for _, coupon := range r.coupons {
+		if coupon.CustomerID == customerID {
+			coupon.UsedCount = r.usedCount(coupon.ID)
+			coupons = append(coupons, coupon)
+		}
+	}
+	sort.Slice(coupons, func(i, j int) bool { return coupons[i].ID < coupons[j].ID })
+	return coupons, nil
+}
+
+func (r *MemoryRepository) GetCoupon(ctx context.Context, customerID, id int) (*Coupon, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coupon, ok := r.coupons[id]
+	if !ok || coupon.CustomerID != customerID {
+		return nil, ErrCouponNotFound
+	}
+	coupon.UsedCount = r.usedCount(coupon.ID)
+	return &coupon, nil
+}
+
+func (r *MemoryRepository) CouponByCode(ctx context.Context, customerID int, code string) (*Coupon, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, coupon := range r.coupons {
+		if coupon.CustomerID == customerID && coupon.Code == code {
+			coupon.UsedCount = r.usedCount(coupon.ID)
+			return &coupon, nil
+		}
+	}
+	return nil, ErrCouponNotFound
+}
+
+func (r *MemoryRepository) CreateCoupon(ctx context.Context, coupon *Coupon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.codeTaken(coupon) {
+		return ErrCouponExists
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.nextCouponID++
+	coupon.ID = r.nextCouponID
+	coupon.UsedCount = 0
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+	r.coupons[coupon.ID] = *coupon
+	return nil
+}
+
+func (r *MemoryRepository) UpdateCoupon(ctx context.Context, coupon *Coupon) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.coupons[coupon.ID]
+	if !ok || existing.CustomerID != coupon.CustomerID {
+		return ErrCouponNotFound
+	}
+	if r.codeTaken(coupon) {
+		return ErrCouponExists
+	}
+	coupon.UsedCount = r.usedCount(coupon.ID)
+	coupon.CreatedAt = existing.CreatedAt
+	coupon.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.coupons[coupon.ID] = *coupon
+	return nil
+}
+
+func (r *MemoryRepository) DeleteCoupon(ctx context.Context, customerID, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.coupons[id]
+	if !ok || existing.CustomerID != customerID {
+		return ErrCouponNotFound
+	}
+	delete(r.coupons, id)
+
+	// Как и ON DELETE SET NULL в PostgreSQL: заказы теряют ссылку на купон, но не его код
+	for orderID, o := range r.orders {
+		if o.CouponID == id {
+			o.CouponID = 0
+			r.orders[orderID] = o
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package orders
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit8-backend/internal/core/rbac"
+	"kit8-backend/internal/core/registry"
+)
+
+// Module подключает Заказы к платформе
+type Module struct {
+	ctrl *Controller
+}
+
+// NewModule создает модуль Заказов
+func NewModule(ctrl *Controller) *Module {
+	return &Module{ctrl: ctrl}
+}
+
+// Info возвращает описание модуля для каталога
+func (m *Module) Info() registry.Info {
+	return registry.Info{
+		Name:         "orders",
+		Title:        "Заказы",
+		Description:  "Прием заказов, статусы и доставка",
+		MonthlyPrice: 9,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты Заказов
+func (m *Module) RegisterRoutes(router fiber.Router) {
+	router.Get("/stats", rbac.Require(rbac.OrdersRead), m.ctrl.GetOrderStats)
+	router.Get("/orders", rbac.Require(rbac.OrdersRead), m.ctrl.GetOrders)
+	router.Post("/orders", rbac.Require(rbac.OrdersWrite), m.ctrl.CreateOrder)
+	router.Put("/orders/:id", rbac.Require(rbac.OrdersWrite), m.ctrl.UpdateOrder)
+	router.Delete("/orders/:id", rbac.Require(rbac.OrdersDelete), m.ctrl.DeleteOrder)
+	router.Get("/orders/:id", rbac.Require(rbac.OrdersRead), m.ctrl.GetOrder)
+	router.Get("/orders/:id/history", rbac.Require(rbac.OrdersRead), m.ctrl.GetOrderHistory)
+	router.Post("/orders/:id/confirm", rbac.Require(rbac.OrdersWrite), m.ctrl.ConfirmOrder)
+	router.Post("/orders/:id/start", rbac.Require(rbac.OrdersFulfill), m.ctrl.StartOrder)
+	router.Post("/orders/:id/ship", rbac.Require(rbac.OrdersFulfill), m.ctrl.ShipOrder)
+	router.Post("/orders/:id/deliver", rbac.Require(rbac.OrdersFulfill), m.ctrl.DeliverOrder)
+	router.Post("/orders/:id/cancel", rbac.Require(rbac.OrdersWrite), m.ctrl.CancelOrder)
+
+	// Купоны (промокоды) на скидку по заказу
+	router.Get("/coupons", rbac.Require(rbac.OrdersRead), m.ctrl.GetCoupons)
+	router.Post("/coupons", rbac.Require(rbac.CouponsWrite), m.ctrl.CreateCoupon)
+	router.Get("/coupons/:id", rbac.Require(rbac.OrdersRead), m.ctrl.GetCoupon)
+	router.Put("/coupons/:id", rbac.Require(rbac.CouponsWrite), m.ctrl.UpdateCoupon)
+	router.Delete("/coupons/:id", rbac.Require(rbac.CouponsWrite), m.ctrl.DeleteCoupon)
+}
@@ -0,0 +1,517 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"kit8-backend/internal/core/currency"
+	"kit8-backend/internal/core/money"
+	"kit8-backend/internal/database"
+)
+
+// PostgresRepository реализует OrderRepository и CouponRepository поверх PostgreSQL
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository создает репозиторий заказов
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+const orderColumns = `id, customer_id, COALESCE(contact_id, 0), COALESCE(deal_id, 0), total_amount, currency, prices_include_tax, status, payment_status, paid_amount,
+	COALESCE(coupon_id, 0), coupon_code, discount, discounts, shipping_address, notes, created_at, updated_at`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (*Order, error) {
+	var o Order
+	var discount, discounts []byte
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&o.ID, &o.CustomerID, &o.ContactID, &o.DealID, &o.TotalAmount, &o.Currency, &o.PricesIncludeTax, &o.Status, &o.PaymentStatus, &o.PaidAmount,
+		&o.CouponID, &o.CouponCode, &discount, &discounts, &o.ShippingAddress, &o.Notes, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if discount != nil {
+		if err := json.Unmarshal(discount, &o.Discount); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(discounts, &o.Discounts); err != nil {
+		return nil, err
+	}
+	o.Items = []OrderItem{}
+	o.Outstanding = outstanding(o.TotalAmount, o.PaidAmount)
+	o.CreatedAt = database.FormatTime(createdAt)
+	o.UpdatedAt = database.FormatTime(updatedAt)
+	return &o, nil
+}
+
+// itemColumns - колонки позиции заказа в порядке scanItem
+const itemColumns = `id, COALESCE(product_id, 0), product_name, quantity, price, discount, discount_amount, tax_category, tax, total`
+
+func scanItem(row interface{ Scan(...interface{}) error }, dest ...interface{}) (OrderItem, error) {
+	var item OrderItem
+	var discount []byte
+	err := row.Scan(append(dest, &item.ID, &item.ProductID, &item.ProductName, &item.Quantity, &item.Price,
+		&discount, &item.DiscountAmount, &item.TaxCategory, &item.Tax, &item.Total)...)
+	if err != nil {
+		return item, err
+	}
+	if discount != nil {
+		err = json.Unmarshal(discount, &item.Discount)
+	}
+	return item, err
+}
+
+// discountValue кодирует скидку для колонки JSONB; без скидки - NULL
+func discountValue(d *Discount) (sql.NullString, error) {
+	if d == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(d)
+	return sql.NullString{String: string(data), Valid: true}, err
+}
+
+func (r *PostgresRepository) ListOrders(ctx context.Context, customerID int) ([]Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+orderColumns+` FROM orders WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	index := make(map[int]int)
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[o.ID] = len(orders)
+		orders = append(orders, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Загружаем позиции всех заказов компании одним запросом
+	items, err := r.db.QueryContext(ctx,
+		`SELECT order_id, `+itemColumns+` FROM order_items
+		 WHERE order_id IN (SELECT id FROM orders WHERE customer_id = $1) ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer items.Close()
+
+	for items.Next() {
+		var orderID int
+		item, err := scanItem(items, &orderID)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[orderID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	for i := range orders {
+		orders[i].summarize()
+	}
+	return orders, items.Err()
+}
+
+func (r *PostgresRepository) GetOrder(ctx context.Context, customerID, id int) (*Order, error) {
+	q := database.Conn(ctx, r.db)
+	row := q.QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM orders WHERE customer_id = $1 AND id = $2`, customerID, id)
+	o, err := scanOrder(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+itemColumns+` FROM order_items WHERE order_id = $1 ORDER BY id`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		o.Items = append(o.Items, item)
+	}
+	o.summarize()
+	return o, rows.Err()
+}
+
+func (r *PostgresRepository) CreateOrder(ctx context.Context, o *Order) error {
+	var createdAt, updatedAt time.Time
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Купон блокируется до конца транзакции: параллельные заказы не превысят лимит использований
+		if o.CouponID != 0 {
+			var code string
+			var limit, used int
+			err := q.QueryRowContext(ctx,
+				`SELECT code, usage_limit FROM coupons WHERE customer_id = $1 AND id = $2 FOR UPDATE`,
+				o.CustomerID, o.CouponID).Scan(&code, &limit)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrCouponNotFound
+			}
+			if err != nil {
+				return err
+			}
+			err = q.QueryRowContext(ctx,
+				`SELECT COUNT(*) FROM orders WHERE coupon_id = $1 AND status <> $2`, o.CouponID, StatusCancelled).Scan(&used)
+			if err != nil {
+				return err
+			}
+			if limit > 0 && used >= limit {
+				return &CouponError{Code: code, Reason: CouponExhausted}
+			}
+		}
+
+		if err := checkContact(ctx, q, o.CustomerID, o.ContactID); err != nil {
+			return err
+		}
+		discount, err := discountValue(o.Discount)
+		if err != nil {
+			return err
+		}
+		discounts, err := json.Marshal(o.Discounts)
+		if err != nil {
+			return err
+		}
+		err = q.QueryRowContext(ctx,
+			`INSERT INTO orders (customer_id, contact_id, total_amount, currency, prices_include_tax, status, payment_status,
+			                     shipping_address, notes, coupon_id, coupon_code, discount, discounts, deal_id)
+			 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), $11, $12, $13, NULLIF($14, 0))
+			 RETURNING id, created_at, updated_at`,
+			o.CustomerID, o.ContactID, o.TotalAmount, o.Currency, o.PricesIncludeTax, o.Status, o.PaymentStatus, o.ShippingAddress, o.Notes,
+			o.CouponID, o.CouponCode, discount, discounts, o.DealID,
+		).Scan(&o.ID, &createdAt, &updatedAt)
+		if err != nil {
+			return dealError(err)
+		}
+
+		for i := range o.Items {
+			item := &o.Items[i]
+			discount, err := discountValue(item.Discount)
+			if err != nil {
+				return err
+			}
+			err = q.QueryRowContext(ctx,
+				`INSERT INTO order_items (order_id, product_id, product_name, quantity, price, discount, discount_amount,
+				                          tax_category, tax, total)
+				 VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+				o.ID, item.ProductID, item.ProductName, item.Quantity, item.Price, discount, item.DiscountAmount,
+				item.TaxCategory, item.Tax, item.Total,
+			).Scan(&item.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	o.CreatedAt = database.FormatTime(createdAt)
+	o.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+// dealError превращает нарушение уникальности заказа по сделке в ErrDealOrdered
+func dealError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "orders_deal_id_key" {
+		return ErrDealOrdered
+	}
+	return err
+}
+
+// checkContact проверяет, что клиент заказа - контакт его компании: внешний ключ
+// на contacts не ограничен компанией
+func checkContact(ctx context.Context, q database.Querier, customerID, contactID int) error {
+	if contactID == 0 {
+		return nil
+	}
+	var exists bool
+	err := q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM contacts WHERE customer_id = $1 AND id = $2)`,
+		customerID, contactID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) UpdateOrder(ctx context.Context, o *Order) error {
+	q := database.Conn(ctx, r.db)
+	if err := checkContact(ctx, q, o.CustomerID, o.ContactID); err != nil {
+		return err
+	}
+
+	var createdAt, updatedAt time.Time
+	err := q.QueryRowContext(ctx,
+		`UPDATE orders SET contact_id = NULLIF($3, 0), shipping_address = $4, notes = $5, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2
+		 RETURNING total_amount, currency, prices_include_tax, status, payment_status, paid_amount, created_at, updated_at`,
+		o.CustomerID, o.ID, o.ContactID, o.ShippingAddress, o.Notes,
+	).Scan(&o.TotalAmount, &o.Currency, &o.PricesIncludeTax, &o.Status, &o.PaymentStatus, &o.PaidAmount, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	o.Outstanding = outstanding(o.TotalAmount, o.PaidAmount)
+	o.CreatedAt = database.FormatTime(createdAt)
+	o.UpdatedAt = database.FormatTime(updatedAt)
+	return nil
+}
+
+func (r *PostgresRepository) DeleteOrder(ctx context.Context, customerID, id int) error {
+	// Позиции заказа удаляются каскадно
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM orders WHERE customer_id = $1 AND id = $2`, customerID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) OrderTotal(ctx context.Context, customerID, id int) (money.Money, error) {
+	var total money.Money
+	var cur string
+	err := database.Conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT total_amount, currency FROM orders WHERE customer_id = $1 AND id = $2 FOR UPDATE`,
+		customerID, id).Scan(&total, &cur)
+	if errors.Is(err, sql.ErrNoRows) {
+		return money.Money{}, ErrNotFound
+	}
+	return total.In(cur), err
+}
+
+func (r *PostgresRepository) SetPayment(ctx context.Context, customerID, id int, status string, paid money.Money) error {
+	res, err := database.Conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE orders SET payment_status = $3, paid_amount = $4, updated_at = now()
+		 WHERE customer_id = $1 AND id = $2`,
+		customerID, id, status, paid)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ChangeStatus(ctx context.Context, customerID int, change *StatusChange, apply func(ctx context.Context) error) error {
+	var changedAt time.Time
+	err := database.InTx(ctx, r.db, func(ctx context.Context) error {
+		q := database.Conn(ctx, r.db)
+
+		// Статус меняется, только если его не успел изменить другой запрос.
+		// Строка заказа остается заблокированной до конца транзакции.
+		res, err := q.ExecContext(ctx,
+			`UPDATE orders SET status = $4, updated_at = now()
+			 WHERE customer_id = $1 AND id = $2 AND status = $3`,
+			customerID, change.OrderID, change.FromStatus, change.ToStatus)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			var exists bool
+			err := q.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM orders WHERE customer_id = $1 AND id = $2)`,
+				customerID, change.OrderID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotFound
+			}
+			return ErrStatusChanged
+		}
+
+		if apply != nil {
+			if err := apply(ctx); err != nil {
+				return err
+			}
+		}
+
+		return q.QueryRowContext(ctx,
+			`INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, comment)
+			 VALUES ($1, $2, $3, NULLIF($4, 0), $5) RETURNING id, changed_at`,
+			change.OrderID, change.FromStatus, change.ToStatus, change.ChangedBy, change.Comment,
+		).Scan(&change.ID, &changedAt)
+	})
+	if err != nil {
+		return err
+	}
+	change.ChangedAt = database.FormatTime(changedAt)
+	return nil
+}
+
+func (r *PostgresRepository) StatusHistory(ctx context.Context, customerID, id int) ([]StatusChange, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT h.id, h.order_id, h.from_status, h.to_status, COALESCE(h.changed_by, 0), h.comment, h.changed_at
+		 FROM order_status_history h JOIN orders o ON o.id = h.order_id
+		 WHERE o.customer_id = $1 AND o.id = $2 ORDER BY h.id`, customerID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []StatusChange{}
+	for rows.Next() {
+		var h StatusChange
+		var changedAt time.Time
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.FromStatus, &h.ToStatus, &h.ChangedBy, &h.Comment, &changedAt); err != nil {
+			return nil, err
+		}
+		h.ChangedAt = database.FormatTime(changedAt)
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// Число использований не хранится в купоне, а считается по заказам, кроме отмененных
+const couponColumns = `id, customer_id, code, percent, amount, currency, min_order_amount, valid_from, valid_until,
+	usage_limit, (SELECT COUNT(*) FROM orders o WHERE o.coupon_id = coupons.id AND o.status <> 'cancelled'),
+	active, created_at, updated_at`
+
+func scanCoupon(row interface{ Scan(...interface{}) error }) (*Coupon, error) {
+	var cp Coupon
+	var validFrom, validUntil sql.NullTime
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&cp.ID, &cp.CustomerID, &cp.Code, &cp.Percent, &cp.Amount, &cp.Currency, &cp.MinOrderAmount,
+		&validFrom, &validUntil, &cp.UsageLimit, &cp.UsedCount, &cp.Active, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if validFrom.Valid {
+		cp.ValidFrom = validFrom.Time.Format(currency.DateLayout)
+	}
+	if validUntil.Valid {
+		cp.ValidUntil = validUntil.Time.Format(currency.DateLayout)
+	}
+	cp.CreatedAt = database.FormatTime(createdAt)
+	cp.UpdatedAt = database.FormatTime(updatedAt)
+	return &cp, nil
+}
+
+// couponSaved переводит нарушение уникальности кода в ErrCouponExists
+func couponSaved(cp *Coupon, saved *Coupon, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrCouponExists
+	}
+	if err != nil {
+		return err
+	}
+	*cp = *saved
+	return nil
+}
+
+func (r *PostgresRepository) ListCoupons(ctx context.Context, customerID int) ([]Coupon, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+couponColumns+` FROM coupons WHERE customer_id = $1 ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		cp, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, *cp)
+	}
+	return coupons, rows.Err()
+}
+
+func (r *PostgresRepository) GetCoupon(ctx context.Context, customerID, id int) (*Coupon, error) {
+	return scanCoupon(r.db.QueryRowContext(ctx,
+		`SELECT `+couponColumns+` FROM coupons WHERE customer_id = $1 AND id = $2`, customerID, id))
+}
+
+func (r *PostgresRepository) CouponByCode(ctx context.Context, customerID int, code string) (*Coupon, error) {
+	return scanCoupon(r.db.QueryRowContext(ctx,
+		`SELECT `+couponColumns+` FROM coupons WHERE customer_id = $1 AND code = $2`, customerID, code))
+}
+
+func (r *PostgresRepository) CreateCoupon(ctx context.Context, cp *Coupon) error {
+	saved, err := scanCoupon(r.db.QueryRowContext(ctx,
+		`INSERT INTO coupons (customer_id, code, percent, amount, currency, min_order_amount, valid_from, valid_until,
+		                      usage_limit, active)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::date, NULLIF($8, '')::date, $9, $10)
+		 RETURNING `+couponColumns,
+		cp.CustomerID, cp.Code, cp.Percent, cp.Amount, cp.Currency, cp.MinOrderAmount, cp.ValidFrom, cp.ValidUntil,
+		cp.UsageLimit, cp.Active))
+	return couponSaved(cp, saved, err)
+}
+
+func (r *PostgresRepository) UpdateCoupon(ctx context.Context, cp *Coupon) error {
+	saved, err := scanCoupon(r.db.QueryRowContext(ctx,
+		`UPDATE coupons SET code = $3, percent = $4, amount = $5, currency = $6, min_order_amount = $7,
+		        valid_from = NULLIF($8, '')::date, valid_until = NULLIF($9, '')::date, usage_limit = $10, active = $11,
+		        updated_at = now()
+		 WHERE customer_id = $1 AND id = $2
+		 RETURNING `+couponColumns,
+		cp.CustomerID, cp.ID, cp.Code, cp.Percent, cp.Amount, cp.Currency, cp.MinOrderAmount, cp.ValidFrom, cp.ValidUntil,
+		cp.UsageLimit, cp.Active))
+	return couponSaved(cp, saved, err)
+}
+
+func (r *PostgresRepository) DeleteCoupon(ctx context.Context, customerID, id int) error {
+	// Заказы теряют ссылку на купон (ON DELETE SET NULL), но сохраняют его код и скидку
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM coupons WHERE customer_id = $1 AND id = $2`, customerID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCouponNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package orders
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/money"
+)
+
+// ErrNotFound возвращается, если заказ не найден или принадлежит другой компании
+var ErrNotFound = errors.New("orders: order not found")
+
+// ErrStatusChanged возвращается, если статус заказа изменился параллельным запросом
+var ErrStatusChanged = errors.New("orders: order status changed concurrently")
+
+// ErrDealOrdered возвращается при создании второго заказа по одной сделке CRM
+var ErrDealOrdered = errors.New("orders: deal already has an order")
+
+// ErrContactNotFound возвращается, если клиент заказа не найден среди контактов CRM компании
+var ErrContactNotFound = errors.New("orders: order contact not found")
+
+// OrderRepository хранит заказы вместе с их позициями.
+// Все методы ограничены компанией customerID.
+type OrderRepository interface {
+	ListOrders(ctx context.Context, customerID int) ([]Order, error)
+	GetOrder(ctx context.Context, customerID, id int) (*Order, error)
+	// CreateOrder сохраняет заказ и его позиции, назначая им ID. Если заказ использует купон
+	// (CouponID), лимит использований купона проверяется атомарно с созданием заказа:
+	// исчерпанный купон отклоняется с *CouponError. Заказ по сделке (DealID) может быть
+	// только один, иначе ErrDealOrdered. Клиент заказа (ContactID) должен быть контактом
+	// компании, иначе ErrContactNotFound. Реализация на PostgreSQL выполняется в транзакции
+	// из контекста (database.WithTx), если она есть.
+	CreateOrder(ctx context.Context, order *Order) error
+	// UpdateOrder обновляет поля заказа; позиции, сумма, статус и статус оплаты заказа не меняются.
+	// Клиент заказа проверяется, как в CreateOrder.
+	UpdateOrder(ctx context.Context, order *Order) error
+	DeleteOrder(ctx context.Context, customerID, id int) error
+	// OrderTotal возвращает сумму заказа в его валюте; в транзакции из контекста блокирует заказ до ее завершения
+	OrderTotal(ctx context.Context, customerID, id int) (money.Money, error)
+	// SetPayment меняет статус оплаты и оплаченную сумму заказа
+	SetPayment(ctx context.Context, customerID, id int, status string, paid money.Money) error
+
+	// ChangeStatus переводит заказ из change.FromStatus в change.ToStatus и записывает
+	// переход в историю. Если текущий статус уже не FromStatus, возвращает ErrStatusChanged.
+	// apply (если задана) выполняется атомарно со сменой статуса: ее ошибка отменяет переход.
+	// Реализация на PostgreSQL передает apply контекст со своей транзакцией (database.WithTx).
+	ChangeStatus(ctx context.Context, customerID int, change *StatusChange, apply func(ctx context.Context) error) error
+	// StatusHistory возвращает историю статусов заказа в порядке изменения
+	StatusHistory(ctx context.Context, customerID, id int) ([]StatusChange, error)
+}
@@ -0,0 +1,63 @@
+package orders
+
+import (
+	"context"
+	"errors"
+
+	"kit8-backend/internal/core/sales"
+	"kit8-backend/internal/core/settlement"
+	"kit8-backend/internal/core/stock"
+)
+
+// Sales реализует sales.Orders: создает заказы по выигранным сделкам CRM
+// по тем же правилам налога и остатков, что и контроллер Заказов
+type Sales struct {
+	ctrl *Controller
+}
+
+// NewSales создает заказы по сделкам через контроллер Заказов
+func NewSales(ctrl *Controller) *Sales {
+	return &Sales{ctrl: ctrl}
+}
+
+// CreateOrder создает новый заказ клиента сделки с товарами сделки по ее ценам и в ее валюте
+func (s *Sales) CreateOrder(ctx context.Context, customerID int, deal sales.Deal) (int, error) {
+	order := Order{
+		CustomerID:    customerID,
+		ContactID:     deal.ContactID,
+		DealID:        deal.ID,
+		Currency:      deal.Currency,
+		Status:        StatusNew,
+		PaymentStatus: settlement.StatusUnpaid,
+		Items:         make([]OrderItem, 0, len(deal.Lines)),
+	}
+	for _, line := range deal.Lines {
+		order.Items = append(order.Items, OrderItem{
+			ProductID:   line.ProductID,
+			ProductName: line.ProductName,
+			Quantity:    line.Quantity,
+			Price:       line.Price,
+		})
+	}
+
+	// Как и при создании заказа вручную, товар резервируется только при подтверждении заказа
+	shortages, err := s.ctrl.stock.Shortages(ctx, customerID, stockLines(order.Items))
+	if err != nil {
+		return 0, err
+	}
+	if len(shortages) > 0 {
+		return 0, &stock.InsufficientError{Shortages: shortages}
+	}
+
+	if err := s.ctrl.price(ctx, &order, nil); err != nil {
+		return 0, err
+	}
+	err = s.ctrl.orders.CreateOrder(ctx, &order)
+	if errors.Is(err, ErrDealOrdered) {
+		return 0, sales.ErrDealConverted
+	}
+	if err != nil {
+		return 0, err
+	}
+	return order.ID, nil
+}
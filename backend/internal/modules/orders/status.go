@@ -0,0 +1,47 @@
+package orders
+
+import "errors"
+
+// Статусы заказа
+const (
+	StatusNew        = "new"
+	StatusConfirmed  = "confirmed"
+	StatusInProgress = "in-progress"
+	StatusShipped    = "shipped"
+	StatusDelivered  = "delivered"
+	StatusCancelled  = "cancelled"
+)
+
+// ErrInvalidTransition возвращается при попытке перевести заказ в недопустимый статус
+var ErrInvalidTransition = errors.New("orders: invalid status transition")
+
+// transitions - допустимые переходы: целевой статус и статусы, из которых в него можно перейти.
+// delivered и cancelled - конечные статусы.
+var transitions = map[string][]string{
+	StatusConfirmed:  {StatusNew},
+	StatusInProgress: {StatusConfirmed},
+	StatusShipped:    {StatusConfirmed, StatusInProgress},
+	StatusDelivered:  {StatusShipped},
+	StatusCancelled:  {StatusNew, StatusConfirmed, StatusInProgress},
+}
+
+// CanTransition сообщает, можно ли перевести заказ из статуса from в статус to
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[to] {
+		if s == from {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange - запись истории статусов заказа
+type StatusChange struct {
+	ID         int    `json:"id"`
+	OrderID    int    `json:"order_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	ChangedBy  int    `json:"changed_by"` // ID пользователя; 0 - запрос по API-ключу
+	Comment    string `json:"comment"`
+	ChangedAt  string `json:"changed_at"`
+}